@@ -0,0 +1,336 @@
+package chart
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"time"
+)
+
+const KindBar = "bar"         // series drawn as bars next to each other for every category
+const KindArea = "area"       // series stacked on top of each other over categories, e.g. cumulative flow
+const KindScatter = "scatter" // points in time, e.g. cycle time of done tickets
+
+const DefaultWidth = 960
+const DefaultHeight = 540
+
+const marginLeft = 70
+const marginRight = 170 // space for legend
+const marginTop = 50
+const marginBottom = 50
+const yTicks = 5
+const labelWidth = 80 // width reserved for single label on x axis
+const maxLabelLength = 12
+
+type Series struct {
+	Name   string
+	Values []float64 // one value per chart category
+}
+
+type Point struct {
+	Date  time.Time
+	Value float64
+}
+
+// Horizontal line drawn across the chart, e.g. percentile of values
+type Marker struct {
+	Name  string
+	Value float64
+}
+
+// Chart data, bar and area charts use categories and series while scatter charts use points
+type Chart struct {
+	Kind       string
+	Title      string
+	YLabel     string
+	Categories []string
+	Series     []Series
+	Points     []Point
+	Markers    []Marker
+}
+
+// Drawing primitives charts are rendered with, coordinates are in pixels with origin in top left corner
+type canvas interface {
+	rect(x float64, y float64, width float64, height float64, fill color.RGBA)
+	line(x1 float64, y1 float64, x2 float64, y2 float64, stroke color.RGBA)
+	circle(x float64, y float64, radius float64, fill color.RGBA)
+	polygon(xs []float64, ys []float64, fill color.RGBA)
+	text(x float64, y float64, value string, anchor string, fill color.RGBA) // y is text baseline
+}
+
+const anchorStart = "start"
+const anchorMiddle = "middle"
+const anchorEnd = "end"
+
+var background = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+var foreground = color.RGBA{R: 0x33, G: 0x33, B: 0x33, A: 0xff}
+var grid = color.RGBA{R: 0xdd, G: 0xdd, B: 0xdd, A: 0xff}
+var markerColor = color.RGBA{R: 0xd6, G: 0x27, B: 0x28, A: 0xff}
+
+var palette = []color.RGBA{
+	{R: 0x1f, G: 0x77, B: 0xb4, A: 0xff},
+	{R: 0xff, G: 0x7f, B: 0x0e, A: 0xff},
+	{R: 0x2c, G: 0xa0, B: 0x2c, A: 0xff},
+	{R: 0x94, G: 0x67, B: 0xbd, A: 0xff},
+	{R: 0x8c, G: 0x56, B: 0x4b, A: 0xff},
+	{R: 0xe3, G: 0x77, B: 0xc2, A: 0xff},
+	{R: 0x7f, G: 0x7f, B: 0x7f, A: 0xff},
+	{R: 0xbc, G: 0xbd, B: 0x22, A: 0xff},
+	{R: 0x17, G: 0xbe, B: 0xcf, A: 0xff},
+}
+
+func seriesColor(i int) color.RGBA {
+	return palette[i%len(palette)]
+}
+
+// Checks whether chart can be drawn
+func (chart Chart) validate() error {
+	switch chart.Kind {
+	case KindBar, KindArea:
+		for _, series := range chart.Series {
+			if len(series.Values) != len(chart.Categories) {
+				return fmt.Errorf("series [%s] has %d values for %d categories", series.Name, len(series.Values), len(chart.Categories))
+			}
+		}
+	case KindScatter:
+	default:
+		return fmt.Errorf("unknown chart kind [%s]", chart.Kind)
+	}
+
+	return nil
+}
+
+// Plot area of chart
+type plot struct {
+	left, top, right, bottom float64
+	yMax                     float64
+}
+
+func (p plot) width() float64 {
+	return p.right - p.left
+}
+
+func (p plot) height() float64 {
+	return p.bottom - p.top
+}
+
+func (p plot) y(value float64) float64 {
+	return p.bottom - value/p.yMax*p.height()
+}
+
+func draw(chart Chart, c canvas, width int, height int) error {
+	if err := chart.validate(); err != nil {
+		return err
+	}
+
+	p := plot{
+		left:   marginLeft,
+		top:    marginTop,
+		right:  float64(width - marginRight),
+		bottom: float64(height - marginBottom),
+		yMax:   niceCeil(chart.maxValue()),
+	}
+	if p.width() <= 0 || p.height() <= 0 {
+		return fmt.Errorf("chart size %dx%d is too small", width, height)
+	}
+
+	c.rect(0, 0, float64(width), float64(height), background)
+	c.text(float64(width)/2, 24, chart.Title, anchorMiddle, foreground)
+	c.text(p.left, p.top-12, chart.YLabel, anchorStart, foreground)
+
+	for i := 0; i <= yTicks; i++ {
+		value := p.yMax * float64(i) / yTicks
+		c.line(p.left, p.y(value), p.right, p.y(value), grid)
+		c.text(p.left-8, p.y(value)+4, formatValue(value), anchorEnd, foreground)
+	}
+
+	switch chart.Kind {
+	case KindBar:
+		drawBars(chart, c, p)
+	case KindArea:
+		drawAreas(chart, c, p)
+	case KindScatter:
+		drawPoints(chart, c, p)
+	}
+
+	for _, marker := range chart.Markers {
+		c.line(p.left, p.y(marker.Value), p.right, p.y(marker.Value), markerColor)
+	}
+
+	c.line(p.left, p.top, p.left, p.bottom, foreground)
+	c.line(p.left, p.bottom, p.right, p.bottom, foreground)
+
+	drawLegend(chart, c, p)
+	return nil
+}
+
+// Highest value drawn, stacked values are summed for area charts
+func (chart Chart) maxValue() float64 {
+	max := 0.0
+	switch chart.Kind {
+	case KindBar:
+		for _, series := range chart.Series {
+			for _, value := range series.Values {
+				max = math.Max(max, value)
+			}
+		}
+	case KindArea:
+		for i := range chart.Categories {
+			sum := 0.0
+			for _, series := range chart.Series {
+				sum += series.Values[i]
+			}
+			max = math.Max(max, sum)
+		}
+	case KindScatter:
+		for _, point := range chart.Points {
+			max = math.Max(max, point.Value)
+		}
+	}
+
+	for _, marker := range chart.Markers {
+		max = math.Max(max, marker.Value)
+	}
+
+	return max
+}
+
+func drawBars(chart Chart, c canvas, p plot) {
+	if len(chart.Categories) == 0 || len(chart.Series) == 0 {
+		return
+	}
+
+	groupWidth := p.width() / float64(len(chart.Categories))
+	barWidth := groupWidth * 0.8 / float64(len(chart.Series))
+
+	for s, series := range chart.Series {
+		for i, value := range series.Values {
+			x := p.left + float64(i)*groupWidth + groupWidth*0.1 + float64(s)*barWidth
+			c.rect(x, p.y(value), barWidth, p.bottom-p.y(value), seriesColor(s))
+		}
+	}
+
+	drawCategories(chart.Categories, c, p, func(i int) float64 {
+		return p.left + (float64(i)+0.5)*groupWidth
+	})
+}
+
+func drawAreas(chart Chart, c canvas, p plot) {
+	if len(chart.Categories) == 0 {
+		return
+	}
+
+	x := func(i int) float64 {
+		if len(chart.Categories) == 1 {
+			return p.left + p.width()/2
+		}
+		return p.left + p.width()*float64(i)/float64(len(chart.Categories)-1)
+	}
+
+	bottoms := make([]float64, len(chart.Categories))
+	for s, series := range chart.Series {
+		xs := make([]float64, 0, 2*len(bottoms))
+		ys := make([]float64, 0, 2*len(bottoms))
+
+		for i, value := range series.Values {
+			xs = append(xs, x(i))
+			ys = append(ys, p.y(bottoms[i]+value))
+		}
+		for i := len(bottoms) - 1; i >= 0; i-- {
+			xs = append(xs, x(i))
+			ys = append(ys, p.y(bottoms[i]))
+			bottoms[i] += series.Values[i]
+		}
+
+		c.polygon(xs, ys, seriesColor(s))
+	}
+
+	drawCategories(chart.Categories, c, p, x)
+}
+
+func drawPoints(chart Chart, c canvas, p plot) {
+	if len(chart.Points) == 0 {
+		return
+	}
+
+	from, to := chart.Points[0].Date, chart.Points[0].Date
+	for _, point := range chart.Points {
+		if point.Date.Before(from) {
+			from = point.Date
+		}
+		if point.Date.After(to) {
+			to = point.Date
+		}
+	}
+	from = from.Add(-12 * time.Hour)
+	to = to.Add(12 * time.Hour)
+
+	x := func(date time.Time) float64 {
+		return p.left + p.width()*float64(date.Sub(from))/float64(to.Sub(from))
+	}
+
+	for _, point := range chart.Points {
+		c.circle(x(point.Date), p.y(point.Value), 3, seriesColor(0))
+	}
+
+	labels := int(math.Max(1, math.Floor(p.width()/(1.5*labelWidth))))
+	for i := 0; i <= labels; i++ {
+		date := from.Add(time.Duration(float64(to.Sub(from)) * float64(i) / float64(labels)))
+		c.text(x(date), p.bottom+20, date.Format("2006-01-02"), anchorMiddle, foreground)
+	}
+}
+
+// Draws category labels on x axis, skipping some of them when they do not fit
+func drawCategories(categories []string, c canvas, p plot, x func(i int) float64) {
+	step := int(math.Ceil(float64(len(categories)) * labelWidth / p.width()))
+	if step < 1 {
+		step = 1
+	}
+
+	for i := 0; i < len(categories); i += step {
+		c.text(x(i), p.bottom+20, truncate(categories[i]), anchorMiddle, foreground)
+	}
+}
+
+func drawLegend(chart Chart, c canvas, p plot) {
+	y := p.top
+	for s, series := range chart.Series {
+		c.rect(p.right+20, y, 12, 12, seriesColor(s))
+		c.text(p.right+38, y+11, truncate(series.Name), anchorStart, foreground)
+		y += 20
+	}
+
+	for _, marker := range chart.Markers {
+		c.line(p.right+20, y+6, p.right+32, y+6, markerColor)
+		c.text(p.right+38, y+11, truncate(marker.Name+": "+formatValue(marker.Value)), anchorStart, foreground)
+		y += 20
+	}
+}
+
+// Rounds value up to 1, 2 or 5 times power of 10 so that axis ticks are readable
+func niceCeil(value float64) float64 {
+	if value <= 0 {
+		return 1
+	}
+
+	magnitude := math.Pow(10, math.Floor(math.Log10(value)))
+	for _, factor := range []float64{1, 2, 5, 10} {
+		if value <= factor*magnitude {
+			return factor * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64)
+}
+
+func truncate(label string) string {
+	runes := []rune(label)
+	if len(runes) <= maxLabelLength {
+		return label
+	}
+	return string(runes[:maxLabelLength-2]) + ".."
+}
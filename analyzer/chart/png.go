@@ -0,0 +1,157 @@
+package chart
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"sort"
+	"strings"
+)
+
+const glyphScale = 2                               // pixels per glyph dot
+const glyphAdvance = (glyphWidth + 1) * glyphScale // space taken by single character
+
+type pngCanvas struct {
+	image *image.RGBA
+}
+
+// Renders chart as PNG image of given size, texts use built-in bitmap font
+func RenderPng(chart Chart, width int, height int) ([]byte, error) {
+	c := &pngCanvas{image: image.NewRGBA(image.Rect(0, 0, width, height))}
+
+	if err := draw(chart, c, width, height); err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, c.image); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (c *pngCanvas) rect(x float64, y float64, width float64, height float64, fill color.RGBA) {
+	for py := round(y); py < round(y+height); py++ {
+		for px := round(x); px < round(x+width); px++ {
+			c.image.SetRGBA(px, py, fill)
+		}
+	}
+}
+
+// Draws 1px line with Bresenham's algorithm
+func (c *pngCanvas) line(x1 float64, y1 float64, x2 float64, y2 float64, stroke color.RGBA) {
+	x, y, endX, endY := round(x1), round(y1), round(x2), round(y2)
+	dx, dy := abs(endX-x), -abs(endY-y)
+	stepX, stepY := sign(endX-x), sign(endY-y)
+	e := dx + dy
+
+	for {
+		c.image.SetRGBA(x, y, stroke)
+		if x == endX && y == endY {
+			return
+		}
+		if 2*e >= dy {
+			e += dy
+			x += stepX
+		}
+		if 2*e <= dx {
+			e += dx
+			y += stepY
+		}
+	}
+}
+
+func (c *pngCanvas) circle(x float64, y float64, radius float64, fill color.RGBA) {
+	for py := round(y - radius); py <= round(y+radius); py++ {
+		for px := round(x - radius); px <= round(x+radius); px++ {
+			if math.Hypot(float64(px)-x, float64(py)-y) <= radius {
+				c.image.SetRGBA(px, py, fill)
+			}
+		}
+	}
+}
+
+// Fills polygon with scanlines, pixel is filled when its center lies inside polygon
+func (c *pngCanvas) polygon(xs []float64, ys []float64, fill color.RGBA) {
+	if len(xs) < 3 {
+		return
+	}
+
+	top, bottom := ys[0], ys[0]
+	for _, y := range ys {
+		top, bottom = math.Min(top, y), math.Max(bottom, y)
+	}
+
+	for py := int(math.Floor(top)); py <= int(math.Ceil(bottom)); py++ {
+		center := float64(py) + 0.5
+
+		crossings := make([]float64, 0)
+		for i := range xs {
+			j := (i + 1) % len(xs)
+			if (ys[i] <= center && center < ys[j]) || (ys[j] <= center && center < ys[i]) {
+				crossings = append(crossings, xs[i]+(center-ys[i])/(ys[j]-ys[i])*(xs[j]-xs[i]))
+			}
+		}
+		sort.Float64s(crossings)
+
+		for i := 0; i+1 < len(crossings); i += 2 {
+			for px := round(crossings[i]); px < round(crossings[i+1]); px++ {
+				c.image.SetRGBA(px, py, fill)
+			}
+		}
+	}
+}
+
+func (c *pngCanvas) text(x float64, y float64, value string, anchor string, fill color.RGBA) {
+	value = strings.ToUpper(value)
+	width := float64(len([]rune(value))*glyphAdvance - glyphScale)
+
+	left := x
+	switch anchor {
+	case anchorMiddle:
+		left = x - width/2
+	case anchorEnd:
+		left = x - width
+	}
+	top := round(y) - glyphHeight*glyphScale
+
+	for i, char := range []rune(value) {
+		rows := glyph(char)
+		for row := range rows {
+			for column, dot := range rows[row] {
+				if dot == '1' {
+					px := round(left) + i*glyphAdvance + column*glyphScale
+					py := top + row*glyphScale
+					for sy := 0; sy < glyphScale; sy++ {
+						for sx := 0; sx < glyphScale; sx++ {
+							c.image.SetRGBA(px+sx, py+sy, fill)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func round(value float64) int {
+	return int(math.Round(value))
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+func sign(value int) int {
+	if value < 0 {
+		return -1
+	}
+	if value > 0 {
+		return 1
+	}
+	return 0
+}
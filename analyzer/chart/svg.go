@@ -0,0 +1,56 @@
+package chart
+
+import (
+	"fmt"
+	"html"
+	"image/color"
+	"strings"
+)
+
+type svgCanvas struct {
+	builder strings.Builder
+}
+
+// Renders chart as SVG image of given size
+func RenderSvg(chart Chart, width int, height int) (string, error) {
+	c := &svgCanvas{}
+	fmt.Fprintf(&c.builder, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`,
+		width, height, width, height)
+	c.builder.WriteString("\n")
+
+	if err := draw(chart, c, width, height); err != nil {
+		return "", err
+	}
+
+	c.builder.WriteString("</svg>\n")
+	return c.builder.String(), nil
+}
+
+func (c *svgCanvas) rect(x float64, y float64, width float64, height float64, fill color.RGBA) {
+	fmt.Fprintf(&c.builder, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`+"\n", x, y, width, height, hex(fill))
+}
+
+func (c *svgCanvas) line(x1 float64, y1 float64, x2 float64, y2 float64, stroke color.RGBA) {
+	fmt.Fprintf(&c.builder, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s"/>`+"\n", x1, y1, x2, y2, hex(stroke))
+}
+
+func (c *svgCanvas) circle(x float64, y float64, radius float64, fill color.RGBA) {
+	fmt.Fprintf(&c.builder, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="%s"/>`+"\n", x, y, radius, hex(fill))
+}
+
+func (c *svgCanvas) polygon(xs []float64, ys []float64, fill color.RGBA) {
+	points := make([]string, len(xs))
+	for i := range xs {
+		points[i] = fmt.Sprintf("%.1f,%.1f", xs[i], ys[i])
+	}
+	fmt.Fprintf(&c.builder, `<polygon points="%s" fill="%s"/>`+"\n", strings.Join(points, " "), hex(fill))
+}
+
+func (c *svgCanvas) text(x float64, y float64, value string, anchor string, fill color.RGBA) {
+	fmt.Fprintf(&c.builder, `<text x="%.1f" y="%.1f" text-anchor="%s" fill="%s">%s</text>`+"\n",
+		x, y, anchor, hex(fill), html.EscapeString(value))
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
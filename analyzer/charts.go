@@ -0,0 +1,223 @@
+package analyzer
+
+import (
+	"fmt"
+	"github.com/VirtusLab/jira-stats/analyzer/chart"
+	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reports which can be drawn as charts
+var ChartReports = []string{ReportDevTime, ReportThroughput, ReportCumulativeFlow, ReportFlowEfficiency}
+
+// Builds chart out of report data, only some of the reports can be drawn
+func ReportChart(name string, csv *domain.CsvContents, workflow domain.Workflow) (chart.Chart, error) {
+	switch name {
+	case ReportDevTime:
+		return devTimeChart(csv)
+	case ReportThroughput:
+		return throughputChart(csv)
+	case ReportCumulativeFlow:
+		return cumulativeFlowChart(csv, workflow)
+	case ReportFlowEfficiency:
+		return cycleTimeChart(csv)
+	default:
+		return chart.Chart{}, fmt.Errorf("report [%s] can not be drawn as chart", name)
+	}
+}
+
+// Dev days summed per project
+func devTimeChart(csv *domain.CsvContents) (chart.Chart, error) {
+	columns, err := csvColumns(csv, "Project", "Dev Time (days)")
+	if err != nil {
+		return chart.Chart{}, err
+	}
+
+	days := make(map[string]float64)
+	for _, row := range csv.Rows {
+		value, err := strconv.ParseFloat(row.Entries[columns[1]], 64)
+		if err != nil {
+			return chart.Chart{}, err
+		}
+		days[row.Entries[columns[0]]] += value
+	}
+
+	projects := make([]string, 0, len(days))
+	for project := range days {
+		projects = append(projects, project)
+	}
+	sort.Strings(projects)
+
+	values := make([]float64, len(projects))
+	for i, project := range projects {
+		values[i] = days[project]
+	}
+
+	return chart.Chart{
+		Kind:       chart.KindBar,
+		Title:      "Dev days per project",
+		YLabel:     "days",
+		Categories: projects,
+		Series:     []chart.Series{{Name: "Dev days", Values: values}},
+	}, nil
+}
+
+// Created and done tickets per period, summed over projects and types
+func throughputChart(csv *domain.CsvContents) (chart.Chart, error) {
+	columns, err := csvColumns(csv, "Period", "Created", "Done")
+	if err != nil {
+		return chart.Chart{}, err
+	}
+
+	periods := make([]string, 0)
+	created := make([]float64, 0)
+	done := make([]float64, 0)
+	for _, row := range csv.Rows {
+		period := row.Entries[columns[0]]
+		if len(periods) == 0 || periods[len(periods)-1] != period {
+			periods = append(periods, period)
+			created = append(created, 0)
+			done = append(done, 0)
+		}
+
+		for i, values := range [][]float64{created, done} {
+			value, err := strconv.ParseFloat(row.Entries[columns[i+1]], 64)
+			if err != nil {
+				return chart.Chart{}, err
+			}
+			values[len(values)-1] += value
+		}
+	}
+
+	return chart.Chart{
+		Kind:       chart.KindBar,
+		Title:      "Throughput",
+		YLabel:     "tickets",
+		Categories: periods,
+		Series:     []chart.Series{{Name: "Created", Values: created}, {Name: "Done", Values: done}},
+	}, nil
+}
+
+// Tickets in every status stacked over time, statuses later in the workflow are drawn at the bottom
+func cumulativeFlowChart(csv *domain.CsvContents, workflow domain.Workflow) (chart.Chart, error) {
+	columns, err := csvColumns(csv, "Date", "Status", "Count")
+	if err != nil {
+		return chart.Chart{}, err
+	}
+
+	dates := make([]string, 0)
+	counts := make(map[string][]float64)
+	for _, row := range csv.Rows {
+		date, status := row.Entries[columns[0]], row.Entries[columns[1]]
+		if len(dates) == 0 || dates[len(dates)-1] != date {
+			dates = append(dates, date)
+		}
+
+		value, err := strconv.ParseFloat(row.Entries[columns[2]], 64)
+		if err != nil {
+			return chart.Chart{}, err
+		}
+		for len(counts[status]) < len(dates)-1 {
+			counts[status] = append(counts[status], 0)
+		}
+		counts[status] = append(counts[status], value)
+	}
+
+	statuses := make([]string, 0, len(counts))
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		first, second := statusPosition(workflow, statuses[i]), statusPosition(workflow, statuses[j])
+		if first != second {
+			return first > second
+		}
+		return statuses[i] < statuses[j]
+	})
+
+	series := make([]chart.Series, len(statuses))
+	for i, status := range statuses {
+		for len(counts[status]) < len(dates) {
+			counts[status] = append(counts[status], 0)
+		}
+		series[i] = chart.Series{Name: status, Values: counts[status]}
+	}
+
+	return chart.Chart{
+		Kind:       chart.KindArea,
+		Title:      "Cumulative flow",
+		YLabel:     "tickets",
+		Categories: dates,
+		Series:     series,
+	}, nil
+}
+
+// Cycle time of done tickets by their end date with 50th and 85th percentile
+func cycleTimeChart(csv *domain.CsvContents) (chart.Chart, error) {
+	columns, err := csvColumns(csv, "Start", "End")
+	if err != nil {
+		return chart.Chart{}, err
+	}
+
+	points := make([]chart.Point, 0)
+	values := make([]float64, 0)
+	for _, row := range csv.Rows {
+		if row.Entries[columns[0]] == "" || row.Entries[columns[1]] == "" {
+			continue
+		}
+
+		start, err := time.Parse(domain.DayFormat, row.Entries[columns[0]])
+		if err != nil {
+			return chart.Chart{}, err
+		}
+		end, err := time.Parse(domain.DayFormat, row.Entries[columns[1]])
+		if err != nil {
+			return chart.Chart{}, err
+		}
+
+		days := end.Sub(start).Hours() / 24
+		points = append(points, chart.Point{Date: end, Value: days})
+		values = append(values, days)
+	}
+
+	return chart.Chart{
+		Kind:   chart.KindScatter,
+		Title:  "Cycle time",
+		YLabel: "days",
+		Points: points,
+		Markers: []chart.Marker{
+			{Name: "50%", Value: domain.Percentile(values, 50)},
+			{Name: "85%", Value: domain.Percentile(values, 85)},
+		},
+	}, nil
+}
+
+// Finds indexes of given columns in CSV header
+func csvColumns(csv *domain.CsvContents, names ...string) ([]int, error) {
+	indexes := make([]int, len(names))
+	for i, name := range names {
+		indexes[i] = -1
+		for j, header := range csv.Header {
+			if header == name {
+				indexes[i] = j
+			}
+		}
+		if indexes[i] < 0 {
+			return nil, fmt.Errorf("report has no [%s] column", name)
+		}
+	}
+	return indexes, nil
+}
+
+// Position of status in workflow status order, -1 for unknown statuses so that they are drawn on top
+func statusPosition(workflow domain.Workflow, status string) int {
+	for i, ordered := range workflow.StatusOrder {
+		if strings.EqualFold(ordered, status) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,83 @@
+package analyzer
+
+import (
+	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"os"
+	"strings"
+)
+
+// Reads Jira deployment (JIRA_DEPLOYMENT - server or cloud) and custom fields from env vars
+func jiraConfig() domain.JiraConfig {
+	config := domain.DefaultJiraConfig
+	if os.Getenv("JIRA_DEPLOYMENT") != "" {
+		config.Deployment = strings.ToLower(os.Getenv("JIRA_DEPLOYMENT"))
+	}
+	config.Fields = customFields()
+
+	return config
+}
+
+// Reads Jira address from env var, falls back to default if not set
+func jiraUrl() string {
+	if os.Getenv("JIRA_URL") != "" {
+		return os.Getenv("JIRA_URL")
+	}
+	return JiraUrl
+}
+
+// Reads Jira custom field ids from env vars, falls back to defaults if not set
+func customFields() domain.CustomFields {
+	fields := domain.DefaultCustomFields
+
+	if os.Getenv("JIRA_EPIC_LINK_FIELD") != "" {
+		fields.EpicLink = os.Getenv("JIRA_EPIC_LINK_FIELD")
+	}
+
+	if os.Getenv("JIRA_SPRINT_FIELD") != "" {
+		fields.Sprint = os.Getenv("JIRA_SPRINT_FIELD")
+	}
+
+	if os.Getenv("JIRA_STORY_POINTS_FIELD") != "" {
+		fields.StoryPoints = os.Getenv("JIRA_STORY_POINTS_FIELD")
+	}
+
+	return fields
+}
+
+// Reads team workflow from env vars, falls back to defaults if not set
+func workflow() domain.Workflow {
+	flow := domain.DefaultWorkflow
+
+	if os.Getenv("JIRA_DONE_STATES") != "" {
+		flow.DoneStates = splitList(os.Getenv("JIRA_DONE_STATES"))
+	}
+
+	if os.Getenv("JIRA_ACTIVE_STATES") != "" {
+		flow.ActiveStates = splitList(os.Getenv("JIRA_ACTIVE_STATES"))
+	}
+
+	if os.Getenv("JIRA_WAITING_STATES") != "" {
+		flow.WaitingStates = splitList(os.Getenv("JIRA_WAITING_STATES"))
+	}
+
+	if os.Getenv("JIRA_STATUS_ORDER") != "" {
+		flow.StatusOrder = splitList(os.Getenv("JIRA_STATUS_ORDER"))
+	}
+
+	if os.Getenv("JIRA_BLOCKED_STATES") != "" {
+		flow.BlockedStates = splitList(os.Getenv("JIRA_BLOCKED_STATES"))
+	}
+
+	return flow
+}
+
+// Splits comma separated list, trimming the entries
+func splitList(value string) []string {
+	entries := make([]string, 0)
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) != "" {
+			entries = append(entries, strings.TrimSpace(entry))
+		}
+	}
+	return entries
+}
@@ -1,43 +1,719 @@
 package analyzer
 
 import (
+	"context"
+	"fmt"
 	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/VirtusLab/jira-stats/analyzer/telemetry"
 	"github.com/ztrue/tracerr"
-	"log"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
-// Generates CSV contents from DB
-func GetCsv(startDate time.Time, endDate time.Time) (*domain.CsvContents, error) {
-	log.Printf("Fetching tickets for dev time between (%s, %s)\n", startDate.Format(time.RFC3339), endDate.Format(time.RFC3339))
+// Generates CSV contents from DB, sub-tasks are treated according to given mode, flagged time is optionally
+// not counted as dev time
+func GetCsv(ctx context.Context, startDate time.Time, endDate time.Time, mode string, subtractBlocked bool) (*domain.CsvContents, error) {
+	telemetry.Infof(ctx, "Fetching tickets for dev time between (%s, %s)", startDate.Format(time.RFC3339), endDate.Format(time.RFC3339))
 
-	ticketsWithDevBefore, err := fetchTicketsWithDevStartTimeBefore(startDate, endDate)
+	ticketsWithDevBefore, err := fetchTicketsWithDevStartTimeBefore(ctx, startDate, endDate)
 	if err != nil {
 		return &domain.CsvContents{}, tracerr.Wrap(err)
 	}
-	log.Printf("Fetched %d tickets...\n", len(ticketsWithDevBefore))
+	telemetry.Infof(ctx, "Fetched %d tickets...", len(ticketsWithDevBefore))
+
+	if mode == domain.ModeRollUp || mode == domain.ModeUnion {
+		ticketsWithDevBefore, err = withParents(ctx, ticketsWithDevBefore)
+		if err != nil {
+			return &domain.CsvContents{}, tracerr.Wrap(err)
+		}
+	}
+
+	calculator := domain.DaysCalculator{SubtractBlocked: subtractBlocked}
+	devTimes, err := domain.CalculateDevTimes(calculator, ticketsWithDevBefore, mode, startDate, endDate)
+	if err != nil {
+		return &domain.CsvContents{}, tracerr.Wrap(err)
+	}
+
+	rows := make([]domain.CsvRow, 0)
+
+	for _, devTime := range devTimes {
+		ticket := devTime.Ticket
+		rows = append(rows, domain.CsvRow{
+			Entries: []string{
+				ticket.Key, ticket.Type, csvEscape(ticket.Title), ticket.Project(),
+				strconv.FormatFloat(devTime.DevDays, 'f', 2, 64),
+			},
+		})
+	}
+
+	return &domain.CsvContents{
+		Header:  []string{"Key", "Type", "Summary", "Project", "Dev Time (days)"},
+		Rows:    rows,
+		Numeric: []string{"Dev Time (days)"},
+	}, nil
+}
+
+// Adds parents of sub-tasks which are missing in given tickets
+func withParents(ctx context.Context, tickets []domain.Ticket) ([]domain.Ticket, error) {
+	keys := make(map[string]bool)
+	for _, ticket := range tickets {
+		keys[ticket.Key] = true
+	}
+
+	missing := make(map[string]bool)
+	for _, ticket := range tickets {
+		if ticket.ParentKey != "" && !keys[ticket.ParentKey] {
+			missing[ticket.ParentKey] = true
+		}
+	}
+
+	if len(missing) == 0 {
+		return tickets, nil
+	}
+
+	allTickets, err := fetchAllTickets(ctx)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+
+	for _, ticket := range allTickets {
+		if missing[ticket.Key] {
+			tickets = append(tickets, ticket)
+		}
+	}
+
+	return tickets, nil
+}
+
+// Generates CSV with epics and dev time of their children
+func GetEpicCsv(ctx context.Context, startDate time.Time, endDate time.Time) (*domain.CsvContents, error) {
+	telemetry.Infof(ctx, "Fetching tickets for epic dev time between (%s, %s)", startDate.Format(time.RFC3339), endDate.Format(time.RFC3339))
+
+	tickets, err := fetchAllTickets(ctx)
+	if err != nil {
+		return &domain.CsvContents{}, tracerr.Wrap(err)
+	}
+	telemetry.Infof(ctx, "Fetched %d tickets...", len(tickets))
+
+	rows := make([]domain.CsvRow, 0)
+
+	for _, epic := range domain.SummarizeEpics(domain.DaysCalculator{}, tickets, tenantOf(ctx).Workflow, startDate, endDate) {
+		rows = append(rows, domain.CsvRow{
+			Entries: []string{
+				epic.Key, csvEscape(epic.Title), epic.Project(), epic.State,
+				strconv.Itoa(epic.ChildCount), csvEscape(formatCounts(epic.ChildrenByState)),
+				strconv.FormatFloat(epic.DevDays, 'f', 2, 64),
+				strconv.FormatFloat(epic.DevDaysTotal, 'f', 2, 64),
+				formatDay(epic.StartDate), formatDay(epic.EndDate),
+			},
+		})
+	}
+
+	return &domain.CsvContents{
+		Header: []string{"Epic", "Summary", "Project", "State", "Children", "Children by State",
+			"Dev Time (days)", "Total Dev Time (days)", "Start", "End"},
+		Rows:    rows,
+		Numeric: []string{"Children", "Dev Time (days)", "Total Dev Time (days)"},
+	}, nil
+}
+
+// Generates CSV with sprints summary, either for given sprint or sprints overlapping with given dates
+func GetSprintCsv(ctx context.Context, startDate time.Time, endDate time.Time, sprintId int) (*domain.CsvContents, error) {
+	telemetry.Infof(ctx, "Fetching sprints between (%s, %s)", startDate.Format(time.RFC3339), endDate.Format(time.RFC3339))
+
+	sprints, err := fetchAllSprints(ctx)
+	if err != nil {
+		return &domain.CsvContents{}, tracerr.Wrap(err)
+	}
+
+	tickets, err := fetchAllTickets(ctx)
+	if err != nil {
+		return &domain.CsvContents{}, tracerr.Wrap(err)
+	}
+	telemetry.Infof(ctx, "Fetched %d sprints and %d tickets...", len(sprints), len(tickets))
+
+	sort.Slice(sprints, func(i, j int) bool {
+		return sprints[i].StartDate.Before(sprints[j].StartDate)
+	})
 
 	rows := make([]domain.CsvRow, 0)
 
-	calculator := domain.DaysCalculator{}
+	for _, sprint := range sprints {
+		if sprintId != 0 && sprint.Id != sprintId {
+			continue
+		}
+		if sprintId == 0 && (sprint.StartDate.After(endDate) || sprint.FinishDate().Before(startDate)) {
+			continue
+		}
 
-	for _, ticket := range ticketsWithDevBefore {
+		summary := domain.SummarizeSprint(domain.DaysCalculator{}, sprint, tickets, tenantOf(ctx).Workflow)
+		rows = append(rows, domain.CsvRow{
+			Entries: []string{
+				strconv.Itoa(sprint.Id), csvEscape(sprint.Name), sprint.State,
+				formatDay(sprint.StartDate), formatDay(sprint.FinishDate()),
+				strconv.Itoa(len(summary.Committed)), strconv.Itoa(len(summary.Completed)),
+				strconv.Itoa(len(summary.Added)), strconv.Itoa(len(summary.Removed)),
+				strconv.Itoa(len(summary.CarriedOver)),
+				strconv.FormatFloat(summary.DevDays, 'f', 2, 64),
+				strings.Join(summary.CarriedOver, " "),
+			},
+		})
+	}
+
+	return &domain.CsvContents{
+		Header: []string{"Sprint", "Name", "State", "Start", "End", "Committed", "Completed",
+			"Added", "Removed", "Carried Over", "Dev Time (days)", "Carried Over Tickets"},
+		Rows:    rows,
+		Numeric: []string{"Sprint", "Committed", "Completed", "Added", "Removed", "Carried Over", "Dev Time (days)"},
+	}, nil
+}
+
+// Generates CSV comparing dev time of tickets finished within given dates with their estimates
+func GetEstimatesCsv(ctx context.Context, startDate time.Time, endDate time.Time) (*domain.CsvContents, error) {
+	accuracies, err := estimateAccuracies(ctx, startDate, endDate)
+	if err != nil {
+		return &domain.CsvContents{}, tracerr.Wrap(err)
+	}
+
+	rows := make([]domain.CsvRow, 0)
+
+	for _, accuracy := range accuracies {
+		ticket := accuracy.Ticket
 		rows = append(rows, domain.CsvRow{
 			Entries: []string{
 				ticket.Key, ticket.Type, csvEscape(ticket.Title), ticket.Project(),
-				strconv.FormatFloat(calculator.CalculateDevDays(ticket, startDate, endDate), 'f', 2, 64),
+				strconv.FormatFloat(ticket.StoryPoints, 'f', -1, 64),
+				strconv.FormatFloat(accuracy.EstimateDays, 'f', 2, 64),
+				strconv.FormatFloat(accuracy.DevDays, 'f', 2, 64),
+				strconv.FormatFloat(accuracy.DaysPerPoint, 'f', 2, 64),
+				strconv.FormatFloat(accuracy.EstimateRatio, 'f', 2, 64),
+				strconv.FormatBool(accuracy.Outlier),
 			},
 		})
 	}
 
 	return &domain.CsvContents{
-		Header: []string{"Key", "Type", "Summary", "Project", "Dev Time (days)"},
-		Rows:   rows,
+		Header: []string{"Key", "Type", "Summary", "Project", "Story Points", "Original Estimate (days)",
+			"Dev Time (days)", "Days per Point", "Dev Time to Estimate", "Outlier"},
+		Rows:    rows,
+		Numeric: []string{"Story Points", "Original Estimate (days)", "Dev Time (days)", "Days per Point", "Dev Time to Estimate"},
 	}, nil
 }
 
+// Generates CSV with estimation accuracy per project
+func GetEstimateTeamsCsv(ctx context.Context, startDate time.Time, endDate time.Time) (*domain.CsvContents, error) {
+	accuracies, err := estimateAccuracies(ctx, startDate, endDate)
+	if err != nil {
+		return &domain.CsvContents{}, tracerr.Wrap(err)
+	}
+
+	rows := make([]domain.CsvRow, 0)
+
+	for _, team := range domain.SummarizeTeams(accuracies) {
+		rows = append(rows, domain.CsvRow{
+			Entries: []string{
+				team.Project, strconv.Itoa(team.Tickets),
+				strconv.FormatFloat(team.Points, 'f', -1, 64),
+				strconv.FormatFloat(team.DaysPerPoint(), 'f', 2, 64),
+				strconv.FormatFloat(team.EstimateDays, 'f', 2, 64),
+				strconv.FormatFloat(team.EstimateRatio(), 'f', 2, 64),
+			},
+		})
+	}
+
+	return &domain.CsvContents{
+		Header:  []string{"Project", "Tickets", "Story Points", "Days per Point", "Original Estimate (days)", "Dev Time to Estimate"},
+		Rows:    rows,
+		Numeric: []string{"Tickets", "Story Points", "Days per Point", "Original Estimate (days)", "Dev Time to Estimate"},
+	}, nil
+}
+
+// Generates CSV with distribution of dev time for each story points value
+func GetEstimatePointsCsv(ctx context.Context, startDate time.Time, endDate time.Time) (*domain.CsvContents, error) {
+	accuracies, err := estimateAccuracies(ctx, startDate, endDate)
+	if err != nil {
+		return &domain.CsvContents{}, tracerr.Wrap(err)
+	}
+
+	rows := make([]domain.CsvRow, 0)
+
+	for _, distribution := range domain.DistributeByPoints(accuracies) {
+		rows = append(rows, domain.CsvRow{
+			Entries: []string{
+				strconv.FormatFloat(distribution.Points, 'f', -1, 64), strconv.Itoa(distribution.Count),
+				strconv.FormatFloat(distribution.Min, 'f', 2, 64),
+				strconv.FormatFloat(distribution.Q1, 'f', 2, 64),
+				strconv.FormatFloat(distribution.Median, 'f', 2, 64),
+				strconv.FormatFloat(distribution.Q3, 'f', 2, 64),
+				strconv.FormatFloat(distribution.Max, 'f', 2, 64),
+				strconv.FormatFloat(distribution.Mean, 'f', 2, 64),
+			},
+		})
+	}
+
+	return &domain.CsvContents{
+		Header:  []string{"Story Points", "Tickets", "Min (days)", "Q1 (days)", "Median (days)", "Q3 (days)", "Max (days)", "Mean (days)"},
+		Rows:    rows,
+		Numeric: []string{"Story Points", "Tickets", "Min (days)", "Q1 (days)", "Median (days)", "Q3 (days)", "Max (days)", "Mean (days)"},
+	}, nil
+}
+
+// Estimation accuracy of tickets which are done and left development within given dates
+func estimateAccuracies(ctx context.Context, startDate time.Time, endDate time.Time) ([]domain.EstimateAccuracy, error) {
+	telemetry.Infof(ctx, "Fetching tickets for estimates between (%s, %s)", startDate.Format(time.RFC3339), endDate.Format(time.RFC3339))
+
+	tickets, err := fetchTicketsWithDevStartTimeBefore(ctx, startDate, endDate)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	telemetry.Infof(ctx, "Fetched %d tickets...", len(tickets))
+
+	flow := tenantOf(ctx).Workflow
+	finished := make([]domain.Ticket, 0)
+	for _, ticket := range tickets {
+		if flow.IsDone(ticket.State) && ticket.DevEndDate >= startDate.Unix() && ticket.DevEndDate <= endDate.Unix() {
+			finished = append(finished, ticket)
+		}
+	}
+
+	return domain.CalculateEstimateAccuracy(domain.DaysCalculator{}, finished), nil
+}
+
+// Generates CSV comparing logged work with dev time inferred from transitions per ticket
+func GetWorklogsCsv(ctx context.Context, startDate time.Time, endDate time.Time) (*domain.CsvContents, error) {
+	telemetry.Infof(ctx, "Fetching tickets for worklogs between (%s, %s)", startDate.Format(time.RFC3339), endDate.Format(time.RFC3339))
+
+	tickets, err := fetchAllTickets(ctx)
+	if err != nil {
+		return &domain.CsvContents{}, tracerr.Wrap(err)
+	}
+	telemetry.Infof(ctx, "Fetched %d tickets...", len(tickets))
+
+	rows := make([]domain.CsvRow, 0)
+
+	for _, work := range domain.CompareTicketWork(domain.DaysCalculator{}, tickets, startDate, endDate) {
+		ticket := work.Ticket
+		rows = append(rows, domain.CsvRow{
+			Entries: []string{
+				ticket.Key, ticket.Type, csvEscape(ticket.Title), ticket.Project(),
+				strconv.FormatFloat(work.LoggedHours, 'f', 2, 64),
+				strconv.FormatFloat(work.InferredHours, 'f', 2, 64),
+				strconv.FormatFloat(work.Difference(), 'f', 2, 64),
+				strconv.FormatBool(work.Discrepancy()),
+			},
+		})
+	}
+
+	return &domain.CsvContents{
+		Header:  []string{"Key", "Type", "Summary", "Project", "Logged (hours)", "Dev Time (hours)", "Difference (hours)", "Discrepancy"},
+		Rows:    rows,
+		Numeric: []string{"Logged (hours)", "Dev Time (hours)", "Difference (hours)"},
+	}, nil
+}
+
+// Generates CSV comparing logged work with dev time inferred from transitions per person
+func GetWorklogPeopleCsv(ctx context.Context, startDate time.Time, endDate time.Time) (*domain.CsvContents, error) {
+	telemetry.Infof(ctx, "Fetching tickets for worklogs between (%s, %s)", startDate.Format(time.RFC3339), endDate.Format(time.RFC3339))
+
+	tickets, err := fetchAllTickets(ctx)
+	if err != nil {
+		return &domain.CsvContents{}, tracerr.Wrap(err)
+	}
+	telemetry.Infof(ctx, "Fetched %d tickets...", len(tickets))
+
+	rows := make([]domain.CsvRow, 0)
+	names := domain.AuthorNames(tickets)
+
+	for _, work := range domain.ComparePersonWork(domain.DaysCalculator{}, tickets, startDate, endDate) {
+		rows = append(rows, domain.CsvRow{
+			Entries: []string{
+				csvEscape(domain.DisplayName(names, work.Person)),
+				strconv.FormatFloat(work.LoggedHours, 'f', 2, 64),
+				strconv.FormatFloat(work.InferredHours, 'f', 2, 64),
+				strconv.FormatFloat(work.Difference(), 'f', 2, 64),
+				strconv.FormatBool(work.Discrepancy()),
+			},
+		})
+	}
+
+	return &domain.CsvContents{
+		Header:  []string{"Person", "Logged (hours)", "Dev Time (hours)", "Difference (hours)", "Discrepancy"},
+		Rows:    rows,
+		Numeric: []string{"Logged (hours)", "Dev Time (hours)", "Difference (hours)"},
+	}, nil
+}
+
+// Generates CSV with flow efficiency of tickets done within given dates
+func GetFlowEfficiencyCsv(ctx context.Context, startDate time.Time, endDate time.Time) (*domain.CsvContents, error) {
+	efficiencies, err := flowEfficiencies(ctx, startDate, endDate)
+	if err != nil {
+		return &domain.CsvContents{}, tracerr.Wrap(err)
+	}
+
+	rows := make([]domain.CsvRow, 0)
+
+	for _, efficiency := range efficiencies {
+		ticket := efficiency.Ticket
+		rows = append(rows, domain.CsvRow{
+			Entries: []string{
+				ticket.Key, ticket.Type, csvEscape(ticket.Title), ticket.Project(),
+				formatDay(efficiency.Start), formatDay(efficiency.End),
+				strconv.FormatFloat(efficiency.ActiveHours, 'f', 2, 64),
+				strconv.FormatFloat(efficiency.WaitingHours, 'f', 2, 64),
+				strconv.FormatFloat(efficiency.Efficiency(), 'f', 2, 64),
+			},
+		})
+	}
+
+	return &domain.CsvContents{
+		Header:  []string{"Key", "Type", "Summary", "Project", "Start", "End", "Active (hours)", "Waiting (hours)", "Flow Efficiency"},
+		Rows:    rows,
+		Numeric: []string{"Active (hours)", "Waiting (hours)", "Flow Efficiency"},
+	}, nil
+}
+
+// Generates CSV with flow efficiency of tickets done within given dates, aggregated by given dimensions
+func GetFlowEfficiencySummaryCsv(ctx context.Context, startDate time.Time, endDate time.Time, groupBy []string) (*domain.CsvContents, error) {
+	efficiencies, err := flowEfficiencies(ctx, startDate, endDate)
+	if err != nil {
+		return &domain.CsvContents{}, tracerr.Wrap(err)
+	}
+
+	groups, err := domain.AggregateFlowEfficiency(efficiencies, groupBy)
+	if err != nil {
+		return &domain.CsvContents{}, tracerr.Wrap(err)
+	}
+
+	rows := make([]domain.CsvRow, 0)
+
+	for _, group := range groups {
+		rows = append(rows, domain.CsvRow{
+			Entries: append(escapeAll(group.Keys),
+				strconv.Itoa(group.Tickets),
+				strconv.FormatFloat(group.ActiveHours, 'f', 2, 64),
+				strconv.FormatFloat(group.WaitingHours, 'f', 2, 64),
+				strconv.FormatFloat(group.Efficiency(), 'f', 2, 64),
+			),
+		})
+	}
+
+	return &domain.CsvContents{
+		Header:  append(titleAll(groupBy), "Tickets", "Active (hours)", "Waiting (hours)", "Flow Efficiency"),
+		Rows:    rows,
+		Numeric: []string{"Tickets", "Active (hours)", "Waiting (hours)", "Flow Efficiency"},
+	}, nil
+}
+
+func flowEfficiencies(ctx context.Context, startDate time.Time, endDate time.Time) ([]domain.FlowEfficiency, error) {
+	telemetry.Infof(ctx, "Fetching tickets for flow efficiency between (%s, %s)", startDate.Format(time.RFC3339), endDate.Format(time.RFC3339))
+
+	tickets, err := fetchAllTickets(ctx)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	telemetry.Infof(ctx, "Fetched %d tickets...", len(tickets))
+
+	return domain.CalculateFlowEfficiencies(tickets, tenantOf(ctx).Workflow, startDate, endDate), nil
+}
+
+func escapeAll(values []string) []string {
+	escaped := make([]string, 0, len(values))
+	for _, value := range values {
+		escaped = append(escaped, csvEscape(value))
+	}
+	return escaped
+}
+
+func titleAll(values []string) []string {
+	titles := make([]string, 0, len(values))
+	for _, value := range values {
+		if value == "" {
+			titles = append(titles, value)
+			continue
+		}
+		first, size := utf8.DecodeRuneInString(value)
+		titles = append(titles, string(unicode.ToUpper(first))+value[size:])
+	}
+	return titles
+}
+
+// Generates CSV with number of tickets in each status for every period (hour or day) between given dates
+func GetCumulativeFlowCsv(ctx context.Context, startDate time.Time, endDate time.Time, period string, scope domain.Scope) (*domain.CsvContents, error) {
+	telemetry.Infof(ctx, "Fetching tickets for cumulative flow between (%s, %s)", startDate.Format(time.RFC3339), endDate.Format(time.RFC3339))
+
+	tickets, err := fetchAllTickets(ctx)
+	if err != nil {
+		return &domain.CsvContents{}, tracerr.Wrap(err)
+	}
+	telemetry.Infof(ctx, "Fetched %d tickets...", len(tickets))
+
+	counts, err := domain.CumulativeFlow(scope.Filter(tickets), startDate, endDate, period)
+	if err != nil {
+		return &domain.CsvContents{}, tracerr.Wrap(err)
+	}
+
+	dateFormat := domain.DayFormat
+	if period == domain.PeriodHour {
+		dateFormat = time.RFC3339
+	}
+
+	rows := make([]domain.CsvRow, 0)
+
+	for _, count := range counts {
+		rows = append(rows, domain.CsvRow{
+			Entries: []string{count.Date.Format(dateFormat), csvEscape(count.Status), strconv.Itoa(count.Count)},
+		})
+	}
+
+	return &domain.CsvContents{
+		Header:  []string{"Date", "Status", "Count"},
+		Rows:    rows,
+		Numeric: []string{"Count"},
+	}, nil
+}
+
+// Generates CSV with number of tickets created, started and done in every period (week or month) between given dates
+func GetThroughputCsv(ctx context.Context, startDate time.Time, endDate time.Time, period string, scope domain.Scope) (*domain.CsvContents, error) {
+	telemetry.Infof(ctx, "Fetching tickets for throughput between (%s, %s)", startDate.Format(time.RFC3339), endDate.Format(time.RFC3339))
+
+	tickets, err := fetchAllTickets(ctx)
+	if err != nil {
+		return &domain.CsvContents{}, tracerr.Wrap(err)
+	}
+	telemetry.Infof(ctx, "Fetched %d tickets...", len(tickets))
+
+	throughputs, err := domain.CalculateThroughput(scope.Filter(tickets), tenantOf(ctx).Workflow, startDate, endDate, period)
+	if err != nil {
+		return &domain.CsvContents{}, tracerr.Wrap(err)
+	}
+
+	rows := make([]domain.CsvRow, 0)
+
+	for _, throughput := range throughputs {
+		rows = append(rows, domain.CsvRow{
+			Entries: []string{
+				throughput.Period.Format(domain.DayFormat), throughput.Project, throughput.Type,
+				strconv.Itoa(throughput.Created), strconv.Itoa(throughput.Started),
+				strconv.Itoa(throughput.Done), strconv.Itoa(throughput.NetChange()),
+			},
+		})
+	}
+
+	return &domain.CsvContents{
+		Header:  []string{"Period", "Project", "Type", "Created", "Started", "Done", "Net Backlog Change"},
+		Rows:    rows,
+		Numeric: []string{"Created", "Started", "Done", "Net Backlog Change"},
+	}, nil
+}
+
+// Generates CSV with tickets currently in progress and their age compared with cycle time of tickets done
+// within given dates
+func GetAgingWipCsv(ctx context.Context, startDate time.Time, endDate time.Time, scope domain.Scope) (*domain.CsvContents, error) {
+	telemetry.Infof(ctx, "Fetching tickets for aging WIP with history between (%s, %s)", startDate.Format(time.RFC3339), endDate.Format(time.RFC3339))
+
+	tickets, err := fetchAllTickets(ctx)
+	if err != nil {
+		return &domain.CsvContents{}, tracerr.Wrap(err)
+	}
+	telemetry.Infof(ctx, "Fetched %d tickets...", len(tickets))
+
+	rows := make([]domain.CsvRow, 0)
+
+	for _, aging := range domain.CalculateAgingWip(scope.Filter(tickets), tenantOf(ctx).Workflow, startDate, endDate, reportTime(ctx)) {
+		ticket := aging.Ticket
+		rows = append(rows, domain.CsvRow{
+			Entries: []string{
+				ticket.Key, ticket.Type, csvEscape(ticket.Title), ticket.Project(), ticket.State,
+				strconv.FormatFloat(aging.StateAgeDays, 'f', 2, 64),
+				strconv.FormatFloat(aging.AgeDays, 'f', 2, 64),
+				strconv.FormatFloat(aging.CycleTimeDays, 'f', 2, 64),
+				strconv.FormatBool(aging.Exceeded),
+			},
+		})
+	}
+
+	return &domain.CsvContents{
+		Header: []string{"Key", "Type", "Summary", "Project", "State", "Age in State (days)", "Age (days)",
+			"Cycle Time P85 (days)", "Exceeded"},
+		Rows:    rows,
+		Numeric: []string{"Age in State (days)", "Age (days)", "Cycle Time P85 (days)"},
+	}, nil
+}
+
+// Generates CSV with Monte Carlo forecast based on throughput of tickets done within given dates
+func GetForecastCsv(ctx context.Context, startDate time.Time, endDate time.Time, forecast domain.Forecast, scope domain.Scope) (*domain.CsvContents, error) {
+	telemetry.Infof(ctx, "Fetching tickets for forecast with history between (%s, %s)", startDate.Format(time.RFC3339), endDate.Format(time.RFC3339))
+
+	tickets, err := fetchAllTickets(ctx)
+	if err != nil {
+		return &domain.CsvContents{}, tracerr.Wrap(err)
+	}
+	telemetry.Infof(ctx, "Fetched %d tickets...", len(tickets))
+
+	history := domain.DailyThroughput(scope.Filter(tickets), tenantOf(ctx).Workflow, startDate, endDate)
+
+	rows := make([]domain.CsvRow, 0)
+	addRows := func(question string, results []domain.ForecastResult) {
+		for _, result := range results {
+			rows = append(rows, domain.CsvRow{
+				Entries: []string{
+					question, strconv.Itoa(result.Confidence), strconv.Itoa(result.Items), result.Date.Format(domain.DayFormat),
+				},
+			})
+		}
+	}
+
+	if forecast.TargetDate.After(domain.BeginingOfTime) {
+		results, err := forecast.ItemsByDate(history, reportTime(ctx))
+		if err != nil {
+			return &domain.CsvContents{}, tracerr.Wrap(err)
+		}
+		addRows("Items by date", results)
+	}
+
+	if forecast.Items > 0 {
+		results, err := forecast.DateForItems(history, reportTime(ctx))
+		if err != nil {
+			return &domain.CsvContents{}, tracerr.Wrap(err)
+		}
+		addRows("Date for items", results)
+	}
+
+	if len(rows) == 0 {
+		return &domain.CsvContents{}, fmt.Errorf("either number of items or target date has to be given for forecast")
+	}
+
+	return &domain.CsvContents{
+		Header:  []string{"Question", "Confidence (%)", "Items", "Date"},
+		Rows:    rows,
+		Numeric: []string{"Confidence (%)", "Items"},
+	}, nil
+}
+
+// Generates CSV with tickets moved backwards in the workflow within given dates, most reworked first
+func GetReworkCsv(ctx context.Context, startDate time.Time, endDate time.Time, scope domain.Scope) (*domain.CsvContents, error) {
+	tickets, reworks, err := reworks(ctx, startDate, endDate, scope)
+	if err != nil {
+		return &domain.CsvContents{}, tracerr.Wrap(err)
+	}
+	telemetry.Infof(ctx, "Found %d reworked tickets out of %d...", len(reworks), len(tickets))
+
+	rows := make([]domain.CsvRow, 0)
+
+	for _, rework := range reworks {
+		ticket := rework.Ticket
+		rows = append(rows, domain.CsvRow{
+			Entries: []string{
+				ticket.Key, ticket.Type, csvEscape(ticket.Title), ticket.Project(),
+				strconv.Itoa(rework.BackwardTransitions),
+				strconv.FormatFloat(rework.ExtraDevDays, 'f', 2, 64),
+			},
+		})
+	}
+
+	return &domain.CsvContents{
+		Header:  []string{"Key", "Type", "Summary", "Project", "Backward Transitions", "Extra Dev Time (days)"},
+		Rows:    rows,
+		Numeric: []string{"Backward Transitions", "Extra Dev Time (days)"},
+	}, nil
+}
+
+// Generates CSV with rework per project within given dates, most reworked first
+func GetReworkProjectsCsv(ctx context.Context, startDate time.Time, endDate time.Time, scope domain.Scope) (*domain.CsvContents, error) {
+	tickets, reworks, err := reworks(ctx, startDate, endDate, scope)
+	if err != nil {
+		return &domain.CsvContents{}, tracerr.Wrap(err)
+	}
+
+	rows := make([]domain.CsvRow, 0)
+
+	for _, project := range domain.SummarizeProjectRework(tickets, reworks) {
+		rows = append(rows, domain.CsvRow{
+			Entries: []string{
+				project.Project, strconv.Itoa(project.Tickets), strconv.Itoa(project.ReworkedTickets),
+				strconv.Itoa(project.BackwardTransitions),
+				strconv.FormatFloat(project.ExtraDevDays, 'f', 2, 64),
+			},
+		})
+	}
+
+	return &domain.CsvContents{
+		Header:  []string{"Project", "Tickets", "Reworked Tickets", "Backward Transitions", "Extra Dev Time (days)"},
+		Rows:    rows,
+		Numeric: []string{"Tickets", "Reworked Tickets", "Backward Transitions", "Extra Dev Time (days)"},
+	}, nil
+}
+
+func reworks(ctx context.Context, startDate time.Time, endDate time.Time, scope domain.Scope) ([]domain.Ticket, []domain.Rework, error) {
+	telemetry.Infof(ctx, "Fetching tickets for rework between (%s, %s)", startDate.Format(time.RFC3339), endDate.Format(time.RFC3339))
+
+	tickets, err := fetchTicketsWithDevStartTimeBefore(ctx, startDate, endDate)
+	if err != nil {
+		return nil, nil, tracerr.Wrap(err)
+	}
+	tickets = scope.Filter(tickets)
+
+	return tickets, domain.CalculateRework(domain.DaysCalculator{}, tickets, tenantOf(ctx).Workflow, startDate, endDate), nil
+}
+
+// Generates CSV with time tickets were flagged or in blocked state within given dates, most blocked first
+func GetBlockedCsv(ctx context.Context, startDate time.Time, endDate time.Time, scope domain.Scope) (*domain.CsvContents, error) {
+	telemetry.Infof(ctx, "Fetching tickets for blocked time between (%s, %s)", startDate.Format(time.RFC3339), endDate.Format(time.RFC3339))
+
+	tickets, err := fetchAllTickets(ctx)
+	if err != nil {
+		return &domain.CsvContents{}, tracerr.Wrap(err)
+	}
+	telemetry.Infof(ctx, "Fetched %d tickets...", len(tickets))
+
+	rows := make([]domain.CsvRow, 0)
+
+	for _, blocked := range domain.CalculateBlockedTime(domain.DaysCalculator{}, scope.Filter(tickets), tenantOf(ctx).Workflow, startDate, endDate) {
+		ticket := blocked.Ticket
+		rows = append(rows, domain.CsvRow{
+			Entries: []string{
+				ticket.Key, ticket.Type, csvEscape(ticket.Title), ticket.Project(),
+				strconv.FormatFloat(blocked.BlockedHours, 'f', 2, 64),
+				strconv.Itoa(blocked.Blockers),
+			},
+		})
+	}
+
+	return &domain.CsvContents{
+		Header:  []string{"Key", "Type", "Summary", "Project", "Blocked (hours)", "Blockers"},
+		Rows:    rows,
+		Numeric: []string{"Blocked (hours)", "Blockers"},
+	}, nil
+}
+
+// Formats counts as "key: count" pairs sorted by key
+func formatCounts(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	entries := make([]string, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, fmt.Sprintf("%s: %d", key, counts[key]))
+	}
+
+	return strings.Join(entries, "; ")
+}
+
+// Formats date as day, leaves it empty when date is not set
+func formatDay(date time.Time) string {
+	if date.Unix() <= domain.BeginingOfTime.Unix() || date.Unix() >= domain.EndOfTime.Unix() {
+		return ""
+	}
+	return date.Format(domain.DayFormat)
+}
+
 func csvEscape(str string) string {
 	return strings.ReplaceAll(str, ",", " ")
 }
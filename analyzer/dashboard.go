@@ -0,0 +1,201 @@
+package analyzer
+
+import (
+	"bytes"
+	"github.com/ztrue/tracerr"
+	"html/template"
+)
+
+// Reports listed in dashboard, in order they are offered
+var Reports = []string{
+	ReportDevTime, ReportEpics, ReportSprints, ReportEstimates, ReportEstimateTeams, ReportEstimatePoints,
+	ReportWorklogs, ReportWorklogPeople, ReportFlowEfficiency, ReportFlowEfficiencySummary, ReportCumulativeFlow,
+	ReportThroughput, ReportAgingWip, ReportForecast, ReportRework, ReportReworkProjects, ReportBlocked,
+}
+
+// Reports limiting tickets by project and type params, filters are disabled in dashboard for others
+var ScopedReports = []string{
+	ReportCumulativeFlow, ReportThroughput, ReportAgingWip, ReportForecast, ReportRework, ReportReworkProjects,
+	ReportBlocked,
+}
+
+// Renders dashboard page fetching reports from given endpoint, path is relative to the page
+func Dashboard(reportPath string) (string, error) {
+	tenants, err := Tenants()
+	if err != nil {
+		return "", tracerr.Wrap(err)
+	}
+
+	tenantIds := make([]string, 0, len(tenants))
+	for _, tenant := range tenants {
+		tenantIds = append(tenantIds, tenant.Id)
+	}
+
+	var buffer bytes.Buffer
+	err = dashboardTemplate.Execute(&buffer, map[string]interface{}{
+		"ReportPath":    reportPath,
+		"Reports":       Reports,
+		"ChartReports":  ChartReports,
+		"ScopedReports": ScopedReports,
+		"Tenants":       tenantIds,
+	})
+	if err != nil {
+		return "", tracerr.Wrap(err)
+	}
+
+	return buffer.String(), nil
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Jira Stats</title>
+<style>
+  body { font-family: sans-serif; font-size: 14px; margin: 20px; color: #333; }
+  form { display: flex; flex-wrap: wrap; gap: 12px; align-items: flex-end; margin-bottom: 20px; }
+  label { display: flex; flex-direction: column; font-size: 12px; }
+  input, select, button { font-size: 14px; padding: 4px; }
+  table { border-collapse: collapse; }
+  th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: left; }
+  th { background: #f4f4f4; cursor: pointer; user-select: none; }
+  td.number { text-align: right; }
+  #error { color: #d62728; white-space: pre-wrap; }
+  #chart img { max-width: 100%; margin-bottom: 20px; }
+</style>
+</head>
+<body>
+<h1>Jira Stats</h1>
+<form id="filters">
+  <label>Tenant <select name="tenant"></select></label>
+  <label>Report <select name="report"></select></label>
+  <label>Start date <input type="date" name="startDate" required></label>
+  <label>End date <input type="date" name="endDate" required></label>
+  <label>Projects <input type="text" name="project" placeholder="ABC,XYZ"></label>
+  <label>Types <input type="text" name="type" placeholder="Story,Bug"></label>
+  <label>Other params <input type="text" name="params" placeholder="mode=rollUp&amp;period=week"></label>
+  <button type="submit">Show</button>
+  <a id="download" href="#">Download CSV</a>
+</form>
+<div id="error"></div>
+<div id="chart"></div>
+<table id="report"></table>
+<script>
+var reportPath = {{.ReportPath}};
+var reports = {{.Reports}};
+var chartReports = {{.ChartReports}};
+var scopedReports = {{.ScopedReports}};
+var tenants = {{.Tenants}};
+
+var form = document.getElementById("filters");
+var rows = [];
+var sortColumn = -1;
+var sortAscending = true;
+
+tenants.forEach(function (tenant) {
+  form.tenant.add(new Option(tenant, tenant));
+});
+
+reports.forEach(function (report) {
+  form.report.add(new Option(report, report));
+});
+
+function toggleScope() {
+  var scoped = scopedReports.indexOf(form.report.value) >= 0;
+  form.project.disabled = !scoped;
+  form.type.disabled = !scoped;
+}
+
+form.report.onchange = toggleScope;
+toggleScope();
+
+var today = new Date();
+form.endDate.value = today.toISOString().substring(0, 10);
+form.startDate.value = new Date(today.getTime() - 30 * 24 * 3600 * 1000).toISOString().substring(0, 10);
+
+function reportUrl(format) {
+  var params = new URLSearchParams(form.params.value);
+  ["tenant", "report", "startDate", "endDate", "project", "type"].forEach(function (name) {
+    if (form[name].value && !form[name].disabled) {
+      params.set(name, form[name].value);
+    }
+  });
+  params.set("format", format);
+  return reportPath + "?" + params.toString();
+}
+
+function render(header) {
+  var table = document.getElementById("report");
+  table.innerHTML = "";
+
+  var head = table.insertRow();
+  header.forEach(function (title, i) {
+    var cell = document.createElement("th");
+    cell.textContent = title + (i === sortColumn ? (sortAscending ? " ▲" : " ▼") : "");
+    cell.onclick = function () {
+      sortAscending = sortColumn === i ? !sortAscending : true;
+      sortColumn = i;
+      sort();
+      render(header);
+    };
+    head.appendChild(cell);
+  });
+
+  rows.forEach(function (row) {
+    var line = table.insertRow();
+    row.forEach(function (entry) {
+      var cell = line.insertCell();
+      cell.textContent = entry;
+      if (entry !== "" && !isNaN(entry)) {
+        cell.className = "number";
+      }
+    });
+  });
+}
+
+function sort() {
+  rows.sort(function (a, b) {
+    var x = a[sortColumn], y = b[sortColumn];
+    var result = (x !== "" && y !== "" && !isNaN(x) && !isNaN(y)) ? x - y : x.localeCompare(y);
+    return sortAscending ? result : -result;
+  });
+}
+
+function load() {
+  document.getElementById("error").textContent = "";
+  document.getElementById("download").href = reportUrl("csv");
+
+  var chart = document.getElementById("chart");
+  chart.innerHTML = "";
+  if (chartReports.indexOf(form.report.value) >= 0) {
+    var image = document.createElement("img");
+    image.onerror = function () { chart.innerHTML = ""; };
+    image.src = reportUrl("svg");
+    chart.appendChild(image);
+  }
+
+  fetch(reportUrl("csv")).then(function (response) {
+    return response.text();
+  }).then(function (text) {
+    if (text.indexOf("Error") === 0) {
+      throw new Error(text);
+    }
+    var lines = text.split("\n").filter(function (line) { return line !== ""; });
+    rows = lines.slice(1).map(function (line) { return line.split(","); });
+    sortColumn = -1;
+    render(lines.length > 0 ? lines[0].split(",") : []);
+  }).catch(function (error) {
+    rows = [];
+    document.getElementById("report").innerHTML = "";
+    document.getElementById("error").textContent = error.message;
+  });
+}
+
+form.onsubmit = function (event) {
+  event.preventDefault();
+  load();
+};
+</script>
+</body>
+</html>
+`))
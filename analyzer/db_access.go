@@ -1,28 +1,221 @@
 package analyzer
 
 import (
-	"fmt"
+	"context"
 	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/VirtusLab/jira-stats/analyzer/telemetry"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
 	"github.com/ztrue/tracerr"
-	"log"
+	"os"
+	"sync"
 	"time"
 )
 
-const ConfigTable = "Config"
-const TicketTable = "Ticket"
+// Tables are partitioned by tenant, items are keyed by tenant id (hash key) and their own key (range key)
+const ConfigTable = "TenantConfig"
+const TicketTable = "TenantTicket"
+const SprintTable = "TenantSprint"
 
-// Fetch all tickets that had dev start time before given date
+const TenantAttribute = "Tenant"
+const LastUpdateConfig = "LastUpdate"
+
+// AWS sessions and DB clients by endpoint, created once and reused by warm lambda invocations
+var awsClients = struct {
+	lock     sync.Mutex
+	sessions map[string]*session.Session
+	dbs      map[string]*dynamodb.DynamoDB
+}{sessions: make(map[string]*session.Session), dbs: make(map[string]*dynamodb.DynamoDB)}
+
+// Session with given endpoint, default endpoints of services are used when empty
+func endpointSession(endpoint string) *session.Session {
+	awsClients.lock.Lock()
+	defer awsClients.lock.Unlock()
+
+	if sess, ok := awsClients.sessions[endpoint]; ok {
+		return sess
+	}
 
-func fetchTicketsWithDevStartTimeBefore(devStartDate time.Time, devEndDate time.Time) ([]domain.Ticket, error) {
-	defer timeTrack(time.Now(), fmt.Sprintf("DB scan for (%s, %s)", devStartDate.Format(time.RFC3339), devEndDate.Format(time.RFC3339)))
+	config := aws.Config{}
+	if endpoint != "" {
+		config.Endpoint = aws.String(endpoint)
+	}
+	sess := session.Must(session.NewSession(&config))
+	awsClients.sessions[endpoint] = sess
+
+	return sess
+}
+
+// Session for AWS services other than DB
+func awsSession() *session.Session {
+	return endpointSession("")
+}
 
-	sess := session.Must(session.NewSession())
+// Session for DB, DYNAMODB_ENDPOINT env variable points it to local store, e.g. DynamoDB Local
+func dbSession() *session.Session {
+	return endpointSession(os.Getenv("DYNAMODB_ENDPOINT"))
+}
+
+// DB client, shared by all DB calls
+func dbClient() *dynamodb.DynamoDB {
+	endpoint := os.Getenv("DYNAMODB_ENDPOINT")
+	sess := endpointSession(endpoint)
+
+	awsClients.lock.Lock()
+	defer awsClients.lock.Unlock()
+
+	if svc, ok := awsClients.dbs[endpoint]; ok {
+		return svc
+	}
 	svc := dynamodb.New(sess)
+	traceRequests(&svc.Handlers, "DynamoDB")
+	awsClients.dbs[endpoint] = svc
+
+	return svc
+}
+
+type awsSpanKey struct{}
+
+// Traces every call of AWS client as client span, e.g. "DynamoDB Scan" (each page of scan is separate call)
+func traceRequests(handlers *request.Handlers, service string) {
+	handlers.Build.PushFrontNamed(request.NamedHandler{Name: "telemetry.StartSpan", Fn: func(r *request.Request) {
+		ctx, span := telemetry.StartSpanOfKind(r.Context(), service+" "+r.Operation.Name, telemetry.KindClient,
+			"aws.service", service, "aws.operation", r.Operation.Name)
+		r.SetContext(context.WithValue(ctx, awsSpanKey{}, span))
+	}})
+	handlers.Complete.PushBackNamed(request.NamedHandler{Name: "telemetry.EndSpan", Fn: func(r *request.Request) {
+		span, ok := r.Context().Value(awsSpanKey{}).(*telemetry.Span)
+		if !ok {
+			return // request failed before it was built
+		}
+		if r.HTTPResponse != nil {
+			span.SetAttribute("http.status_code", r.HTTPResponse.StatusCode)
+		}
+		span.SetAttribute("aws.retries", r.RetryCount)
+		span.RecordError(r.Error)
+		span.End()
+	}})
+}
+
+// Creates tables missing in DB, meant for local store as tables are managed by serverless in AWS
+func CreateTables(ctx context.Context) error {
+	svc := dbClient()
+
+	keys := []struct {
+		table         string
+		attribute     string
+		attributeType string
+		ttlAttribute  string
+		index         string // index keyed by tenant and numeric index attribute
+		indexKey      string
+	}{
+		{ConfigTable, "ConfigName", dynamodb.ScalarAttributeTypeS, "", "", ""},
+		{TicketTable, "Id", dynamodb.ScalarAttributeTypeS, "", "", ""},
+		{SprintTable, "Id", dynamodb.ScalarAttributeTypeN, "", "", ""},
+		{TicketVersionTable, VersionAttribute, dynamodb.ScalarAttributeTypeS, ExpiresAtAttribute, SyncedAtIndex, SyncedAtAttribute},
+	}
+
+	for _, key := range keys {
+		_, err := svc.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(key.table)})
+		if err == nil {
+			continue
+		}
+		if awsErr, ok := err.(awserr.Error); !ok || awsErr.Code() != dynamodb.ErrCodeResourceNotFoundException {
+			return tracerr.Wrap(err)
+		}
+
+		input := dynamodb.CreateTableInput{
+			TableName: aws.String(key.table),
+			AttributeDefinitions: []*dynamodb.AttributeDefinition{
+				{AttributeName: aws.String(TenantAttribute), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+				{AttributeName: aws.String(key.attribute), AttributeType: aws.String(key.attributeType)},
+			},
+			KeySchema: []*dynamodb.KeySchemaElement{
+				{AttributeName: aws.String(TenantAttribute), KeyType: aws.String(dynamodb.KeyTypeHash)},
+				{AttributeName: aws.String(key.attribute), KeyType: aws.String(dynamodb.KeyTypeRange)},
+			},
+			BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+		}
+		if key.index != "" {
+			input.AttributeDefinitions = append(input.AttributeDefinitions,
+				&dynamodb.AttributeDefinition{AttributeName: aws.String(key.indexKey), AttributeType: aws.String(dynamodb.ScalarAttributeTypeN)})
+			input.GlobalSecondaryIndexes = []*dynamodb.GlobalSecondaryIndex{{
+				IndexName: aws.String(key.index),
+				KeySchema: []*dynamodb.KeySchemaElement{
+					{AttributeName: aws.String(TenantAttribute), KeyType: aws.String(dynamodb.KeyTypeHash)},
+					{AttributeName: aws.String(key.indexKey), KeyType: aws.String(dynamodb.KeyTypeRange)},
+				},
+				Projection: &dynamodb.Projection{ProjectionType: aws.String(dynamodb.ProjectionTypeAll)},
+			}}
+		}
+
+		_, err = svc.CreateTableWithContext(ctx, &input)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+
+		if key.ttlAttribute != "" {
+			_, err = svc.UpdateTimeToLiveWithContext(ctx, &dynamodb.UpdateTimeToLiveInput{
+				TableName: aws.String(key.table),
+				TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+					AttributeName: aws.String(key.ttlAttribute),
+					Enabled:       aws.Bool(true),
+				},
+			})
+			if err != nil {
+				return tracerr.Wrap(err)
+			}
+		}
+		telemetry.Infof(ctx, "Created table %s", key.table)
+	}
+
+	return nil
+}
+
+// Key of item of tenant bound to context
+func tenantKey(ctx context.Context, attribute string, value *dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		TenantAttribute: {S: aws.String(tenantOf(ctx).Id)},
+		attribute:       value,
+	}
+}
+
+// Marshals value into item of tenant bound to context
+func tenantItem(ctx context.Context, value interface{}) (map[string]*dynamodb.AttributeValue, error) {
+	item, err := dynamodbattribute.MarshalMap(value)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+
+	item[TenantAttribute] = &dynamodb.AttributeValue{S: aws.String(tenantOf(ctx).Id)}
+	return item, nil
+}
+
+// Fetch all tickets that had dev start time before given date
+
+func fetchTicketsWithDevStartTimeBefore(ctx context.Context, devStartDate time.Time, devEndDate time.Time) ([]domain.Ticket, error) {
+	if asOf, ok := reportedAsOf(ctx); ok {
+		allTickets, err := fetchTicketsAsOf(ctx, asOf)
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+
+		tickets := make([]domain.Ticket, 0)
+		for _, ticket := range allTickets {
+			if ticket.DevTimeOverlaps(devStartDate, devEndDate) {
+				tickets = append(tickets, ticket)
+			}
+		}
+		return tickets, nil
+	}
+
+	ctx, done := trackPhase(ctx, "db_scan_dev_time")
+	defer done()
 
 	filter :=
 		expression.Or(
@@ -36,51 +229,145 @@ func fetchTicketsWithDevStartTimeBefore(devStartDate time.Time, devEndDate time.
 			),
 		)
 
-	expr, err := expression.NewBuilder().
-		//WithKeyCondition(keyCondition).
-		WithFilter(filter).
-		Build()
+	tickets := make([]domain.Ticket, 0)
+	err := queryTable(ctx, TicketTable, &filter, func(item map[string]*dynamodb.AttributeValue) error {
+		var ticket domain.Ticket
+		err := dynamodbattribute.UnmarshalMap(item, &ticket)
+		if err != nil {
+			return err
+		}
+
+		tickets = append(tickets, ticket)
+		return nil
+	})
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+
+	return tickets, nil
+}
+
+// Fetch all stored tickets, as they were at reported time if bound to context
+func fetchAllTickets(ctx context.Context) ([]domain.Ticket, error) {
+	if asOf, ok := reportedAsOf(ctx); ok {
+		return fetchTicketsAsOf(ctx, asOf)
+	}
+
+	ctx, done := trackPhase(ctx, "db_scan_tickets")
+	defer done()
+
+	tickets := make([]domain.Ticket, 0)
+	err := queryTable(ctx, TicketTable, nil, func(item map[string]*dynamodb.AttributeValue) error {
+		var ticket domain.Ticket
+		err := dynamodbattribute.UnmarshalMap(item, &ticket)
+		if err != nil {
+			return err
+		}
 
+		tickets = append(tickets, ticket)
+		return nil
+	})
 	if err != nil {
 		return nil, tracerr.Wrap(err)
 	}
 
-	queryInput := dynamodb.ScanInput{
+	return tickets, nil
+}
+
+// Queries through all the pages of items of tenant bound to context within given table, optionally filtered
+func queryTable(ctx context.Context, tableName string, filter *expression.ConditionBuilder, handle func(item map[string]*dynamodb.AttributeValue) error) error {
+	return queryIndex(ctx, tableName, "", nil, filter, handle)
+}
+
+// Queries items of tenant by index of table (table itself when index name is empty), items can be limited by
+// condition on range key of the index
+func queryIndex(ctx context.Context, tableName string, indexName string, rangeCondition *expression.KeyConditionBuilder,
+	filter *expression.ConditionBuilder, handle func(item map[string]*dynamodb.AttributeValue) error) error {
+	svc := dbClient()
+
+	keyCondition := expression.Key(TenantAttribute).Equal(expression.Value(tenantOf(ctx).Id))
+	if rangeCondition != nil {
+		keyCondition = keyCondition.And(*rangeCondition)
+	}
+
+	builder := expression.NewBuilder().WithKeyCondition(keyCondition)
+	if filter != nil {
+		builder = builder.WithFilter(*filter)
+	}
+
+	expr, err := builder.Build()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+
+	queryInput := dynamodb.QueryInput{
 		ExpressionAttributeNames:  expr.Names(),
 		ExpressionAttributeValues: expr.Values(),
+		KeyConditionExpression:    expr.KeyCondition(),
 		FilterExpression:          expr.Filter(),
-		TableName:                 aws.String(TicketTable),
+		TableName:                 aws.String(tableName),
+	}
+	if indexName != "" {
+		queryInput.IndexName = aws.String(indexName)
 	}
 
-	queryResults, err := svc.Scan(&queryInput)
+	var handleErr error
+	err = svc.QueryPagesWithContext(ctx, &queryInput, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			handleErr = handle(item)
+			if handleErr != nil {
+				return false
+			}
+		}
+		return true
+	})
 	if err != nil {
-		return nil, tracerr.Wrap(err)
+		return tracerr.Wrap(err)
+	}
+	if handleErr != nil {
+		return tracerr.Wrap(handleErr)
 	}
 
-	tickets := make([]domain.Ticket, 0)
-	for _, result := range queryResults.Items {
-		var ticket domain.Ticket
-		err := dynamodbattribute.UnmarshalMap(result, &ticket)
+	return nil
+}
+
+// Fetch all stored sprints
+func fetchAllSprints(ctx context.Context) ([]domain.Sprint, error) {
+	ctx, done := trackPhase(ctx, "db_scan_sprints")
+	defer done()
+
+	sprints := make([]domain.Sprint, 0)
+	err := queryTable(ctx, SprintTable, nil, func(item map[string]*dynamodb.AttributeValue) error {
+		var sprint domain.Sprint
+		err := dynamodbattribute.UnmarshalMap(item, &sprint)
 		if err != nil {
-			return nil, tracerr.Wrap(err)
+			return err
 		}
 
-		tickets = append(tickets, ticket)
+		sprints = append(sprints, sprint)
+		return nil
+	})
+	if err != nil {
+		return nil, tracerr.Wrap(err)
 	}
 
-	return tickets, nil
+	return sprints, nil
 }
 
-// Adds new ticket representation to db, overwrites previously existing one
-func store(ticket domain.Ticket) error {
-	sess := session.Must(session.NewSession())
+// Adds sprint to db, overwrites previously existing one
+func storeSprint(ctx context.Context, sprint domain.Sprint) error {
+	svc := dbClient()
 
-	err := delete(sess, ticket.Id)
+	item, err := tenantItem(ctx, sprint)
 	if err != nil {
 		return tracerr.Wrap(err)
 	}
 
-	err = insert(sess, ticket)
+	input := dynamodb.PutItemInput{
+		Item:      item,
+		TableName: aws.String(SprintTable),
+	}
+	_, err = svc.PutItemWithContext(ctx, &input)
 	if err != nil {
 		return tracerr.Wrap(err)
 	}
@@ -88,19 +375,46 @@ func store(ticket domain.Ticket) error {
 	return nil
 }
 
-func delete(sess *session.Session, ticketId string) error {
-	svc := dynamodb.New(sess)
+// Adds new ticket representation to db as version synced at given time, current representation is replaced
+// while previous versions are kept
+func store(ctx context.Context, ticket domain.Ticket, syncTime time.Time) error {
+	err := storeVersion(ctx, domain.TicketVersion{Ticket: ticket, SyncTime: syncTime})
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+
+	err = insert(ctx, ticket)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+
+	return nil
+}
+
+// Removes ticket deleted in Jira at given time, its versions are kept for reports as of earlier syncs
+func remove(ctx context.Context, ticketId string, syncTime time.Time) error {
+	err := storeVersion(ctx, domain.TicketVersion{Ticket: domain.Ticket{Id: ticketId}, SyncTime: syncTime, Deleted: true})
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+
+	err = delete(ctx, ticketId)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+
+	return nil
+}
+
+func delete(ctx context.Context, ticketId string) error {
+	svc := dbClient()
 
 	input := dynamodb.DeleteItemInput{
-		Key: map[string]*dynamodb.AttributeValue{
-			"Id": {
-				S: aws.String(ticketId),
-			},
-		},
+		Key:       tenantKey(ctx, "Id", &dynamodb.AttributeValue{S: aws.String(ticketId)}),
 		TableName: aws.String(TicketTable),
 	}
 
-	_, err := svc.DeleteItem(&input)
+	_, err := svc.DeleteItemWithContext(ctx, &input)
 	if err != nil {
 		return tracerr.Wrap(err)
 	}
@@ -108,10 +422,10 @@ func delete(sess *session.Session, ticketId string) error {
 	return nil
 }
 
-func insert(sess *session.Session, ticket domain.Ticket) error {
-	svc := dynamodb.New(sess)
+func insert(ctx context.Context, ticket domain.Ticket) error {
+	svc := dbClient()
 
-	item, err := dynamodbattribute.MarshalMap(ticket)
+	item, err := tenantItem(ctx, ticket)
 	if err != nil {
 		return tracerr.Wrap(err)
 	}
@@ -120,7 +434,7 @@ func insert(sess *session.Session, ticket domain.Ticket) error {
 		Item:      item,
 		TableName: aws.String(TicketTable),
 	}
-	_, err = svc.PutItem(&input)
+	_, err = svc.PutItemWithContext(ctx, &input)
 	if err != nil {
 		return tracerr.Wrap(err)
 	}
@@ -128,11 +442,11 @@ func insert(sess *session.Session, ticket domain.Ticket) error {
 	return nil
 }
 
-func storeLastUpdate(updateTime time.Time) error {
-	sess := session.Must(session.NewSession())
-	svc := dynamodb.New(sess)
+// Stores sync cursor of tenant, update time of the most recent ticket stored
+func storeLastUpdate(ctx context.Context, updateTime time.Time) error {
+	svc := dbClient()
 
-	prevUpdate, err := getLastUpdate()
+	prevUpdate, err := getLastUpdate(ctx)
 	if err != nil {
 		return tracerr.Wrap(err)
 	}
@@ -144,27 +458,23 @@ func storeLastUpdate(updateTime time.Time) error {
 					S: aws.String(updateTime.Format(time.RFC3339)),
 				},
 			},
-			Key: map[string]*dynamodb.AttributeValue{
-				"ConfigName": {
-					S: aws.String("LastUpdate"),
-				},
-			},
+			Key:              tenantKey(ctx, "ConfigName", &dynamodb.AttributeValue{S: aws.String(LastUpdateConfig)}),
 			ReturnValues:     aws.String("UPDATED_NEW"),
 			TableName:        aws.String(ConfigTable),
 			UpdateExpression: aws.String("set ConfigValue = :w"),
 		}
-		output, err := svc.UpdateItem(input)
+		output, err := svc.UpdateItemWithContext(ctx, input)
 		if err != nil {
 			return tracerr.Wrap(err)
 		}
-		log.Printf("Output is: %s", output)
+		telemetry.Debugf(ctx, "Output is: %s", output)
 	} else {
 		configItem := domain.ConfigItem{
-			ConfigName:  "LastUpdate",
+			ConfigName:  LastUpdateConfig,
 			ConfigValue: updateTime.Format(time.RFC3339),
 		}
 
-		item, err := dynamodbattribute.MarshalMap(configItem)
+		item, err := tenantItem(ctx, configItem)
 		if err != nil {
 			return tracerr.Wrap(err)
 		}
@@ -174,7 +484,7 @@ func storeLastUpdate(updateTime time.Time) error {
 			TableName: aws.String(ConfigTable),
 		}
 
-		_, err = svc.PutItem(&input)
+		_, err = svc.PutItemWithContext(ctx, &input)
 		if err != nil {
 			return tracerr.Wrap(err)
 		}
@@ -183,17 +493,11 @@ func storeLastUpdate(updateTime time.Time) error {
 	return err
 }
 
-func getLastUpdate() (time.Time, error) {
-	sess := session.Must(session.NewSession())
-	svc := dynamodb.New(sess)
-
-	result, err := svc.GetItem(&dynamodb.GetItemInput{
-		Key: map[string]*dynamodb.AttributeValue{
-			"ConfigName": {
-				S: aws.String("LastUpdate"),
-			},
-		},
+func getLastUpdate(ctx context.Context) (time.Time, error) {
+	svc := dbClient()
 
+	result, err := svc.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		Key:       tenantKey(ctx, "ConfigName", &dynamodb.AttributeValue{S: aws.String(LastUpdateConfig)}),
 		TableName: aws.String(ConfigTable),
 	})
 	if err != nil {
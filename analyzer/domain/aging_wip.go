@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"sort"
+	"time"
+)
+
+const agingPercentile = 85
+
+type AgingTicket struct {
+	Ticket        Ticket
+	StateAgeDays  float64 // time in current state
+	AgeDays       float64 // time since ticket became active for the first time
+	CycleTimeDays float64 // 85th percentile of cycle time of tickets of the same type, 0 when no history
+	Exceeded      bool
+}
+
+// Cycle time (from becoming active to becoming done) in calendar days of tickets done within given dates,
+// grouped by ticket type
+func CycleTimes(tickets []Ticket, workflow Workflow, start time.Time, end time.Time) map[string][]float64 {
+	cycleTimes := make(map[string][]float64)
+	for _, ticket := range tickets {
+		startTime, started := workflow.StartTime(ticket)
+		doneTime, done := workflow.DoneTime(ticket)
+		if !started || !done || doneTime.Before(start) || !doneTime.Before(end) {
+			continue
+		}
+
+		cycleTimes[ticket.Type] = append(cycleTimes[ticket.Type], days(doneTime.Sub(startTime)))
+	}
+
+	return cycleTimes
+}
+
+// Lists tickets which are currently in active or waiting state with their age, flagging tickets older than
+// 85th percentile of cycle time of tickets of the same type done within given dates
+func CalculateAgingWip(tickets []Ticket, workflow Workflow, start time.Time, end time.Time, now time.Time) []AgingTicket {
+	cycleTimes := CycleTimes(tickets, workflow, start, end)
+
+	result := make([]AgingTicket, 0)
+	for _, ticket := range tickets {
+		if len(ticket.Transitions) == 0 {
+			continue
+		}
+
+		current := ticket.Transitions[len(ticket.Transitions)-1]
+		if !workflow.IsActive(current.State) && !workflow.IsWaiting(current.State) {
+			continue
+		}
+
+		startTime, ok := workflow.StartTime(ticket)
+		if !ok {
+			startTime = current.Start
+		}
+
+		aging := AgingTicket{
+			Ticket:        ticket,
+			StateAgeDays:  days(now.Sub(current.Start)),
+			AgeDays:       days(now.Sub(startTime)),
+			CycleTimeDays: Percentile(cycleTimes[ticket.Type], agingPercentile),
+		}
+		aging.Exceeded = len(cycleTimes[ticket.Type]) > 0 && aging.AgeDays > aging.CycleTimeDays
+
+		result = append(result, aging)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].AgeDays > result[j].AgeDays
+	})
+
+	return result
+}
+
+func days(duration time.Duration) float64 {
+	return duration.Hours() / 24
+}
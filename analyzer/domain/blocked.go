@@ -0,0 +1,135 @@
+package domain
+
+import (
+	"sort"
+	"time"
+)
+
+const StateFlagged = "Flagged"
+
+type BlockedTime struct {
+	Ticket       Ticket
+	BlockedHours float64
+	Blockers     int // number of times ticket got flagged or moved to blocked state
+}
+
+// Flag being set or cleared on a ticket
+type FlagChange struct {
+	Flagged   bool
+	Timestamp time.Time
+	Author    string
+}
+
+// Builds intervals of ticket being flagged out of flag changes, sorted by time
+func MakeFlagIntervals(changes ...FlagChange) []TransitionInterval {
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Timestamp.Before(changes[j].Timestamp)
+	})
+
+	intervals := make([]TransitionInterval, 0)
+	var current *TransitionInterval
+
+	for _, change := range changes {
+		if change.Flagged && current == nil {
+			current = &TransitionInterval{Start: change.Timestamp, End: EndOfTime, State: StateFlagged, Author: change.Author}
+		} else if !change.Flagged && current != nil {
+			current.End = change.Timestamp
+			intervals = append(intervals, *current)
+			current = nil
+		}
+	}
+
+	if current != nil {
+		intervals = append(intervals, *current)
+	}
+
+	return intervals
+}
+
+// Intervals of ticket being flagged or in blocked state, overlapping ones merged
+func BlockedIntervals(ticket Ticket, workflow Workflow) []TransitionInterval {
+	intervals := append([]TransitionInterval{}, ticket.FlagIntervals...)
+	for _, interval := range ticket.Transitions {
+		if workflow.IsBlocked(interval.State) {
+			intervals = append(intervals, interval)
+		}
+	}
+
+	return mergeIntervals(intervals)
+}
+
+// Sums time tickets were blocked within given boundaries, tickets which were not blocked are skipped
+func CalculateBlockedTime(calculator DaysCalculator, tickets []Ticket, workflow Workflow, start time.Time, end time.Time) []BlockedTime {
+	endBound := calculator.calculateEndBound(end)
+
+	result := make([]BlockedTime, 0)
+	for _, ticket := range tickets {
+		blocked := BlockedTime{Ticket: ticket}
+
+		for _, interval := range BlockedIntervals(ticket, workflow) {
+			intervalStart := maxTime(interval.Start, start)
+			intervalEnd := minTime(interval.End, endBound)
+			if intervalStart.Before(intervalEnd) {
+				blocked.BlockedHours += intervalEnd.Sub(intervalStart).Hours()
+			}
+		}
+
+		for _, interval := range ticket.FlagIntervals {
+			if !interval.Start.Before(start) && interval.Start.Before(end) {
+				blocked.Blockers++
+			}
+		}
+		for i, interval := range ticket.Transitions {
+			entered := i == 0 || !workflow.IsBlocked(ticket.Transitions[i-1].State)
+			if workflow.IsBlocked(interval.State) && entered && !interval.Start.Before(start) && interval.Start.Before(end) {
+				blocked.Blockers++
+			}
+		}
+
+		if blocked.BlockedHours > 0 || blocked.Blockers > 0 {
+			result = append(result, blocked)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].BlockedHours > result[j].BlockedHours
+	})
+
+	return result
+}
+
+// Merges overlapping intervals regardless of their state
+func mergeIntervals(intervals []TransitionInterval) []TransitionInterval {
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i].Start.Before(intervals[j].Start)
+	})
+
+	merged := make([]TransitionInterval, 0)
+	for _, interval := range intervals {
+		last := len(merged) - 1
+		if last >= 0 && !interval.Start.After(merged[last].End) {
+			if interval.End.After(merged[last].End) {
+				merged[last].End = interval.End
+			}
+			continue
+		}
+
+		merged = append(merged, interval)
+	}
+
+	return merged
+}
+
+func maxTime(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a time.Time, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const maxCumulativeFlowSamples = 24 * 366 // a year of hourly samples
+
+type StatusCount struct {
+	Date   time.Time
+	Status string
+	Count  int
+}
+
+// Counts tickets in each status at the end of every period (hour or day) between given dates.
+//
+// Every status seen within the dates is reported for each period, also with zero count, so that data can be
+// directly used for stacked charts. Tickets are counted since they were created.
+func CumulativeFlow(tickets []Ticket, start time.Time, end time.Time, period string) ([]StatusCount, error) {
+	if period != PeriodHour && period != PeriodDay {
+		return nil, fmt.Errorf("unsupported cumulative flow period [%s]", period)
+	}
+
+	periodStart, err := PeriodStart(start, period)
+	if err != nil {
+		return nil, err
+	}
+
+	periods := make([]time.Time, 0)
+	samples := make([]map[string]int, 0)
+	statuses := make(map[string]bool)
+
+	for ; periodStart.Before(end); periodStart = NextPeriod(periodStart, period) {
+		if len(periods) >= maxCumulativeFlowSamples {
+			return nil, fmt.Errorf("too many samples for cumulative flow, use longer period or shorter dates")
+		}
+
+		sampleTime := NextPeriod(periodStart, period).Add(-time.Second)
+		counts := make(map[string]int)
+		for _, ticket := range tickets {
+			if state := ticket.StateAt(sampleTime); state != "" {
+				counts[state]++
+				statuses[state] = true
+			}
+		}
+
+		periods = append(periods, periodStart)
+		samples = append(samples, counts)
+	}
+
+	sortedStatuses := make([]string, 0, len(statuses))
+	for status := range statuses {
+		sortedStatuses = append(sortedStatuses, status)
+	}
+	sort.Strings(sortedStatuses)
+
+	result := make([]StatusCount, 0, len(periods)*len(sortedStatuses))
+	for i, date := range periods {
+		for _, status := range sortedStatuses {
+			result = append(result, StatusCount{Date: date, Status: status, Count: samples[i][status]})
+		}
+	}
+
+	return result, nil
+}
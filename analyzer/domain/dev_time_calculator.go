@@ -6,11 +6,13 @@ import (
 )
 
 const StateDev = "In Development"
+const TypeEpic = "Epic"
 
 type Now func() time.Time
 
 type DaysCalculator struct {
-	ClockNow Now
+	ClockNow        Now
+	SubtractBlocked bool // time ticket was flagged as impediment is not counted as dev time
 }
 
 /**
@@ -25,7 +27,7 @@ type DaysCalculator struct {
 	- weekends are not counted
 */
 func (this *DaysCalculator) CalculateDevDays(ticket Ticket, start time.Time, end time.Time) float64 {
-	var cumulativeTime int
+	var cumulativeTime float64
 
 	if this.shouldSkipTicket(ticket) {
 		log.Printf("Ticket %s has been skipped from dev time calculation", ticket.Key)
@@ -33,15 +35,70 @@ func (this *DaysCalculator) CalculateDevDays(ticket Ticket, start time.Time, end
 	}
 
 	for _, transition := range ticket.Transitions {
-		devTime := this.calculateDevTime(transition, start, end)
-		cumulativeTime += devTime
+		cumulativeTime += this.devHours(ticket, transition, start, end)
 	}
 
-	return float64(cumulativeTime) / 8.0
+	return cumulativeTime / 8.0
+}
+
+// Calculates dev days per author of dev state intervals
+func (this *DaysCalculator) CalculateDevDaysByAuthor(ticket Ticket, start time.Time, end time.Time) map[string]float64 {
+	days := make(map[string]float64)
+
+	if this.shouldSkipTicket(ticket) {
+		return days
+	}
+
+	for _, transition := range ticket.Transitions {
+		devTime := this.devHours(ticket, transition, start, end)
+		if devTime > 0 {
+			days[transition.Author] += devTime / 8.0
+		}
+	}
+
+	return days
+}
+
+// Dev hours of state interval, flagged part of it is left out before rounding if requested. Short interval is
+// rounded by its unflagged duration, longer one counted in working days loses flagged share of its working hours
+// (or of its whole duration when it has no working hours), so that flags do not split it into separately rounded parts
+func (this *DaysCalculator) devHours(ticket Ticket, interval TransitionInterval, start time.Time, end time.Time) float64 {
+	if !this.SubtractBlocked {
+		return float64(this.calculateDevTime(interval, start, end))
+	}
+	if interval.State != StateDev || !this.isTransitionRelevantForBoundaries(interval, start, end) {
+		return 0
+	}
+
+	bounded := this.adjustDatesToBounds(interval, start, end)
+	diff := bounded.End.Sub(bounded.Start)
+
+	flagged := time.Duration(0)
+	flaggedWorkingHours := 0.0
+	for _, flag := range ticket.FlagIntervals {
+		from, to := maxTime(flag.Start, bounded.Start), minTime(flag.End, bounded.End)
+		if from.Before(to) {
+			flagged += to.Sub(from)
+			flaggedWorkingHours += this.workingHoursBetween(from, to)
+		}
+	}
+
+	if diff <= 8*time.Hour {
+		if flagged >= diff {
+			return 0
+		}
+		return float64(this.shortDevTime(diff - flagged))
+	}
+
+	hours := float64(this.calculateWorkingHours(bounded.Start, bounded.End))
+	if workingHours := this.workingHoursBetween(bounded.Start, bounded.End); workingHours > 0 {
+		return hours * (workingHours - flaggedWorkingHours) / workingHours
+	}
+	return hours * (diff - flagged).Hours() / diff.Hours()
 }
 
 func (this *DaysCalculator) shouldSkipTicket(ticket Ticket) bool {
-	return ticket.Type == "Epic" // epics are being skipped from calculation
+	return ticket.Type == TypeEpic // epics are being skipped from calculation
 }
 
 func (this *DaysCalculator) calculateDevTime(interval TransitionInterval, start time.Time, end time.Time) int {
@@ -56,17 +113,24 @@ func (this *DaysCalculator) calculateDevTime(interval TransitionInterval, start
 
 	diff := interval.End.Sub(interval.Start)
 
+	if diff.Minutes() <= 8*60 {
+		return this.shortDevTime(diff)
+	} else {
+		return this.calculateWorkingHours(interval.Start, interval.End)
+		return int(diff.Hours())
+	}
+}
+
+// Dev time of interval not longer than a day, rounded up to multiplication of 2 hours
+func (this *DaysCalculator) shortDevTime(diff time.Duration) int {
 	if diff.Minutes() <= 2*60 {
 		return 2
 	} else if diff.Minutes() <= 4*60 {
 		return 4
 	} else if diff.Minutes() <= 6*60 {
 		return 6
-	} else if diff.Minutes() <= 8*60 {
-		return 8
 	} else {
-		return this.calculateWorkingHours(interval.Start, interval.End)
-		return int(diff.Hours())
+		return 8
 	}
 }
 
@@ -105,6 +169,25 @@ func (this *DaysCalculator) calculateWorkingHours(start time.Time, end time.Time
 	return totalHours
 }
 
+// Hours between given times falling into working hours (9:00 - 17:00 of working days)
+func (this *DaysCalculator) workingHoursBetween(start time.Time, end time.Time) float64 {
+	hours := 0.0
+
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	for day.Before(end) {
+		if this.isWorkingDay(day) {
+			from := maxTime(start, day.Add(9*time.Hour))
+			to := minTime(end, day.Add(17*time.Hour))
+			if from.Before(to) {
+				hours += to.Sub(from).Hours()
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+
+	return hours
+}
+
 func (this *DaysCalculator) adjustDatesToBounds(interval TransitionInterval, start time.Time, end time.Time) TransitionInterval {
 	if interval.Start.Before(start) {
 		interval.Start = start
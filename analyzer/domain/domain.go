@@ -1,9 +1,11 @@
 package domain
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/andygrunwald/go-jira"
 	"github.com/ztrue/tracerr"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -23,10 +25,24 @@ type Ticket struct {
 	State       string
 	Type        string
 	Title       string
+	EpicKey     string
+	ParentKey   string   // set for sub-tasks only
+	SubTaskKeys []string // set for tickets having sub-tasks
 	Transitions []TransitionInterval
 	UpdateTime  time.Time
 	CreateTime  time.Time
 
+	Sprints       []int // sprints ticket currently belongs to
+	SprintChanges []SprintChange
+
+	StoryPoints      float64
+	OriginalEstimate int // in seconds
+
+	Worklogs []Worklog
+
+	FlagIntervals []TransitionInterval // ticket being flagged as impediment
+	AuthorNames   map[string]string    // display names of authors of transitions and worklogs by their ids
+
 	DevStartDate int64
 	DevEndDate   int64
 }
@@ -36,6 +52,27 @@ func (t *Ticket) Project() string {
 	return t.Key[0:dashIdx]
 }
 
+// Tells whether dev time of ticket is contained in, overlaps with or contains given interval
+func (t *Ticket) DevTimeOverlaps(startDate time.Time, endDate time.Time) bool {
+	start, end := startDate.Unix(), endDate.Unix()
+	within := func(value int64) bool { return start <= value && value <= end }
+
+	return within(t.DevStartDate) || within(t.DevEndDate) || (t.DevStartDate <= start && t.DevEndDate >= end)
+}
+
+// Ids of Jira custom fields - those differ between Jira instances
+type CustomFields struct {
+	EpicLink    string
+	Sprint      string
+	StoryPoints string
+}
+
+var DefaultCustomFields = CustomFields{
+	EpicLink:    "customfield_10008",
+	Sprint:      "customfield_10007",
+	StoryPoints: "customfield_10002",
+}
+
 type Transition struct {
 	FromState string
 	ToState   string
@@ -61,8 +98,9 @@ type ConfigItem struct {
 }
 
 type CsvContents struct {
-	Header []string
-	Rows   []CsvRow
+	Header  []string
+	Rows    []CsvRow
+	Numeric []string // columns holding numbers, printed unquoted in JSON
 }
 
 type CsvRow struct {
@@ -81,18 +119,64 @@ func (csv CsvContents) ToString() string {
 	return strings.Join(csvList, "\n")
 }
 
-func JiraToDomain(jiraIssue jira.Issue) (Ticket, error) {
+// Prints CSV structure as JSON array of objects keyed by header, entries of numeric columns and booleans are not quoted
+func (csv CsvContents) ToJson() (string, error) {
+	objects := make([]map[string]interface{}, 0, len(csv.Rows))
+
+	numeric := make(map[string]bool)
+	for _, column := range csv.Numeric {
+		numeric[column] = true
+	}
+
+	for _, row := range csv.Rows {
+		object := make(map[string]interface{})
+		for i, entry := range row.Entries {
+			if i < len(csv.Header) {
+				object[csv.Header[i]] = jsonValue(entry, numeric[csv.Header[i]])
+			}
+		}
+		objects = append(objects, object)
+	}
+
+	result, err := json.Marshal(objects)
+	if err != nil {
+		return "", tracerr.Wrap(err)
+	}
+
+	return string(result), nil
+}
+
+func jsonValue(entry string, numeric bool) interface{} {
+	if numeric {
+		if number, err := strconv.ParseFloat(entry, 64); err == nil {
+			return number
+		}
+	}
+	if entry == "true" || entry == "false" {
+		return entry == "true"
+	}
+	return entry
+}
+
+func JiraToDomain(jiraIssue jira.Issue, config JiraConfig) (Ticket, error) {
+	fields := config.Fields
+	authorNames := make(map[string]string)
 
 	transitions := make([]Transition, 0)
+	changes := make([]SprintChange, 0)
+	flagChanges := make([]FlagChange, 0)
 
 	devStartDate := EndOfTime
 	devEndDate := BeginingOfTime
 
 	for _, historyItem := range jiraIssue.Changelog.Histories {
+		author := config.authorId(&historyItem.Author)
+		config.addAuthorName(authorNames, &historyItem.Author)
+
 		for _, changeItem := range historyItem.Items {
 			if strings.ToLower(changeItem.Field) == "status" {
 
-				timestamp, err := time.Parse(JiraTimestampFormat, historyItem.Created)
+				timestamp, err := ParseJiraTime(historyItem.Created)
 				if err != nil {
 					return Ticket{}, tracerr.Wrap(err)
 				}
@@ -101,7 +185,7 @@ func JiraToDomain(jiraIssue jira.Issue) (Ticket, error) {
 					FromState: changeItem.FromString,
 					ToState:   changeItem.ToString,
 					Timestamp: timestamp,
-					Author:    historyItem.Author.Name,
+					Author:    author,
 				})
 
 				if changeItem.ToString == "In Development" && devStartDate.After(timestamp) {
@@ -112,6 +196,28 @@ func JiraToDomain(jiraIssue jira.Issue) (Ticket, error) {
 					devEndDate = timestamp
 				}
 			}
+
+			if strings.ToLower(changeItem.Field) == "sprint" {
+				timestamp, err := ParseJiraTime(historyItem.Created)
+				if err != nil {
+					return Ticket{}, tracerr.Wrap(err)
+				}
+
+				changes = append(changes, sprintChanges(changeItem.From, changeItem.To, timestamp)...)
+			}
+
+			if strings.ToLower(changeItem.Field) == "flagged" {
+				timestamp, err := ParseJiraTime(historyItem.Created)
+				if err != nil {
+					return Ticket{}, tracerr.Wrap(err)
+				}
+
+				flagChanges = append(flagChanges, FlagChange{
+					Flagged:   changeItem.ToString != "",
+					Timestamp: timestamp,
+					Author:    author,
+				})
+			}
 		}
 	}
 
@@ -128,13 +234,27 @@ func JiraToDomain(jiraIssue jira.Issue) (Ticket, error) {
 	state := jiraIssue.Fields.Status.Name
 
 	ticket := Ticket{
-		Id:         jiraIssue.ID,
-		Key:        jiraIssue.Key,
-		Title:      jiraIssue.Fields.Summary,
-		Type:       jiraIssue.Fields.Type.Name,
-		State:      state,
-		UpdateTime: updateTime,
-		CreateTime: createdTime,
+		Id:          jiraIssue.ID,
+		Key:         jiraIssue.Key,
+		Title:       jiraIssue.Fields.Summary,
+		Type:        jiraIssue.Fields.Type.Name,
+		State:       state,
+		EpicKey:     epicKey(jiraIssue, fields),
+		ParentKey:   parentKey(jiraIssue),
+		SubTaskKeys: subTaskKeys(jiraIssue),
+		UpdateTime:  updateTime,
+		CreateTime:  createdTime,
+
+		Sprints:       sprints(jiraIssue, fields),
+		SprintChanges: changes,
+
+		StoryPoints:      storyPoints(jiraIssue, fields),
+		OriginalEstimate: jiraIssue.Fields.TimeOriginalEstimate,
+
+		Worklogs:    worklogs(jiraIssue, config, authorNames),
+		AuthorNames: authorNames,
+
+		FlagIntervals: MakeFlagIntervals(flagChanges...),
 
 		DevStartDate: devStartDate.Unix(),
 		DevEndDate:   devEndDate.Unix(),
@@ -144,12 +264,80 @@ func JiraToDomain(jiraIssue jira.Issue) (Ticket, error) {
 	return ticket, nil
 }
 
+// Epic link is a custom field in Jira Server, team-managed projects use parent field instead
+func epicKey(jiraIssue jira.Issue, fields CustomFields) string {
+	if epicLink, ok := jiraIssue.Fields.Unknowns.Value(fields.EpicLink); ok {
+		if key, ok := epicLink.(string); ok && key != "" {
+			return key
+		}
+	}
+
+	if jiraIssue.Fields.Parent != nil && !jiraIssue.Fields.Type.Subtask {
+		return jiraIssue.Fields.Parent.Key
+	}
+
+	return ""
+}
+
+func parentKey(jiraIssue jira.Issue) string {
+	if jiraIssue.Fields.Parent != nil && jiraIssue.Fields.Type.Subtask {
+		return jiraIssue.Fields.Parent.Key
+	}
+
+	return ""
+}
+
+func sprints(jiraIssue jira.Issue, fields CustomFields) []int {
+	sprintField, _ := jiraIssue.Fields.Unknowns.Value(fields.Sprint)
+	return parseSprintField(sprintField)
+}
+
+func storyPoints(jiraIssue jira.Issue, fields CustomFields) float64 {
+	points, _ := jiraIssue.Fields.Unknowns.Value(fields.StoryPoints)
+	if value, ok := points.(float64); ok {
+		return value
+	}
+	return 0
+}
+
+func worklogs(jiraIssue jira.Issue, config JiraConfig, authorNames map[string]string) []Worklog {
+	result := make([]Worklog, 0)
+	if jiraIssue.Fields.Worklog == nil {
+		return result
+	}
+
+	for _, record := range jiraIssue.Fields.Worklog.Worklogs {
+		worklog := Worklog{
+			Id:               record.ID,
+			Author:           config.authorId(record.Author),
+			TimeSpentSeconds: record.TimeSpentSeconds,
+		}
+		config.addAuthorName(authorNames, record.Author)
+		if record.Started != nil {
+			worklog.Started = time.Time(*record.Started)
+		}
+
+		result = append(result, worklog)
+	}
+
+	return result
+}
+
+func subTaskKeys(jiraIssue jira.Issue) []string {
+	keys := make([]string, 0)
+	for _, subTask := range jiraIssue.Fields.Subtasks {
+		keys = append(keys, subTask.Key)
+	}
+
+	return keys
+}
+
 func unmarshalDatetime(field jira.Time) (time.Time, error) {
 	datetimeRaw, err := field.MarshalJSON()
 	if err != nil {
 		return BeginingOfTime, err
 	}
-	datetime, err := time.Parse(JiraUpdateTimestampFormat, strings.ReplaceAll(string(datetimeRaw), "\"", ""))
+	datetime, err := ParseJiraTime(string(datetimeRaw))
 	if err != nil {
 		return BeginingOfTime, err
 	}
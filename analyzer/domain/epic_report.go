@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"sort"
+	"time"
+)
+
+type EpicSummary struct {
+	Key             string
+	Title           string
+	State           string
+	ChildCount      int
+	ChildrenByState map[string]int
+	DevDays         float64 // dev time of children within requested boundaries
+	DevDaysTotal    float64 // dev time of children since the beginning
+	StartDate       time.Time
+	EndDate         time.Time
+}
+
+func (e *EpicSummary) Project() string {
+	ticket := Ticket{Key: e.Key}
+	return ticket.Project()
+}
+
+// Rolls up children dev time to epics they are linked to.
+//
+// Epics themselves are skipped by the calculator, so only children are summed. Epic start is the earliest
+// dev start of its children, epic end is the latest dev end (unset while any child which got to development is not
+// done yet, or while no child left development).
+// Sub-tasks are counted towards epic of their parent. Epics which are not stored but are referenced by children
+// are reported with key only.
+func SummarizeEpics(calculator DaysCalculator, tickets []Ticket, workflow Workflow, start time.Time, end time.Time) []EpicSummary {
+	epics := make(map[string]*EpicSummary)
+	inProgress := make(map[string]bool)
+
+	summary := func(key string) *EpicSummary {
+		if _, ok := epics[key]; !ok {
+			epics[key] = &EpicSummary{
+				Key:             key,
+				ChildrenByState: make(map[string]int),
+				StartDate:       EndOfTime,
+				EndDate:         BeginingOfTime,
+			}
+		}
+		return epics[key]
+	}
+
+	epicKeys := make(map[string]string)
+	for _, ticket := range tickets {
+		epicKeys[ticket.Key] = ticket.EpicKey
+
+		if ticket.Type == TypeEpic {
+			epic := summary(ticket.Key)
+			epic.Title = ticket.Title
+			epic.State = ticket.State
+		}
+	}
+
+	for _, ticket := range tickets {
+		epicKey := ticket.EpicKey
+		if epicKey == "" && ticket.ParentKey != "" { // sub-tasks belong to epic of their parent
+			epicKey = epicKeys[ticket.ParentKey]
+		}
+
+		if epicKey == "" || ticket.Type == TypeEpic {
+			continue
+		}
+
+		epic := summary(epicKey)
+		epic.ChildCount++
+		epic.ChildrenByState[ticket.State]++
+		epic.DevDays += calculator.CalculateDevDays(ticket, start, end)
+		epic.DevDaysTotal += calculator.CalculateDevDays(ticket, BeginingOfTime, EndOfTime)
+
+		devStart := time.Unix(ticket.DevStartDate, 0).UTC()
+		if devStart.Before(epic.StartDate) {
+			epic.StartDate = devStart
+		}
+
+		devEnd := time.Unix(ticket.DevEndDate, 0).UTC()
+		if devEnd.After(epic.EndDate) {
+			epic.EndDate = devEnd
+		}
+
+		if ticket.wasInDevelopment() && !workflow.IsDone(ticket.State) {
+			inProgress[epicKey] = true
+		}
+	}
+
+	result := make([]EpicSummary, 0, len(epics))
+	for _, epic := range epics {
+		if inProgress[epic.Key] {
+			epic.EndDate = BeginingOfTime
+		}
+		result = append(result, *epic)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+
+	return result
+}
+
+// Tells whether ticket ever got to development
+func (t *Ticket) wasInDevelopment() bool {
+	for _, interval := range t.Transitions {
+		if interval.State == StateDev {
+			return true
+		}
+	}
+	return false
+}
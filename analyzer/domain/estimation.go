@@ -0,0 +1,163 @@
+package domain
+
+import (
+	"sort"
+)
+
+const SecondsPerDay = 8 * 60 * 60 // estimates are given in working days of 8h
+
+const minOutlierSample = 4   // point values with fewer tickets are not checked for outliers
+const maxEstimateRatio = 2.0 // dev time twice the estimate is considered an outlier
+const minEstimateRatio = 0.5 // dev time half of the estimate is considered an outlier
+
+type EstimateAccuracy struct {
+	Ticket        Ticket
+	DevDays       float64
+	EstimateDays  float64 // original estimate, 0 when not estimated
+	DaysPerPoint  float64 // 0 when not pointed
+	EstimateRatio float64 // dev time to original estimate, 0 when not estimated
+	Outlier       bool
+}
+
+type PointsDistribution struct {
+	Points float64
+	Count  int
+	Min    float64
+	Q1     float64
+	Median float64
+	Q3     float64
+	Max    float64
+	Mean   float64
+}
+
+type TeamAccuracy struct {
+	Project          string
+	Tickets          int
+	Points           float64
+	PointedDevDays   float64 // dev time of pointed tickets only
+	EstimateDays     float64
+	EstimatedDevDays float64 // dev time of estimated tickets only
+}
+
+func (t *TeamAccuracy) DaysPerPoint() float64 {
+	if t.Points == 0 {
+		return 0
+	}
+	return t.PointedDevDays / t.Points
+}
+
+func (t *TeamAccuracy) EstimateRatio() float64 {
+	if t.EstimateDays == 0 {
+		return 0
+	}
+	return t.EstimatedDevDays / t.EstimateDays
+}
+
+// Compares total dev time of tickets with their estimates.
+//
+// Ticket is flagged as an outlier when its dev time is outside of Tukey fences (1.5 IQR) of tickets with
+// the same number of points, or when dev time is more than twice or less than half of its original estimate.
+func CalculateEstimateAccuracy(calculator DaysCalculator, tickets []Ticket) []EstimateAccuracy {
+	accuracies := make([]EstimateAccuracy, 0)
+	for _, ticket := range tickets {
+		if ticket.StoryPoints == 0 && ticket.OriginalEstimate == 0 {
+			continue
+		}
+
+		accuracy := EstimateAccuracy{
+			Ticket:       ticket,
+			DevDays:      calculator.CalculateDevDays(ticket, BeginingOfTime, EndOfTime),
+			EstimateDays: float64(ticket.OriginalEstimate) / SecondsPerDay,
+		}
+
+		if ticket.StoryPoints > 0 {
+			accuracy.DaysPerPoint = accuracy.DevDays / ticket.StoryPoints
+		}
+
+		if accuracy.EstimateDays > 0 {
+			accuracy.EstimateRatio = accuracy.DevDays / accuracy.EstimateDays
+			accuracy.Outlier = accuracy.EstimateRatio > maxEstimateRatio || accuracy.EstimateRatio < minEstimateRatio
+		}
+
+		accuracies = append(accuracies, accuracy)
+	}
+
+	for _, distribution := range DistributeByPoints(accuracies) {
+		if distribution.Count < minOutlierSample {
+			continue
+		}
+
+		iqr := distribution.Q3 - distribution.Q1
+		for i := range accuracies {
+			if accuracies[i].Ticket.StoryPoints == distribution.Points &&
+				(accuracies[i].DevDays < distribution.Q1-1.5*iqr || accuracies[i].DevDays > distribution.Q3+1.5*iqr) {
+				accuracies[i].Outlier = true
+			}
+		}
+	}
+
+	return accuracies
+}
+
+// Distribution of dev time for each story points value
+func DistributeByPoints(accuracies []EstimateAccuracy) []PointsDistribution {
+	devDays := make(map[float64][]float64)
+	for _, accuracy := range accuracies {
+		if accuracy.Ticket.StoryPoints > 0 {
+			devDays[accuracy.Ticket.StoryPoints] = append(devDays[accuracy.Ticket.StoryPoints], accuracy.DevDays)
+		}
+	}
+
+	distributions := make([]PointsDistribution, 0)
+	for points, values := range devDays {
+		distributions = append(distributions, PointsDistribution{
+			Points: points,
+			Count:  len(values),
+			Min:    Percentile(values, 0),
+			Q1:     Percentile(values, 25),
+			Median: Percentile(values, 50),
+			Q3:     Percentile(values, 75),
+			Max:    Percentile(values, 100),
+			Mean:   mean(values),
+		})
+	}
+
+	sort.Slice(distributions, func(i, j int) bool {
+		return distributions[i].Points < distributions[j].Points
+	})
+
+	return distributions
+}
+
+// Sums estimates and dev time per project
+func SummarizeTeams(accuracies []EstimateAccuracy) []TeamAccuracy {
+	teams := make(map[string]*TeamAccuracy)
+	for _, accuracy := range accuracies {
+		project := accuracy.Ticket.Project()
+		if _, ok := teams[project]; !ok {
+			teams[project] = &TeamAccuracy{Project: project}
+		}
+
+		team := teams[project]
+		team.Tickets++
+		if accuracy.Ticket.StoryPoints > 0 {
+			team.Points += accuracy.Ticket.StoryPoints
+			team.PointedDevDays += accuracy.DevDays
+		}
+		if accuracy.EstimateDays > 0 {
+			team.EstimateDays += accuracy.EstimateDays
+			team.EstimatedDevDays += accuracy.DevDays
+		}
+	}
+
+	result := make([]TeamAccuracy, 0, len(teams))
+	for _, team := range teams {
+		result = append(result, *team)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Project < result[j].Project
+	})
+
+	return result
+}
@@ -0,0 +1,155 @@
+package domain
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+const GroupByProject = "project"
+const GroupByType = "type"
+
+type FlowEfficiency struct {
+	Ticket       Ticket
+	ActiveHours  float64
+	WaitingHours float64
+	Start        time.Time // ticket became active for the first time
+	End          time.Time // ticket became done for the first time
+}
+
+// Share of active time in the cycle time, 0 when there was no time recorded
+func (f *FlowEfficiency) Efficiency() float64 {
+	if f.ActiveHours+f.WaitingHours == 0 {
+		return 0
+	}
+	return f.ActiveHours / (f.ActiveHours + f.WaitingHours)
+}
+
+type FlowEfficiencyGroup struct {
+	Keys         []string
+	Tickets      int
+	ActiveHours  float64
+	WaitingHours float64
+}
+
+func (f *FlowEfficiencyGroup) Efficiency() float64 {
+	if f.ActiveHours+f.WaitingHours == 0 {
+		return 0
+	}
+	return f.ActiveHours / (f.ActiveHours + f.WaitingHours)
+}
+
+// Calculates flow efficiency of done ticket.
+//
+// Cycle starts when ticket becomes active for the first time and ends when it becomes done. Time spent in active
+// and waiting states within the cycle is counted, states which are neither active nor waiting are not taken into
+// account. Tickets which have not been active or are not done yet are skipped.
+func CalculateFlowEfficiency(ticket Ticket, workflow Workflow) (FlowEfficiency, bool) {
+	end, done := workflow.DoneTime(ticket)
+	if !done {
+		return FlowEfficiency{}, false
+	}
+
+	efficiency := FlowEfficiency{
+		Ticket: ticket,
+		Start:  EndOfTime,
+		End:    end,
+	}
+
+	for _, interval := range ticket.Transitions {
+		if !interval.Start.Before(end) {
+			break
+		}
+
+		if workflow.IsActive(interval.State) && interval.Start.Before(efficiency.Start) {
+			efficiency.Start = interval.Start
+		}
+		if efficiency.Start == EndOfTime {
+			continue
+		}
+
+		hours := interval.End.Sub(interval.Start).Hours()
+		if workflow.IsActive(interval.State) {
+			efficiency.ActiveHours += hours
+		} else if workflow.IsWaiting(interval.State) {
+			efficiency.WaitingHours += hours
+		}
+	}
+
+	if efficiency.Start == EndOfTime {
+		return FlowEfficiency{}, false
+	}
+
+	return efficiency, true
+}
+
+// Calculates flow efficiency of tickets which became done within given boundaries
+func CalculateFlowEfficiencies(tickets []Ticket, workflow Workflow, start time.Time, end time.Time) []FlowEfficiency {
+	result := make([]FlowEfficiency, 0)
+	for _, ticket := range tickets {
+		efficiency, ok := CalculateFlowEfficiency(ticket, workflow)
+		if ok && !efficiency.End.Before(start) && efficiency.End.Before(end) {
+			result = append(result, efficiency)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].End.Before(result[j].End)
+	})
+
+	return result
+}
+
+// Aggregates flow efficiency by project, type and/or period (day, week, month) of cycle end
+func AggregateFlowEfficiency(efficiencies []FlowEfficiency, groupBy []string) ([]FlowEfficiencyGroup, error) {
+	groups := make(map[string]*FlowEfficiencyGroup)
+	order := make([]string, 0)
+
+	for _, efficiency := range efficiencies {
+		keys, err := groupKeys(efficiency.Ticket, efficiency.End, groupBy)
+		if err != nil {
+			return nil, err
+		}
+
+		id := strings.Join(keys, "|")
+		if _, ok := groups[id]; !ok {
+			groups[id] = &FlowEfficiencyGroup{Keys: keys}
+			order = append(order, id)
+		}
+
+		group := groups[id]
+		group.Tickets++
+		group.ActiveHours += efficiency.ActiveHours
+		group.WaitingHours += efficiency.WaitingHours
+	}
+
+	sort.Strings(order)
+
+	result := make([]FlowEfficiencyGroup, 0, len(order))
+	for _, id := range order {
+		result = append(result, *groups[id])
+	}
+
+	return result, nil
+}
+
+// Builds keys ticket is grouped by, dates are grouped by period they belong to
+func groupKeys(ticket Ticket, date time.Time, groupBy []string) ([]string, error) {
+	keys := make([]string, 0, len(groupBy))
+	for _, dimension := range groupBy {
+		switch dimension {
+		case GroupByProject:
+			keys = append(keys, ticket.Project())
+		case GroupByType:
+			keys = append(keys, ticket.Type)
+		default:
+			periodStart, err := PeriodStart(date, dimension)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, periodStart.Format(DayFormat))
+		}
+	}
+
+	return keys, nil
+}
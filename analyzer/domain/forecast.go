@@ -0,0 +1,130 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+const DefaultForecastTrials = 10000
+
+const maxForecastDays = 10 * 366 // simulations not reaching requested items within 10 years are abandoned
+
+var ForecastConfidences = []int{50, 70, 85, 95}
+
+// Monte Carlo forecast settings, same seed gives same results
+type Forecast struct {
+	Items      int       // number of items to be delivered, for "by which date" question
+	TargetDate time.Time // date of delivery, for "how many items" question
+	Trials     int
+	Seed       int64
+}
+
+type ForecastResult struct {
+	Confidence int
+	Items      int
+	Date       time.Time
+}
+
+// Number of tickets done on each day between given dates
+func DailyThroughput(tickets []Ticket, workflow Workflow, start time.Time, end time.Time) []int {
+	startDay, _ := PeriodStart(start, PeriodDay)
+
+	daysNo := int(math.Ceil(end.Sub(startDay).Hours() / 24))
+	if daysNo <= 0 {
+		return []int{}
+	}
+
+	throughput := make([]int, daysNo)
+	for _, ticket := range tickets {
+		doneTime, ok := workflow.DoneTime(ticket)
+		if !ok || doneTime.Before(startDay) || !doneTime.Before(end) {
+			continue
+		}
+
+		throughput[int(doneTime.Sub(startDay).Hours()/24)]++
+	}
+
+	return throughput
+}
+
+// Answers "how many items will be done by target date", counting from the day after given one. Number of items
+// for each confidence level is the number reached or exceeded in that percentage of trials.
+func (f *Forecast) ItemsByDate(history []int, from time.Time) ([]ForecastResult, error) {
+	if len(history) == 0 {
+		return nil, fmt.Errorf("no throughput history to forecast from")
+	}
+
+	fromDay, _ := PeriodStart(from, PeriodDay)
+	daysNo := int(f.TargetDate.Sub(fromDay).Hours() / 24)
+	if daysNo <= 0 {
+		return nil, fmt.Errorf("target date [%s] has to be in the future", f.TargetDate.Format(DayFormat))
+	}
+	if daysNo > maxForecastDays {
+		return nil, fmt.Errorf("target date [%s] is more than %d days ahead", f.TargetDate.Format(DayFormat), maxForecastDays)
+	}
+
+	random := rand.New(rand.NewSource(f.Seed))
+	outcomes := make([]int, f.trials())
+	for trial := range outcomes {
+		for day := 0; day < daysNo; day++ {
+			outcomes[trial] += history[random.Intn(len(history))]
+		}
+	}
+	sort.Ints(outcomes)
+
+	results := make([]ForecastResult, 0, len(ForecastConfidences))
+	for _, confidence := range ForecastConfidences {
+		idx := int(math.Floor(float64(len(outcomes)-1) * float64(100-confidence) / 100))
+		results = append(results, ForecastResult{Confidence: confidence, Items: outcomes[idx], Date: f.TargetDate})
+	}
+
+	return results, nil
+}
+
+// Answers "by which date will the items be done", counting from the day after given one. Date for each
+// confidence level is the date by which items were done in that percentage of trials, levels needing trials
+// abandoned at the forecast horizon are left out.
+func (f *Forecast) DateForItems(history []int, from time.Time) ([]ForecastResult, error) {
+	if len(history) == 0 || f.Items <= 0 {
+		return nil, fmt.Errorf("no throughput history or items to forecast")
+	}
+
+	random := rand.New(rand.NewSource(f.Seed))
+	outcomes := make([]int, f.trials())
+	for trial := range outcomes {
+		done := 0
+		for done < f.Items && outcomes[trial] < maxForecastDays {
+			done += history[random.Intn(len(history))]
+			outcomes[trial]++
+		}
+		if done < f.Items {
+			outcomes[trial] = math.MaxInt32 // abandoned, ordered after all the finished trials
+		}
+	}
+	sort.Ints(outcomes)
+
+	fromDay, _ := PeriodStart(from, PeriodDay)
+	results := make([]ForecastResult, 0, len(ForecastConfidences))
+	for _, confidence := range ForecastConfidences {
+		idx := int(math.Ceil(float64(len(outcomes)-1) * float64(confidence) / 100))
+		if outcomes[idx] > maxForecastDays {
+			break
+		}
+		results = append(results, ForecastResult{Confidence: confidence, Items: f.Items, Date: fromDay.AddDate(0, 0, outcomes[idx])})
+	}
+
+	if len(results) == 0 {
+		return nil, fmt.Errorf("%d items can not be done within %d days in most trials at historical throughput", f.Items, maxForecastDays)
+	}
+	return results, nil
+}
+
+func (f *Forecast) trials() int {
+	if f.Trials <= 0 {
+		return DefaultForecastTrials
+	}
+	return f.Trials
+}
@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/ztrue/tracerr"
+	"time"
+)
+
+// Snapshot of ticket taken by sync, versions are kept so that reports can be reproduced as of past syncs
+type TicketVersion struct {
+	Ticket   Ticket
+	SyncTime time.Time
+	Deleted  bool // ticket was deleted in Jira, Ticket holds its id only
+}
+
+// Hash of version contents regardless of sync time, versions of unchanged ticket have the same hash
+func (v TicketVersion) ContentHash() (string, error) {
+	contents, err := json.Marshal(struct {
+		Ticket  Ticket
+		Deleted bool
+	}{v.Ticket, v.Deleted})
+	if err != nil {
+		return "", tracerr.Wrap(err)
+	}
+
+	sum := sha256.Sum256(contents)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Tickets as they were at given time - latest versions synced until then, without deleted tickets,
+// in order of their first versions
+func TicketsAsOf(versions []TicketVersion, asOf time.Time) []Ticket {
+	latest := make(map[string]TicketVersion)
+	ids := make([]string, 0)
+	for _, version := range versions {
+		if version.SyncTime.After(asOf) {
+			continue
+		}
+
+		id := version.Ticket.Id
+		previous, ok := latest[id]
+		if !ok {
+			ids = append(ids, id)
+		}
+		if !ok || !version.SyncTime.Before(previous.SyncTime) {
+			latest[id] = version
+		}
+	}
+
+	tickets := make([]Ticket, 0, len(ids))
+	for _, id := range ids {
+		if !latest[id].Deleted {
+			tickets = append(tickets, latest[id].Ticket)
+		}
+	}
+	return tickets
+}
+
+// Time version superseded at given time expires at, when it is kept for retention, zero time if kept forever
+func VersionExpiry(supersededAt time.Time, retention time.Duration) time.Time {
+	if retention <= 0 {
+		return time.Time{}
+	}
+	return supersededAt.Add(retention)
+}
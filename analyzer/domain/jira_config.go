@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"fmt"
+	"github.com/andygrunwald/go-jira"
+	"strings"
+	"time"
+)
+
+const DeploymentServer = "server"
+const DeploymentCloud = "cloud"
+
+// Settings of Jira instance tickets are converted from
+type JiraConfig struct {
+	Deployment string // server or cloud, Jira Cloud identifies users by accountId only
+	Fields     CustomFields
+}
+
+var DefaultJiraConfig = JiraConfig{
+	Deployment: DeploymentServer,
+	Fields:     DefaultCustomFields,
+}
+
+func (config JiraConfig) IsCloud() bool {
+	return config.Deployment == DeploymentCloud
+}
+
+// Formats timestamps are sent in by Jira Server and Cloud, tried in order
+var jiraTimestampFormats = []string{
+	JiraTimestampFormat,
+	JiraUpdateTimestampFormat,
+	"2006-01-02T15:04:05.999999999-0700",
+	time.RFC3339Nano,
+}
+
+// Parses timestamp in any of the formats used by Jira
+func ParseJiraTime(value string) (time.Time, error) {
+	value = strings.Trim(value, "\"")
+	for _, format := range jiraTimestampFormats {
+		if timestamp, err := time.Parse(format, value); err == nil {
+			return timestamp, nil
+		}
+	}
+
+	return BeginingOfTime, fmt.Errorf("unsupported Jira timestamp [%s]", value)
+}
+
+// Identifies user by accountId in Jira Cloud and by name in Jira Server, the other one is used when missing
+func (config JiraConfig) authorId(user *jira.User) string {
+	if user == nil {
+		return UnknownAuthor
+	}
+
+	ids := []string{user.Name, user.AccountID}
+	if config.IsCloud() {
+		ids = []string{user.AccountID, user.Name}
+	}
+
+	for _, id := range ids {
+		if id != "" {
+			return id
+		}
+	}
+	return UnknownAuthor
+}
+
+// Remembers display name of user, if known
+func (config JiraConfig) addAuthorName(names map[string]string, user *jira.User) {
+	if user != nil && user.DisplayName != "" {
+		names[config.authorId(user)] = user.DisplayName
+	}
+}
+
+// Collects display names of authors from all the tickets
+func AuthorNames(tickets []Ticket) map[string]string {
+	names := make(map[string]string)
+	for _, ticket := range tickets {
+		for id, name := range ticket.AuthorNames {
+			names[id] = name
+		}
+	}
+	return names
+}
+
+// Display name of author, falls back to author id when name is not known
+func DisplayName(names map[string]string, authorId string) string {
+	if name, ok := names[authorId]; ok {
+		return name
+	}
+	return authorId
+}
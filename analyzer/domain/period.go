@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+const PeriodHour = "hour"
+const PeriodDay = "day"
+const PeriodWeek = "week"
+const PeriodMonth = "month"
+
+// Truncates date to the beginning of period it belongs to, weeks start on Monday
+func PeriodStart(date time.Time, period string) (time.Time, error) {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+
+	switch period {
+	case PeriodHour:
+		return date.Truncate(time.Hour), nil
+	case PeriodDay:
+		return day, nil
+	case PeriodWeek:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7)), nil
+	case PeriodMonth:
+		return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location()), nil
+	default:
+		return time.Time{}, fmt.Errorf("unknown period [%s]", period)
+	}
+}
+
+// Beginning of the period following given one
+func NextPeriod(periodStart time.Time, period string) time.Time {
+	switch period {
+	case PeriodHour:
+		return periodStart.Add(time.Hour)
+	case PeriodWeek:
+		return periodStart.AddDate(0, 0, 7)
+	case PeriodMonth:
+		return periodStart.AddDate(0, 1, 0)
+	default:
+		return periodStart.AddDate(0, 0, 1)
+	}
+}
@@ -0,0 +1,108 @@
+package domain
+
+import (
+	"sort"
+	"time"
+)
+
+type Rework struct {
+	Ticket              Ticket
+	BackwardTransitions int
+	ExtraDevDays        float64 // dev time spent after the ticket left development for the first time
+}
+
+type ProjectRework struct {
+	Project             string
+	Tickets             int
+	ReworkedTickets     int
+	BackwardTransitions int
+	ExtraDevDays        float64
+}
+
+// Finds tickets moved backwards in the workflow within given boundaries, sorted by most rework first
+func CalculateRework(calculator DaysCalculator, tickets []Ticket, workflow Workflow, start time.Time, end time.Time) []Rework {
+	result := make([]Rework, 0)
+	for _, ticket := range tickets {
+		rework := Rework{Ticket: ticket}
+
+		for i := 1; i < len(ticket.Transitions); i++ {
+			transitionTime := ticket.Transitions[i].Start
+			if !transitionTime.Before(start) && transitionTime.Before(end) &&
+				workflow.IsBackward(ticket.Transitions[i-1].State, ticket.Transitions[i].State) {
+				rework.BackwardTransitions++
+			}
+		}
+
+		rework.ExtraDevDays = calculator.CalculateDevDays(devAfterFirstExit(ticket), start, end)
+
+		if rework.BackwardTransitions > 0 || rework.ExtraDevDays > 0 {
+			result = append(result, rework)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].BackwardTransitions != result[j].BackwardTransitions {
+			return result[i].BackwardTransitions > result[j].BackwardTransitions
+		}
+		return result[i].ExtraDevDays > result[j].ExtraDevDays
+	})
+
+	return result
+}
+
+// Sums rework per project, sorted by most rework first
+func SummarizeProjectRework(tickets []Ticket, reworks []Rework) []ProjectRework {
+	projects := make(map[string]*ProjectRework)
+	project := func(name string) *ProjectRework {
+		if _, ok := projects[name]; !ok {
+			projects[name] = &ProjectRework{Project: name}
+		}
+		return projects[name]
+	}
+
+	for _, ticket := range tickets {
+		project(ticket.Project()).Tickets++
+	}
+
+	for _, rework := range reworks {
+		summary := project(rework.Ticket.Project())
+		summary.ReworkedTickets++
+		summary.BackwardTransitions += rework.BackwardTransitions
+		summary.ExtraDevDays += rework.ExtraDevDays
+	}
+
+	result := make([]ProjectRework, 0, len(projects))
+	for _, summary := range projects {
+		result = append(result, *summary)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].BackwardTransitions != result[j].BackwardTransitions {
+			return result[i].BackwardTransitions > result[j].BackwardTransitions
+		}
+		return result[i].Project < result[j].Project
+	})
+
+	return result
+}
+
+// Copy of ticket with dev intervals which started after ticket left development for the first time
+func devAfterFirstExit(ticket Ticket) Ticket {
+	firstExit := EndOfTime
+	for _, interval := range ticket.Transitions {
+		if interval.State == StateDev {
+			firstExit = interval.End
+			break
+		}
+	}
+
+	rework := ticket
+	rework.Transitions = make([]TransitionInterval, 0)
+	for _, interval := range ticket.Transitions {
+		if interval.State == StateDev && !interval.Start.Before(firstExit) {
+			rework.Transitions = append(rework.Transitions, interval)
+		}
+	}
+
+	return rework
+}
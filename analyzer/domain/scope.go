@@ -0,0 +1,22 @@
+package domain
+
+// Limits tickets taken into account, empty lists match every ticket
+type Scope struct {
+	Projects []string
+	Types    []string
+}
+
+func (s *Scope) Matches(ticket Ticket) bool {
+	return (len(s.Projects) == 0 || containsIgnoreCase(s.Projects, ticket.Project())) &&
+		(len(s.Types) == 0 || containsIgnoreCase(s.Types, ticket.Type))
+}
+
+func (s *Scope) Filter(tickets []Ticket) []Ticket {
+	result := make([]Ticket, 0)
+	for _, ticket := range tickets {
+		if s.Matches(ticket) {
+			result = append(result, ticket)
+		}
+	}
+	return result
+}
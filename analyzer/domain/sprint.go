@@ -0,0 +1,263 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const SprintStateClosed = "closed"
+
+type Sprint struct {
+	Id           int
+	Name         string
+	State        string
+	StartDate    time.Time
+	EndDate      time.Time
+	CompleteDate time.Time
+}
+
+// Sprint is finished when completed, planned end is used otherwise
+func (s *Sprint) FinishDate() time.Time {
+	if s.CompleteDate.After(BeginingOfTime) {
+		return s.CompleteDate
+	}
+	return s.EndDate
+}
+
+// Ticket being added to or removed from sprint
+type SprintChange struct {
+	SprintId  int
+	Added     bool
+	Timestamp time.Time
+}
+
+// Tells whether ticket was part of sprint at given time, replaying sprint changes from changelog.
+// Tickets which are in sprint but have no sprint changes recorded are assumed to be there since creation.
+func (t *Ticket) InSprintAt(sprintId int, at time.Time) bool {
+	changes := make([]SprintChange, 0)
+	for _, change := range t.SprintChanges {
+		if change.SprintId == sprintId {
+			changes = append(changes, change)
+		}
+	}
+
+	if len(changes) == 0 {
+		return containsInt(t.Sprints, sprintId) && !t.CreateTime.After(at)
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Timestamp.Before(changes[j].Timestamp)
+	})
+
+	member := !changes[0].Added // state before first change
+	for _, change := range changes {
+		if change.Timestamp.After(at) {
+			break
+		}
+		member = change.Added
+	}
+
+	return member
+}
+
+// Periods within given boundaries ticket was part of sprint, replaying sprint changes from changelog
+func (t *Ticket) SprintPeriods(sprintId int, start time.Time, end time.Time) []TransitionInterval {
+	bounds := []time.Time{start}
+	for _, change := range t.SprintChanges {
+		if change.SprintId == sprintId && change.Timestamp.After(start) && change.Timestamp.Before(end) {
+			bounds = append(bounds, change.Timestamp)
+		}
+	}
+	if t.CreateTime.After(start) && t.CreateTime.Before(end) {
+		bounds = append(bounds, t.CreateTime)
+	}
+	sort.Slice(bounds, func(i, j int) bool {
+		return bounds[i].Before(bounds[j])
+	})
+	bounds = append(bounds, end)
+
+	periods := make([]TransitionInterval, 0)
+	for i := 0; i < len(bounds)-1; i++ {
+		if !bounds[i].Before(bounds[i+1]) || !t.InSprintAt(sprintId, bounds[i]) {
+			continue
+		}
+
+		last := len(periods) - 1
+		if last >= 0 && periods[last].End.Equal(bounds[i]) {
+			periods[last].End = bounds[i+1]
+		} else {
+			periods = append(periods, TransitionInterval{Start: bounds[i], End: bounds[i+1]})
+		}
+	}
+
+	return periods
+}
+
+// Tells whether ticket was ever part of sprint
+func (t *Ticket) EverInSprint(sprintId int) bool {
+	if containsInt(t.Sprints, sprintId) {
+		return true
+	}
+
+	for _, change := range t.SprintChanges {
+		if change.SprintId == sprintId {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns state ticket was in at given time
+func (t *Ticket) StateAt(at time.Time) string {
+	for _, interval := range t.Transitions {
+		if !interval.Start.After(at) && interval.End.After(at) {
+			return interval.State
+		}
+	}
+
+	return ""
+}
+
+type SprintSummary struct {
+	Sprint      Sprint
+	Committed   []string // in sprint when it started
+	Completed   []string // in sprint and done when it finished
+	Added       []string // added after sprint start
+	Removed     []string // removed after sprint start
+	CarriedOver []string // in sprint but not done when it finished
+	DevDays     float64
+}
+
+// Summarizes sprint out of tickets which were part of it at any point of time
+func SummarizeSprint(calculator DaysCalculator, sprint Sprint, tickets []Ticket, workflow Workflow) SprintSummary {
+	summary := SprintSummary{
+		Sprint:      sprint,
+		Committed:   make([]string, 0),
+		Completed:   make([]string, 0),
+		Added:       make([]string, 0),
+		Removed:     make([]string, 0),
+		CarriedOver: make([]string, 0),
+	}
+
+	start := sprint.StartDate
+	finish := calculator.calculateEndBound(sprint.FinishDate())
+
+	for _, ticket := range tickets {
+		if !ticket.EverInSprint(sprint.Id) {
+			continue
+		}
+
+		inAtStart := ticket.InSprintAt(sprint.Id, start)
+		inAtFinish := ticket.InSprintAt(sprint.Id, finish)
+
+		if inAtStart {
+			summary.Committed = append(summary.Committed, ticket.Key)
+		}
+
+		for _, change := range ticket.SprintChanges {
+			if change.SprintId != sprint.Id || !change.Timestamp.After(start) || change.Timestamp.After(finish) {
+				continue
+			}
+
+			if change.Added && !inAtStart && !containsString(summary.Added, ticket.Key) {
+				summary.Added = append(summary.Added, ticket.Key)
+			}
+			if !change.Added && !inAtFinish && !containsString(summary.Removed, ticket.Key) {
+				summary.Removed = append(summary.Removed, ticket.Key)
+			}
+		}
+
+		if inAtFinish {
+			if workflow.IsDone(ticket.StateAt(finish)) {
+				summary.Completed = append(summary.Completed, ticket.Key)
+			} else {
+				summary.CarriedOver = append(summary.CarriedOver, ticket.Key)
+			}
+		}
+
+		for _, period := range ticket.SprintPeriods(sprint.Id, start, finish) {
+			summary.DevDays += calculator.CalculateDevDays(ticket, period.Start, period.End)
+		}
+	}
+
+	return summary
+}
+
+var sprintIdPattern = regexp.MustCompile(`id=(\d+)`)
+
+// Parses sprint custom field, which is list of serialized objects in Jira Server and list of JSON objects in Jira Cloud
+func parseSprintField(value interface{}) []int {
+	ids := make([]int, 0)
+
+	values, ok := value.([]interface{})
+	if !ok {
+		return ids
+	}
+
+	for _, sprint := range values {
+		switch sprintValue := sprint.(type) {
+		case string:
+			if match := sprintIdPattern.FindStringSubmatch(sprintValue); match != nil {
+				id, _ := strconv.Atoi(match[1])
+				ids = append(ids, id)
+			}
+		case map[string]interface{}:
+			if id, ok := sprintValue["id"].(float64); ok {
+				ids = append(ids, int(id))
+			}
+		}
+	}
+
+	return ids
+}
+
+// Translates sprint changelog item into list of sprint additions and removals
+func sprintChanges(from interface{}, to interface{}, timestamp time.Time) []SprintChange {
+	fromIds := parseSprintIds(from)
+	toIds := parseSprintIds(to)
+
+	changes := make([]SprintChange, 0)
+	for _, id := range toIds {
+		if !containsInt(fromIds, id) {
+			changes = append(changes, SprintChange{SprintId: id, Added: true, Timestamp: timestamp})
+		}
+	}
+	for _, id := range fromIds {
+		if !containsInt(toIds, id) {
+			changes = append(changes, SprintChange{SprintId: id, Added: false, Timestamp: timestamp})
+		}
+	}
+
+	return changes
+}
+
+// Parses comma separated list of sprint ids
+func parseSprintIds(value interface{}) []int {
+	ids := make([]int, 0)
+	if value == nil {
+		return ids
+	}
+
+	for _, idString := range strings.Split(fmt.Sprintf("%v", value), ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(idString))
+		if err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
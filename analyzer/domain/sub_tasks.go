@@ -0,0 +1,119 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+const ModeAll = "all"       // every ticket counted separately, sub-tasks and their parents alike
+const ModeRollUp = "rollup" // sub-task dev time added to the parent
+const ModeLeaf = "leaf"     // only tickets with no sub-tasks counted
+const ModeUnion = "union"   // overlapping dev intervals of parent and sub-tasks counted once, on the parent
+
+type TicketDevTime struct {
+	Ticket  Ticket
+	DevDays float64
+}
+
+// Calculates dev time of tickets, sub-tasks are treated according to given mode
+func CalculateDevTimes(calculator DaysCalculator, tickets []Ticket, mode string, start time.Time, end time.Time) ([]TicketDevTime, error) {
+	switch mode {
+	case ModeAll:
+		return calculateEach(calculator, tickets, func(ticket Ticket) bool { return true }, start, end), nil
+	case ModeLeaf:
+		return calculateEach(calculator, tickets, func(ticket Ticket) bool { return len(ticket.SubTaskKeys) == 0 }, start, end), nil
+	case ModeRollUp:
+		return calculateFamilies(tickets, func(parent Ticket, subTasks []Ticket) float64 {
+			devDays := calculator.CalculateDevDays(parent, start, end)
+			for _, subTask := range subTasks {
+				devDays += calculator.CalculateDevDays(subTask, start, end)
+			}
+			return devDays
+		}), nil
+	case ModeUnion:
+		return calculateFamilies(tickets, func(parent Ticket, subTasks []Ticket) float64 {
+			merged := parent
+			merged.Transitions = MergeDevIntervals(append([]Ticket{parent}, subTasks...)...)
+			return calculator.CalculateDevDays(merged, start, end)
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown sub-task mode [%s]", mode)
+	}
+}
+
+func calculateEach(calculator DaysCalculator, tickets []Ticket, include func(ticket Ticket) bool, start time.Time, end time.Time) []TicketDevTime {
+	result := make([]TicketDevTime, 0)
+	for _, ticket := range tickets {
+		if include(ticket) {
+			result = append(result, TicketDevTime{
+				Ticket:  ticket,
+				DevDays: calculator.CalculateDevDays(ticket, start, end),
+			})
+		}
+	}
+
+	return result
+}
+
+// Groups sub-tasks with their parents, parents missing from given tickets are represented by key only
+func calculateFamilies(tickets []Ticket, calculate func(parent Ticket, subTasks []Ticket) float64) []TicketDevTime {
+	parents := make(map[string]Ticket)
+	subTasks := make(map[string][]Ticket)
+	order := make([]string, 0)
+
+	for _, ticket := range tickets {
+		if ticket.ParentKey == "" {
+			parents[ticket.Key] = ticket
+		} else {
+			subTasks[ticket.ParentKey] = append(subTasks[ticket.ParentKey], ticket)
+		}
+	}
+
+	for _, ticket := range tickets {
+		key := ticket.Key
+		if ticket.ParentKey != "" {
+			key = ticket.ParentKey
+		}
+
+		if _, ok := parents[key]; !ok {
+			parents[key] = Ticket{Id: key, Key: key}
+		}
+
+		if !containsString(order, key) {
+			order = append(order, key)
+		}
+	}
+
+	result := make([]TicketDevTime, 0)
+	for _, key := range order {
+		result = append(result, TicketDevTime{
+			Ticket:  parents[key],
+			DevDays: calculate(parents[key], subTasks[key]),
+		})
+	}
+
+	return result
+}
+
+// Merges overlapping dev intervals of given tickets, so that time spent in parallel is counted once
+func MergeDevIntervals(tickets ...Ticket) []TransitionInterval {
+	intervals := make([]TransitionInterval, 0)
+	for _, ticket := range tickets {
+		for _, interval := range ticket.Transitions {
+			if interval.State == StateDev {
+				intervals = append(intervals, interval)
+			}
+		}
+	}
+
+	return mergeIntervals(intervals)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
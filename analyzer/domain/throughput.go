@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+type Throughput struct {
+	Period  time.Time
+	Project string
+	Type    string
+	Created int
+	Started int // became active for the first time
+	Done    int // became done for the first time
+}
+
+// Change of backlog size within the period
+func (t *Throughput) NetChange() int {
+	return t.Created - t.Done
+}
+
+// Counts tickets created, started and done in every period (week or month) between given dates,
+// broken down by project and issue type
+func CalculateThroughput(tickets []Ticket, workflow Workflow, start time.Time, end time.Time, period string) ([]Throughput, error) {
+	if period != PeriodWeek && period != PeriodMonth {
+		return nil, fmt.Errorf("unsupported throughput period [%s]", period)
+	}
+
+	throughputs := make(map[string]*Throughput)
+	entry := func(ticket Ticket, date time.Time) (*Throughput, error) {
+		periodStart, err := PeriodStart(date, period)
+		if err != nil {
+			return nil, err
+		}
+
+		key := fmt.Sprintf("%s|%s|%s", periodStart.Format(DayFormat), ticket.Project(), ticket.Type)
+		if _, ok := throughputs[key]; !ok {
+			throughputs[key] = &Throughput{Period: periodStart, Project: ticket.Project(), Type: ticket.Type}
+		}
+		return throughputs[key], nil
+	}
+	within := func(date time.Time) bool {
+		return !date.Before(start) && date.Before(end)
+	}
+
+	for _, ticket := range tickets {
+		if within(ticket.CreateTime) {
+			throughput, err := entry(ticket, ticket.CreateTime)
+			if err != nil {
+				return nil, err
+			}
+			throughput.Created++
+		}
+
+		if startTime, ok := workflow.StartTime(ticket); ok && within(startTime) {
+			throughput, err := entry(ticket, startTime)
+			if err != nil {
+				return nil, err
+			}
+			throughput.Started++
+		}
+
+		if doneTime, ok := workflow.DoneTime(ticket); ok && within(doneTime) {
+			throughput, err := entry(ticket, doneTime)
+			if err != nil {
+				return nil, err
+			}
+			throughput.Done++
+		}
+	}
+
+	result := make([]Throughput, 0, len(throughputs))
+	for _, throughput := range throughputs {
+		result = append(result, *throughput)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Period.Equal(result[j].Period) {
+			return result[i].Period.Before(result[j].Period)
+		}
+		if result[i].Project != result[j].Project {
+			return result[i].Project < result[j].Project
+		}
+		return result[i].Type < result[j].Type
+	})
+
+	return result, nil
+}
@@ -0,0 +1,82 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// Describes how Jira statuses are used by the team
+type Workflow struct {
+	DoneStates    []string
+	ActiveStates  []string // someone is working on the ticket
+	WaitingStates []string // ticket waits for someone to pick it up
+	StatusOrder   []string // order of statuses in the workflow, used to detect moving tickets backwards
+	BlockedStates []string
+}
+
+var DefaultWorkflow = Workflow{
+	DoneStates:    []string{"Done", "Closed", "Resolved"},
+	ActiveStates:  []string{StateDev, "In Testing", "Testing"},
+	WaitingStates: []string{"Ready For Testing", "In Review", "Ready For Release", "Blocked"},
+	StatusOrder: []string{"To Do", StateDev, "In Review", "Ready For Testing", "In Testing", "Testing",
+		"Ready For Release", "Done", "Closed", "Resolved"},
+	BlockedStates: []string{"Blocked"},
+}
+
+func (w *Workflow) IsDone(state string) bool {
+	return containsIgnoreCase(w.DoneStates, state)
+}
+
+// Tells whether transition goes back in the workflow, transitions from or to statuses out of order are not
+func (w *Workflow) IsBackward(fromState string, toState string) bool {
+	from := indexIgnoreCase(w.StatusOrder, fromState)
+	to := indexIgnoreCase(w.StatusOrder, toState)
+	return from >= 0 && to >= 0 && to < from
+}
+
+func (w *Workflow) IsActive(state string) bool {
+	return containsIgnoreCase(w.ActiveStates, state)
+}
+
+func (w *Workflow) IsBlocked(state string) bool {
+	return containsIgnoreCase(w.BlockedStates, state)
+}
+
+func (w *Workflow) IsWaiting(state string) bool {
+	return containsIgnoreCase(w.WaitingStates, state)
+}
+
+// Returns time ticket entered done state for the first time
+func (w *Workflow) DoneTime(ticket Ticket) (time.Time, bool) {
+	for _, interval := range ticket.Transitions {
+		if w.IsDone(interval.State) {
+			return interval.Start, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// Returns time ticket became active for the first time
+func (w *Workflow) StartTime(ticket Ticket) (time.Time, bool) {
+	for _, interval := range ticket.Transitions {
+		if w.IsActive(interval.State) {
+			return interval.Start, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func containsIgnoreCase(values []string, value string) bool {
+	return indexIgnoreCase(values, value) >= 0
+}
+
+func indexIgnoreCase(values []string, value string) int {
+	for i, v := range values {
+		if strings.EqualFold(v, value) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+const UnknownAuthor = "unknown"
+
+const minDiscrepancyHours = 8.0 // differences smaller than a day are not reported
+const minDiscrepancyRatio = 0.5 // difference has to be at least half of the bigger value
+
+type Worklog struct {
+	Id               string
+	Author           string
+	Started          time.Time
+	TimeSpentSeconds int
+}
+
+type WorkComparison struct {
+	LoggedHours   float64
+	InferredHours float64 // calculated from dev state transitions
+}
+
+func (w *WorkComparison) Difference() float64 {
+	return w.LoggedHours - w.InferredHours
+}
+
+// Tells whether logged and inferred time differ significantly
+func (w *WorkComparison) Discrepancy() bool {
+	difference := math.Abs(w.Difference())
+	return difference >= minDiscrepancyHours && difference >= minDiscrepancyRatio*math.Max(w.LoggedHours, w.InferredHours)
+}
+
+type TicketWork struct {
+	WorkComparison
+	Ticket Ticket
+}
+
+type PersonWork struct {
+	WorkComparison
+	Person string
+}
+
+// Logged hours of worklogs started within given boundaries
+func (t *Ticket) LoggedHours(start time.Time, end time.Time) map[string]float64 {
+	hours := make(map[string]float64)
+	for _, worklog := range t.Worklogs {
+		if !worklog.Started.Before(start) && worklog.Started.Before(end) {
+			hours[worklog.Author] += float64(worklog.TimeSpentSeconds) / 3600
+		}
+	}
+
+	return hours
+}
+
+// Compares logged and inferred time per ticket, tickets with neither are skipped
+func CompareTicketWork(calculator DaysCalculator, tickets []Ticket, start time.Time, end time.Time) []TicketWork {
+	result := make([]TicketWork, 0)
+	for _, ticket := range tickets {
+		work := TicketWork{Ticket: ticket}
+
+		for _, hours := range ticket.LoggedHours(start, end) {
+			work.LoggedHours += hours
+		}
+		work.InferredHours = calculator.CalculateDevDays(ticket, start, end) * 8
+
+		if work.LoggedHours > 0 || work.InferredHours > 0 {
+			result = append(result, work)
+		}
+	}
+
+	return result
+}
+
+// Compares logged and inferred time per person, inferred time is attributed to the author of dev state change
+func ComparePersonWork(calculator DaysCalculator, tickets []Ticket, start time.Time, end time.Time) []PersonWork {
+	people := make(map[string]*PersonWork)
+	person := func(name string) *PersonWork {
+		if name == "" {
+			name = UnknownAuthor
+		}
+		if _, ok := people[name]; !ok {
+			people[name] = &PersonWork{Person: name}
+		}
+		return people[name]
+	}
+
+	for _, ticket := range tickets {
+		for author, hours := range ticket.LoggedHours(start, end) {
+			person(author).LoggedHours += hours
+		}
+		for author, days := range calculator.CalculateDevDaysByAuthor(ticket, start, end) {
+			person(author).InferredHours += days * 8
+		}
+	}
+
+	result := make([]PersonWork, 0, len(people))
+	for _, work := range people {
+		if work.LoggedHours > 0 || work.InferredHours > 0 {
+			result = append(result, *work)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Person < result[j].Person
+	})
+
+	return result
+}
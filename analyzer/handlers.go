@@ -0,0 +1,135 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"github.com/VirtusLab/jira-stats/analyzer/telemetry"
+	"github.com/ztrue/tracerr"
+	"strings"
+	"sync"
+	"time"
+)
+
+const FetchBatchCount = 100
+
+var fetchLock sync.Mutex // fetches started by schedule and on demand must not store tickets concurrently
+
+// Handles report endpoint params, fetches data instead when forceFetch is set (of all tenants unless tenant is given),
+// returns body with its content type
+func GenerateReport(ctx context.Context, params map[string]string) (string, string, error) {
+	ctx, span := telemetry.StartSpan(ctx, "generate_report")
+	defer span.End()
+	telemetry.Infof(ctx, "Path params are: %s", params)
+
+	if strings.ToLower(params["forceFetch"]) == "true" {
+		_, err := FetchData(ctx, params["tenant"])
+		span.RecordError(err)
+		return "", "text/plain", err
+	}
+
+	request, err := ParseReportRequest(params)
+	if err != nil {
+		span.RecordError(err)
+		return "", "", tracerr.Wrap(err)
+	}
+	span.SetAttribute("report", request.Name)
+	span.SetAttribute("format", request.Format)
+	span.SetAttribute("tenant", request.Tenant)
+
+	csv, err := GetReport(ctx, request)
+	if err != nil {
+		span.RecordError(err)
+		return "", "", tracerr.Wrap(err)
+	}
+	telemetry.Infof(ctx, "Generated CSV with: %d rows...", len(csv.Rows)+1)
+
+	_, renderSpan := telemetry.StartSpan(ctx, "render_report", "rows", len(csv.Rows))
+	result, contentType, err := RenderReport(request, csv)
+	renderSpan.RecordError(err)
+	renderSpan.End()
+	if err != nil {
+		span.RecordError(err)
+		return "", "", tracerr.Wrap(err)
+	}
+
+	return result, contentType, nil
+}
+
+// Fetches batch of updated tickets of given tenant (of every tenant when empty) from Jira into DB, returns summary
+// of the fetch, it stops early when context is about to be done
+func FetchData(ctx context.Context, tenantId string) (string, error) {
+	fetchLock.Lock()
+	defer fetchLock.Unlock()
+
+	tenants, err := fetchedTenants(tenantId)
+	if err != nil {
+		return err.Error(), tracerr.Wrap(err)
+	}
+
+	ctx = telemetry.WithFields(ctx, "runId", telemetry.NewId())
+	ctx, span := telemetry.StartSpan(ctx, "fetch_data", "tenants", len(tenants))
+	defer span.End()
+
+	summaries := make([]string, 0, len(tenants))
+	failed := make([]string, 0)
+	total := 0
+	for _, tenant := range tenants {
+		tenantCtx := WithTenant(ctx, tenant)
+
+		// tenants left are fetched on next execution, there would be no time to store their progress
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= DeadlineMargin {
+			telemetry.Warnf(tenantCtx, "Deadline approached, tenant to be fetched on next execution...")
+			summaries = append(summaries, fmt.Sprintf("Tenant %s postponed", tenant.Id))
+			continue
+		}
+
+		number, err := ProcessTickets(tenantCtx, FetchBatchCount)
+		if err != nil {
+			telemetry.Errorf(tenantCtx, "%s", tracerr.Sprint(err))
+			summaries = append(summaries, fmt.Sprintf("Tenant %s failed: %s", tenant.Id, err.Error()))
+			failed = append(failed, tenant.Id)
+			continue
+		}
+
+		total += number
+		summaries = append(summaries, fmt.Sprintf("Number of processed Jiras of %s: %d", tenant.Id, number))
+	}
+	span.SetAttribute("tickets", total)
+
+	summary := strings.Join(summaries, "\n")
+	if len(failed) > 0 {
+		err := fmt.Errorf("fetch failed for tenants [%s]", strings.Join(failed, ", "))
+		span.RecordError(err)
+		return summary, tracerr.Wrap(err)
+	}
+
+	return summary, nil
+}
+
+// Tenants to fetch, all of them when no id is given
+func fetchedTenants(tenantId string) ([]Tenant, error) {
+	if tenantId == "" {
+		return Tenants()
+	}
+
+	tenant, err := LookupTenant(tenantId)
+	if err != nil {
+		return nil, err
+	}
+	return []Tenant{tenant}, nil
+}
+
+// Runs job every interval until stopped, first run happens after first interval passes
+func Schedule(interval time.Duration, job func(), stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			job()
+		case <-stop:
+			return
+		}
+	}
+}
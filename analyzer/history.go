@@ -0,0 +1,203 @@
+package analyzer
+
+import (
+	"context"
+	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"github.com/ztrue/tracerr"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Versions of tickets taken by every sync, keyed by tenant id and version key (ticket id and sync time)
+const TicketVersionTable = "TenantTicketVersion"
+
+const VersionAttribute = "Version"
+const SyncedAtAttribute = "SyncedAt"   // sync time of version in epoch nanoseconds, range key of SyncedAtIndex
+const ExpiresAtAttribute = "ExpiresAt" // DynamoDB TTL attribute, epoch seconds
+const HashAttribute = "Hash"           // hash of version contents, unchanged ticket is not stored again
+
+// Index of versions keyed by tenant id and sync time, so that reports as of given time read versions synced until then
+const SyncedAtIndex = "SyncedAtIndex"
+
+// Fixed width, so that versions of ticket are ordered by sync time
+const versionTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+const defaultHistoryRetentionDays = 365
+
+// Retention of superseded ticket versions, set with JIRA_HISTORY_RETENTION_DAYS env var, 0 keeps versions forever
+func historyRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("JIRA_HISTORY_RETENTION_DAYS"))
+	if err != nil {
+		days = defaultHistoryRetentionDays
+	}
+	if days <= 0 {
+		return 0
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Key of version of ticket synced at given time
+func versionKey(ticketId string, syncTime time.Time) string {
+	return ticketId + "#" + syncTime.UTC().Format(versionTimeFormat)
+}
+
+type asOfKey struct{}
+
+// Binds time reports are generated as of to context, tickets are then read from their versions
+func withAsOf(ctx context.Context, asOf time.Time) context.Context {
+	return context.WithValue(ctx, asOfKey{}, asOf)
+}
+
+// Time reports are generated as of, if bound to context
+func reportedAsOf(ctx context.Context) (time.Time, bool) {
+	asOf, ok := ctx.Value(asOfKey{}).(time.Time)
+	return asOf, ok
+}
+
+// Time reports measure ages and forecasts from - time reported as of when bound to context, current time otherwise
+func reportTime(ctx context.Context) time.Time {
+	if asOf, ok := reportedAsOf(ctx); ok {
+		return asOf
+	}
+	return time.Now()
+}
+
+// Stores version of ticket unless it is unchanged since the latest stored one, previous version of the ticket
+// expires once retention passes
+func storeVersion(ctx context.Context, version domain.TicketVersion) error {
+	svc := dbClient()
+
+	previous, previousHash, ok, err := latestVersion(ctx, version.Ticket.Id)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+
+	item, err := versionItem(ctx, version)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if ok && aws.StringValue(item[HashAttribute].S) == previousHash {
+		return nil
+	}
+	key := aws.StringValue(item[VersionAttribute].S)
+
+	_, err = svc.PutItemWithContext(ctx, &dynamodb.PutItemInput{Item: item, TableName: aws.String(TicketVersionTable)})
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+
+	expiry := domain.VersionExpiry(version.SyncTime, historyRetention())
+	if !ok || previous == key || expiry.IsZero() {
+		return nil
+	}
+
+	_, err = svc.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		Key:                      tenantKey(ctx, VersionAttribute, &dynamodb.AttributeValue{S: aws.String(previous)}),
+		ExpressionAttributeNames: map[string]*string{"#e": aws.String(ExpiresAtAttribute)},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":e": {N: aws.String(strconv.FormatInt(expiry.Unix(), 10))},
+		},
+		TableName:        aws.String(TicketVersionTable),
+		UpdateExpression: aws.String("set #e = :e"),
+	})
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+
+	return nil
+}
+
+// Item of version of ticket, keyed by tenant and version key
+func versionItem(ctx context.Context, version domain.TicketVersion) (map[string]*dynamodb.AttributeValue, error) {
+	item, err := tenantItem(ctx, version)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+
+	item[VersionAttribute] = &dynamodb.AttributeValue{S: aws.String(versionKey(version.Ticket.Id, version.SyncTime))}
+	item[SyncedAtAttribute] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(version.SyncTime.UnixNano(), 10))}
+
+	hash, err := version.ContentHash()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	item[HashAttribute] = &dynamodb.AttributeValue{S: aws.String(hash)}
+	return item, nil
+}
+
+// Key and content hash of the most recent version of ticket, reports whether ticket has any
+func latestVersion(ctx context.Context, ticketId string) (string, string, bool, error) {
+	svc := dbClient()
+
+	keyCondition := expression.Key(TenantAttribute).Equal(expression.Value(tenantOf(ctx).Id)).
+		And(expression.Key(VersionAttribute).BeginsWith(ticketId + "#"))
+	expr, err := expression.NewBuilder().
+		WithKeyCondition(keyCondition).
+		WithProjection(expression.NamesList(expression.Name(VersionAttribute), expression.Name(HashAttribute))).
+		Build()
+	if err != nil {
+		return "", "", false, tracerr.Wrap(err)
+	}
+
+	output, err := svc.QueryWithContext(ctx, &dynamodb.QueryInput{
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ProjectionExpression:      expr.Projection(),
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int64(1),
+		TableName:                 aws.String(TicketVersionTable),
+	})
+	if err != nil {
+		return "", "", false, tracerr.Wrap(err)
+	}
+
+	if len(output.Items) == 0 || output.Items[0][VersionAttribute] == nil {
+		return "", "", false, nil
+	}
+	latest := output.Items[0]
+	hash := ""
+	if latest[HashAttribute] != nil {
+		hash = aws.StringValue(latest[HashAttribute].S)
+	}
+	return aws.StringValue(latest[VersionAttribute].S), hash, true, nil
+}
+
+// Fetch tickets as they were at given time out of their versions, only versions synced until then are read and
+// only the latest one of every ticket is kept
+func fetchTicketsAsOf(ctx context.Context, asOf time.Time) ([]domain.Ticket, error) {
+	ctx, done := trackPhase(ctx, "db_scan_versions")
+	defer done()
+
+	syncedUntil := expression.Key(SyncedAtAttribute).LessThanEqual(expression.Value(asOf.UnixNano()))
+
+	latest := make(map[string]int)
+	versions := make([]domain.TicketVersion, 0)
+	err := queryIndex(ctx, TicketVersionTable, SyncedAtIndex, &syncedUntil, nil, func(item map[string]*dynamodb.AttributeValue) error {
+		var version domain.TicketVersion
+		err := dynamodbattribute.UnmarshalMap(item, &version)
+		if err != nil {
+			return err
+		}
+
+		if index, ok := latest[version.Ticket.Id]; ok {
+			if !version.SyncTime.Before(versions[index].SyncTime) {
+				versions[index] = version
+			}
+			return nil
+		}
+		latest[version.Ticket.Id] = len(versions)
+		versions = append(versions, version)
+		return nil
+	})
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+
+	return domain.TicketsAsOf(versions, asOf), nil
+}
@@ -1,44 +1,32 @@
 package analyzer
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/VirtusLab/jira-stats/analyzer/telemetry"
 	"github.com/andygrunwald/go-jira"
 	"github.com/ztrue/tracerr"
-	"log"
-	"os"
+	"net/http"
 	"time"
 )
 
-// fetches tickets from analyzer
-func fetch(updatedSince time.Time, batchCount int) ([]jira.Issue, error) {
-	defer timeTrack(time.Now(), "Fetching Jira issues")
+const JiraUrl = "https://jira.adstream.com"
 
-	tp, err := jiraAuth()
-	if err != nil {
-		return nil, tracerr.Wrap(err)
-	}
+// fetches tickets of tenant from analyzer
+func fetch(ctx context.Context, updatedSince time.Time, batchCount int) ([]jira.Issue, error) {
+	ctx, done := trackPhase(ctx, "fetch_issues")
+	defer done()
 
-	client, err := jira.NewClient(tp.Client(), "https://jira.adstream.com")
+	client, err := jiraClient(ctx)
 	if err != nil {
 		return nil, tracerr.Wrap(err)
 	}
 
-	jqlQuery :=
-		fmt.Sprintf("(project in (\"Traffic & Ordering\", \"Amazing Delivery\", ROB) OR "+
-			"kanban = \"Traffic & Ordering\" OR "+
-			"labels in (traffic-external, traffic-team) "+
-			") AND "+
-			"project != NIR AND "+
-			"NOT (project = DL AND status = Closed) AND "+
-			"updated >= \"%s\" "+
-			"ORDER BY updated ASC",
-
-			updatedSince.Format(domain.JiraFilterFormat),
-		)
+	jqlQuery := fmt.Sprintf("(%s) AND updated >= \"%s\" ORDER BY updated ASC",
+		tenantOf(ctx).Query, updatedSince.Format(domain.JiraFilterFormat))
 
-	log.Printf("Jira query used: %s\n", jqlQuery)
+	telemetry.Infof(ctx, "Jira query used: %s", jqlQuery)
 
 	searchOpts := jira.SearchOptions{MaxResults: batchCount, Expand: "changelog"}
 	issues, _, err := client.Issue.Search(jqlQuery, &searchOpts)
@@ -49,36 +37,141 @@ func fetch(updatedSince time.Time, batchCount int) ([]jira.Issue, error) {
 	return issues, nil
 }
 
-// Builds analyzer client (fetching creds either from env vars or AWS Secret Manager)
-func jiraAuth() (jira.BasicAuthTransport, error) {
-
-	if os.Getenv("JIRA_USER") != "" {
-		log.Printf("Fetching creds from local vars...")
-		return jira.BasicAuthTransport{
-			Username: os.Getenv("JIRA_USER"),
-			Password: os.Getenv("JIRA_PASSWORD"),
-		}, nil
-	} else {
-		log.Printf("Fetching creds from Secret Manager...")
-		secrets, err := RetrieveSecrets()
+// fetches single issue with its changelog and all its worklogs
+func fetchIssue(ctx context.Context, key string) (jira.Issue, error) {
+	client, err := jiraClient(ctx)
+	if err != nil {
+		return jira.Issue{}, tracerr.Wrap(err)
+	}
+
+	issue, _, err := client.Issue.Get(key, &jira.GetQueryOptions{Expand: "changelog"})
+	if err != nil {
+		return jira.Issue{}, tracerr.Wrap(err)
+	}
+
+	issues := []jira.Issue{*issue}
+	if err := fetchWorklogs(ctx, issues); err != nil {
+		return jira.Issue{}, tracerr.Wrap(err)
+	}
+
+	return issues[0], nil
+}
+
+const WorklogPageSize = 100
+
+// completes worklogs of issues - search returns only the first page of them
+func fetchWorklogs(ctx context.Context, issues []jira.Issue) error {
+	ctx, done := trackPhase(ctx, "fetch_worklogs")
+	defer done()
+
+	client, err := jiraClient(ctx)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+
+	for i := range issues {
+		worklog := issues[i].Fields.Worklog
+		if worklog != nil && len(worklog.Worklogs) >= worklog.Total {
+			continue
+		}
+
+		records, err := fetchIssueWorklogs(client, issues[i].ID)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+
+		issues[i].Fields.Worklog = &jira.Worklog{
+			Total:      len(records),
+			MaxResults: len(records),
+			Worklogs:   records,
+		}
+	}
+
+	return nil
+}
+
+// fetches all the worklogs of issue, page by page
+func fetchIssueWorklogs(client *jira.Client, issueId string) ([]jira.WorklogRecord, error) {
+	records := make([]jira.WorklogRecord, 0)
+
+	for {
+		apiEndpoint := fmt.Sprintf("rest/api/2/issue/%s/worklog?startAt=%d&maxResults=%d", issueId, len(records), WorklogPageSize)
+		req, err := client.NewRequest("GET", apiEndpoint, nil)
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+
+		page := jira.Worklog{}
+		_, err = client.Do(req, &page)
 		if err != nil {
-			return jira.BasicAuthTransport{}, tracerr.Wrap(err)
+			return nil, tracerr.Wrap(err)
 		}
 
-		type Creds struct {
-			Username string `json:"username"`
-			Password string `json:"password"`
+		records = append(records, page.Worklogs...)
+		if len(page.Worklogs) == 0 || len(records) >= page.Total {
+			return records, nil
 		}
+	}
+}
 
-		creds := Creds{}
-		err = json.Unmarshal(secrets, &creds)
+// fetches sprints with given ids using Jira Agile API
+func fetchSprints(ctx context.Context, sprintIds []int) ([]domain.Sprint, error) {
+	ctx, done := trackPhase(ctx, "fetch_sprints", "sprints", len(sprintIds))
+	defer done()
+
+	client, err := jiraClient(ctx)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+
+	sprints := make([]domain.Sprint, 0)
+	for _, sprintId := range sprintIds {
+		req, err := client.NewRequest("GET", fmt.Sprintf("rest/agile/1.0/sprint/%d", sprintId), nil)
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+
+		jiraSprint := jira.Sprint{}
+		resp, err := client.Do(req, &jiraSprint)
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			telemetry.Warnf(ctx, "Sprint %d not found, skipping...", sprintId)
+			continue
+		}
 		if err != nil {
-			return jira.BasicAuthTransport{}, tracerr.Wrap(err)
+			return nil, tracerr.Wrap(err)
 		}
 
-		return jira.BasicAuthTransport{
-			Username: creds.Username,
-			Password: creds.Password,
-		}, nil
+		sprints = append(sprints, domain.Sprint{
+			Id:           jiraSprint.ID,
+			Name:         jiraSprint.Name,
+			State:        jiraSprint.State,
+			StartDate:    timeOrDefault(jiraSprint.StartDate, domain.BeginingOfTime),
+			EndDate:      timeOrDefault(jiraSprint.EndDate, domain.EndOfTime),
+			CompleteDate: timeOrDefault(jiraSprint.CompleteDate, domain.BeginingOfTime),
+		})
 	}
+
+	return sprints, nil
+}
+
+func timeOrDefault(value *time.Time, defaultValue time.Time) time.Time {
+	if value == nil {
+		return defaultValue
+	}
+	return *value
+}
+
+// Builds Jira client of tenant, all its requests are bound to given context
+func jiraClient(ctx context.Context) (*jira.Client, error) {
+	httpClient, err := jiraAuth(ctx)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+
+	client, err := jira.NewClient(httpClient, tenantOf(ctx).JiraUrl)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+
+	return client, nil
 }
@@ -1,11 +1,12 @@
 package analyzer
 
 import (
+	"context"
 	"fmt"
 	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/VirtusLab/jira-stats/analyzer/telemetry"
 	"github.com/andygrunwald/go-jira"
 	"github.com/ztrue/tracerr"
-	"log"
 	"time"
 )
 
@@ -13,68 +14,134 @@ const GoogleSpreadsheetFormat = "2006-01-02 15:04:05"
 
 const MaxBatchSize = 400
 
-// Fetches data from Jira and stores it in DB
-func ProcessTickets(batchCount int) (int, error) {
+// Time reserved before context deadline (e.g. of lambda invocation) to checkpoint progress and return
+const DeadlineMargin = 3 * time.Second
+
+// Fetches data of tenant bound to context from Jira and stores it in DB, stops early when context deadline approaches
+// and returns number of tickets stored so far
+func ProcessTickets(ctx context.Context, batchCount int) (int, error) {
 	if batchCount > MaxBatchSize {
 		return -1, fmt.Errorf("requested batch size [%d] bigger than allowed limit [%d]", batchCount, MaxBatchSize)
 	}
 
-	count, err := processLoop(batchCount)
+	count, complete, err := processLoop(ctx, batchCount)
 	if err != nil {
 		return -1, tracerr.Wrap(err)
 	}
 
-	if count < batchCount {
-		log.Printf("Read all the issues up to date...")
+	if !complete {
+		telemetry.Warnf(ctx, "Deadline approached, remaining issues to be read on next execution...")
+	} else if count < batchCount {
+		telemetry.Infof(ctx, "Read all the issues up to date...")
 	} else {
-		log.Printf("More issues most likely to be read on next execution...")
+		telemetry.Infof(ctx, "More issues most likely to be read on next execution...")
 	}
 
 	return count, nil
 }
 
-func processLoop(batchCount int) (int, error) {
+// Processes single batch, reports whether all the fetched tickets were stored. When deadline approaches before
+// tickets are stored (e.g. while fetching them or their worklogs) the batch is dropped, it is fetched again on next
+// execution.
+func processLoop(ctx context.Context, batchCount int) (int, bool, error) {
+	// work has to end before deadline, so that there is time left to store progress
+	workCtx, cancel := withDeadlineMargin(ctx, DeadlineMargin)
+	defer cancel()
+
+	// versions of tickets stored by this run are marked with its start
+	syncTime := time.Now()
+
 	// gets last update to figure out where to start with fetching
-	lastUpdate, err := getLastUpdate()
+	lastUpdate, err := getLastUpdate(workCtx)
 	if err != nil {
-		return -1, tracerr.Wrap(err)
+		return interrupted(ctx, workCtx, err)
 	}
-	log.Printf("Last update is: %s\n", lastUpdate.Format(time.RFC3339))
+	telemetry.Infof(ctx, "Last update is: %s", lastUpdate.Format(time.RFC3339))
 
 	// fetches tickets
-	jiraTickets, err := fetch(lastUpdate, batchCount)
+	jiraTickets, err := fetch(workCtx, lastUpdate, batchCount)
 	if err != nil {
-		return -1, tracerr.Wrap(err)
+		return interrupted(ctx, workCtx, err)
+	}
+	telemetry.Count(MetricTicketsFetched, float64(len(jiraTickets)), "tenant", tenantOf(ctx).Id)
+
+	// completes worklogs, which are only partially returned by search
+	err = fetchWorklogs(workCtx, jiraTickets)
+	if err != nil {
+		return interrupted(ctx, workCtx, err)
 	}
 
 	// converts Jira issues to model
-	tickets, err := transformToModel(jiraTickets)
+	tickets, err := transformToModel(workCtx, jiraTickets)
+	if err != nil {
+		return -1, false, err
+	}
+
+	// refreshes sprints tickets belong to, before tickets so that stored tickets always have their sprints
+	err = storeSprints(workCtx, tickets)
 	if err != nil {
-		return -1, err
+		return interrupted(ctx, workCtx, err)
 	}
 
-	// stores in db
-	mostRecentUpdate, err := storeTickets(tickets)
+	// stores in db, tickets come ordered by update time so the stored ones are always the oldest
+	stored, mostRecentUpdate, err := storeTickets(workCtx, tickets, syncTime)
 	if err != nil {
-		return -1, err
+		return -1, false, err
 	}
+	telemetry.Count(MetricTicketsStored, float64(stored), "tenant", tenantOf(ctx).Id)
+	telemetry.Count(MetricTicketsSkipped, float64(len(tickets)-stored), "tenant", tenantOf(ctx).Id)
+	recordSyncLag(ctx, stored == len(tickets) && len(tickets) < batchCount, lastUpdate, mostRecentUpdate)
 
-	// updates update time for next round
-	processTicketsNo := len(tickets)
+	if stored == 0 {
+		return 0, len(tickets) == 0, nil
+	}
 
-	err = storeLastUpdate(mostRecentUpdate)
+	// updates update time for next round, with original context as work one may be already done
+	err = storeLastUpdate(ctx, mostRecentUpdate)
 	if err != nil {
-		return processTicketsNo, tracerr.Wrap(err)
+		return stored, false, tracerr.Wrap(err)
 	}
 
-	log.Printf("Processed %d tickets...\n", processTicketsNo)
-	return processTicketsNo, nil
+	telemetry.Infof(ctx, "Processed %d of %d tickets...", stored, len(tickets))
+	return stored, stored == len(tickets), nil
 }
 
-func transformToModel(jiraTickets []jira.Issue) (tickets []domain.Ticket, err error) {
-	defer timeTrack(time.Now(), fmt.Sprintf("Converting %d tickets to model", len(jiraTickets)))
+// Result of batch failed before any ticket was stored, failure caused by work context ending before the original
+// one is not an error but an incomplete batch
+func interrupted(ctx context.Context, workCtx context.Context, err error) (int, bool, error) {
+	if workCtx.Err() != nil && ctx.Err() == nil {
+		telemetry.Warnf(ctx, "Batch interrupted before storing tickets: %v", err)
+		return 0, false, nil
+	}
+	return -1, false, tracerr.Wrap(err)
+}
+
+// Records how far DB is behind Jira, there is no lag once all the updated tickets were read
+func recordSyncLag(ctx context.Context, upToDate bool, lastUpdate time.Time, mostRecentUpdate time.Time) {
+	lag := time.Duration(0)
+	if !upToDate {
+		if mostRecentUpdate.Before(lastUpdate) {
+			mostRecentUpdate = lastUpdate
+		}
+		lag = time.Since(mostRecentUpdate)
+	}
+	telemetry.Gauge(MetricSyncLag, lag.Seconds(), "tenant", tenantOf(ctx).Id)
+}
 
-	tickets, err = BuildModel(jiraTickets)
+// Derives context ending margin before deadline of given one, if it has any
+func withDeadlineMargin(ctx context.Context, margin time.Duration) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, deadline.Add(-margin))
+}
+
+func transformToModel(ctx context.Context, jiraTickets []jira.Issue) (tickets []domain.Ticket, err error) {
+	ctx, done := trackPhase(ctx, "convert_tickets", "tickets", len(jiraTickets))
+	defer done()
+
+	tickets, err = buildModel(jiraTickets, tenantOf(ctx).JiraConfig())
 	if err != nil {
 		return nil, err
 	}
@@ -82,16 +149,27 @@ func transformToModel(jiraTickets []jira.Issue) (tickets []domain.Ticket, err er
 	return tickets, nil
 }
 
-func storeTickets(tickets []domain.Ticket) (lastUpdateTime time.Time, err error) {
-	defer timeTrack(time.Now(), fmt.Sprintf("Storing %d tickets", len(tickets)))
+// Stores tickets as synced at given time until context is done, returns number of stored ones and their most recent
+// update time
+func storeTickets(ctx context.Context, tickets []domain.Ticket, syncTime time.Time) (stored int, lastUpdateTime time.Time, err error) {
+	ctx, done := trackPhase(ctx, "store_tickets", "tickets", len(tickets))
+	defer done()
 
 	mostRecentUpdate := domain.BeginingOfTime
 	// stores new model
 	for _, ticket := range tickets {
-		err = store(ticket)
+		if ctx.Err() != nil {
+			break
+		}
+
+		err = store(ctx, ticket, syncTime)
 		if err != nil {
-			return time.Time{}, err
+			if ctx.Err() != nil {
+				break // interrupted write is repeated on next execution
+			}
+			return 0, time.Time{}, err
 		}
+		stored++
 
 		updateTime := ticket.UpdateTime
 
@@ -100,21 +178,71 @@ func storeTickets(tickets []domain.Ticket) (lastUpdateTime time.Time, err error)
 		}
 	}
 
-	return mostRecentUpdate, nil
+	return stored, mostRecentUpdate, nil
 }
 
-// transforms analyzer tickets to model
+// Fetches sprints referenced by tickets and stores them, closed sprints already stored are not fetched again
+func storeSprints(ctx context.Context, tickets []domain.Ticket) error {
+	ctx, done := trackPhase(ctx, "store_sprints")
+	defer done()
+
+	storedSprints, err := fetchAllSprints(ctx)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+
+	closedSprints := make(map[int]bool)
+	for _, sprint := range storedSprints {
+		closedSprints[sprint.Id] = sprint.State == domain.SprintStateClosed
+	}
+
+	sprintIds := make([]int, 0)
+	requested := make(map[int]bool)
+	for _, ticket := range tickets {
+		ids := append([]int{}, ticket.Sprints...)
+		for _, change := range ticket.SprintChanges {
+			ids = append(ids, change.SprintId)
+		}
+
+		for _, id := range ids {
+			if !closedSprints[id] && !requested[id] {
+				requested[id] = true
+				sprintIds = append(sprintIds, id)
+			}
+		}
+	}
+
+	sprints, err := fetchSprints(ctx, sprintIds)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+
+	for _, sprint := range sprints {
+		err = storeSprint(ctx, sprint)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+
+	return nil
+}
+
+// transforms analyzer tickets to model, with Jira settings configured with env vars
 func BuildModel(jiraIssues []jira.Issue) ([]domain.Ticket, error) {
+	return buildModel(jiraIssues, jiraConfig())
+}
+
+func buildModel(jiraIssues []jira.Issue, config domain.JiraConfig) ([]domain.Ticket, error) {
 	domainTickets := make([]domain.Ticket, 0)
 	for _, issue := range jiraIssues {
-		domainTicket, err := domain.JiraToDomain(issue)
+		domainTicket, err := domain.JiraToDomain(issue, config)
 		if err != nil {
 			return nil, err
 		}
 
 		updateString := domainTicket.UpdateTime.Format(time.RFC3339)
 
-		log.Printf("Ticket: %s, key: %s, no of transitions: %d (updated at %s)",
+		telemetry.Debugf(context.Background(), "Ticket: %s, key: %s, no of transitions: %d (updated at %s)",
 			domainTicket.Id, domainTicket.Key, len(domainTicket.Transitions), updateString)
 
 		domainTickets = append(domainTickets, domainTicket)
@@ -0,0 +1,450 @@
+package analyzer
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/VirtusLab/jira-stats/analyzer/telemetry"
+	"github.com/andygrunwald/go-jira"
+	"github.com/ztrue/tracerr"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const AuthBasic = "basic"   // username and password, or email and API token in Jira Cloud
+const AuthPat = "pat"       // Personal Access Token sent as bearer token
+const AuthOAuth1 = "oauth1" // OAuth 1.0a with RSA-SHA1 signed requests
+const AuthOAuth2 = "oauth2" // OAuth 2.0 access token, refreshed with refresh token when given
+
+const OAuth2TokenPath = "rest/oauth2/latest/token"
+const tokenExpiryMargin = time.Minute
+
+// Jira credentials, either from env vars or from AWS Secret Manager (JSON keys)
+type jiraCreds struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email"`
+	ApiToken string `json:"apiToken"`
+	Token    string `json:"token"` // Personal Access Token
+
+	ConsumerKey  string `json:"consumerKey"`
+	PrivateKey   string `json:"privateKey"` // PEM encoded RSA key of OAuth 1.0a consumer
+	AccessToken  string `json:"accessToken"`
+	ClientId     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+	RefreshToken string `json:"refreshToken"`
+	TokenUrl     string `json:"tokenUrl"`
+}
+
+// Reads credentials from env vars, reports whether any of them were set
+func credsFromEnv() (jiraCreds, bool) {
+	creds := jiraCreds{
+		Username:     os.Getenv("JIRA_USER"),
+		Password:     os.Getenv("JIRA_PASSWORD"),
+		Email:        os.Getenv("JIRA_EMAIL"),
+		ApiToken:     os.Getenv("JIRA_API_TOKEN"),
+		Token:        os.Getenv("JIRA_TOKEN"),
+		ConsumerKey:  os.Getenv("JIRA_OAUTH_CONSUMER_KEY"),
+		PrivateKey:   os.Getenv("JIRA_OAUTH_PRIVATE_KEY"),
+		AccessToken:  os.Getenv("JIRA_OAUTH_ACCESS_TOKEN"),
+		ClientId:     os.Getenv("JIRA_OAUTH_CLIENT_ID"),
+		ClientSecret: os.Getenv("JIRA_OAUTH_CLIENT_SECRET"),
+		RefreshToken: os.Getenv("JIRA_OAUTH_REFRESH_TOKEN"),
+		TokenUrl:     os.Getenv("JIRA_OAUTH_TOKEN_URL"),
+	}
+
+	return creds, creds != jiraCreds{}
+}
+
+// Builds authenticated HTTP client for Jira of tenant (fetching creds either from env vars or secrets provider),
+// requests are cancelled along with given context
+func jiraAuth(ctx context.Context) (*http.Client, error) {
+	tenant := tenantOf(ctx)
+
+	creds, ok := jiraCreds{}, false
+	if tenant.envCreds {
+		creds, ok = credsFromEnv()
+	}
+	var store func(ctx context.Context, refreshToken string) error
+	if ok {
+		telemetry.Infof(ctx, "Fetching creds from local vars...")
+	} else {
+		store = storeRefreshToken
+		telemetry.Infof(ctx, "Fetching creds from secrets provider...")
+		secrets, err := RetrieveSecrets(ctx)
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+
+		err = json.Unmarshal(secrets, &creds)
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+	}
+
+	if creds.TokenUrl == "" {
+		creds.TokenUrl = strings.TrimSuffix(tenant.JiraUrl, "/") + "/" + OAuth2TokenPath
+	}
+
+	config := transportConfig()
+	transport, err := authTransport(tenant, creds, store, &tracingTransport{transport: timeoutTransport(config.Timeout)})
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+
+	if store != nil { // credentials read from secrets provider
+		transport = &invalidatingTransport{transport: transport}
+	}
+
+	state := jiraTransportState(tenant.JiraUrl)
+	return &http.Client{Transport: &contextTransport{ctx: ctx, transport: newResilientTransport(transport, config, state)}}, nil
+}
+
+// Reads authentication method from env var, basic auth is used by default
+func jiraAuthMethod() string {
+	if os.Getenv("JIRA_AUTH") != "" {
+		return strings.ToLower(os.Getenv("JIRA_AUTH"))
+	}
+	return AuthBasic
+}
+
+// Builds transport authenticating requests of tenant sent with given transport, rotated OAuth 2.0 refresh tokens
+// are written back with store, they are kept in memory only when it is nil
+func authTransport(tenant Tenant, creds jiraCreds, store func(ctx context.Context, refreshToken string) error,
+	transport http.RoundTripper) (http.RoundTripper, error) {
+	switch tenant.Auth {
+	case AuthBasic:
+		if tenant.JiraConfig().IsCloud() {
+			return &jira.BasicAuthTransport{Username: creds.Email, Password: creds.ApiToken, Transport: transport}, nil
+		}
+		return &jira.BasicAuthTransport{Username: creds.Username, Password: creds.Password, Transport: transport}, nil
+	case AuthPat:
+		return &bearerTransport{token: creds.Token, transport: transport}, nil
+	case AuthOAuth1:
+		key, err := parsePrivateKey(creds.PrivateKey)
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		return &oauth1Transport{consumerKey: creds.ConsumerKey, privateKey: key, token: creds.AccessToken, transport: transport}, nil
+	case AuthOAuth2:
+		if creds.RefreshToken == "" {
+			return &bearerTransport{token: creds.AccessToken, transport: transport}, nil
+		}
+
+		return &oauth2Transport{
+			tokenUrl:     creds.TokenUrl,
+			clientId:     creds.ClientId,
+			clientSecret: creds.ClientSecret,
+			tokens:       tenantOAuth2Tokens(tenant.Id, creds),
+			store:        store,
+			transport:    transport,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown Jira auth method [%s]", tenant.Auth)
+	}
+}
+
+// Checks credentials of tenant by fetching currently authenticated user, returns its description
+func CheckAuth(ctx context.Context) (string, error) {
+	client, err := jiraClient(ctx)
+	if err != nil {
+		return "", tracerr.Wrap(err)
+	}
+
+	req, err := client.NewRequest("GET", "rest/api/2/myself", nil)
+	if err != nil {
+		return "", tracerr.Wrap(err)
+	}
+
+	user := jira.User{}
+	resp, err := client.Do(req, &user)
+	if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+		return "", fmt.Errorf("Jira rejected credentials of [%s] auth", tenantOf(ctx).Auth)
+	}
+	if err != nil {
+		return "", tracerr.Wrap(err)
+	}
+
+	id := user.Name
+	if id == "" {
+		id = user.AccountID
+	}
+	return fmt.Sprintf("Authenticated as %s (%s) with [%s] auth", user.DisplayName, id, tenantOf(ctx).Auth), nil
+}
+
+func baseTransport(transport http.RoundTripper) http.RoundTripper {
+	if transport == nil {
+		return http.DefaultTransport
+	}
+	return transport
+}
+
+// Sends token as bearer token, used for Personal Access Tokens and OAuth 2.0 access tokens
+type bearerTransport struct {
+	token     string
+	transport http.RoundTripper
+}
+
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	return baseTransport(t.transport).RoundTrip(req)
+}
+
+// OAuth 2.0 tokens of tenant, kept for the lifetime of process so that access token is reused by every Jira client
+// of tenant and rotated refresh token is not lost
+type oauth2Tokens struct {
+	lock         sync.Mutex
+	seed         string // refresh token last read from credentials or written back to them
+	refreshToken string
+	accessToken  string
+	expiry       time.Time
+}
+
+var oauth2TokenStates = struct {
+	lock   sync.Mutex
+	tokens map[string]*oauth2Tokens
+}{tokens: make(map[string]*oauth2Tokens)}
+
+// Tokens of tenant obtained by given client, refresh token is taken from credentials only when they were changed
+// since it was read last time
+func tenantOAuth2Tokens(tenantId string, creds jiraCreds) *oauth2Tokens {
+	oauth2TokenStates.lock.Lock()
+	key := tenantId + "|" + creds.TokenUrl + "|" + creds.ClientId
+	tokens, ok := oauth2TokenStates.tokens[key]
+	if !ok {
+		tokens = &oauth2Tokens{}
+		oauth2TokenStates.tokens[key] = tokens
+	}
+	oauth2TokenStates.lock.Unlock()
+
+	tokens.lock.Lock()
+	defer tokens.lock.Unlock()
+
+	if tokens.seed != creds.RefreshToken {
+		tokens.seed = creds.RefreshToken
+		tokens.refreshToken = creds.RefreshToken
+		tokens.accessToken = ""
+	}
+	return tokens
+}
+
+// Writes rotated refresh token back to credentials secret of tenant, other credentials are kept as they are
+func storeRefreshToken(ctx context.Context, refreshToken string) error {
+	secret, err := RetrieveSecrets(ctx)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(secret, &fields); err != nil {
+		return tracerr.Wrap(err)
+	}
+	fields["refreshToken"] = refreshToken
+
+	secret, err = json.Marshal(fields)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	return storeSecrets(ctx, secret)
+}
+
+// Invalidates cached secrets once Jira rejects credentials, e.g. after they were rotated
+type invalidatingTransport struct {
+	transport http.RoundTripper
+}
+
+func (t *invalidatingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.transport.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		invalidateSecrets(req.Context())
+	}
+	return resp, err
+}
+
+// Obtains OAuth 2.0 access tokens with refresh token and sends them as bearer tokens, until they expire
+type oauth2Transport struct {
+	tokenUrl     string
+	clientId     string
+	clientSecret string
+	tokens       *oauth2Tokens
+	store        func(ctx context.Context, refreshToken string) error // writes rotated refresh token back, if possible
+	transport    http.RoundTripper
+}
+
+func (t *oauth2Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return baseTransport(t.transport).RoundTrip(req)
+}
+
+func (t *oauth2Transport) token(ctx context.Context) (string, error) {
+	tokens := t.tokens
+	tokens.lock.Lock()
+	defer tokens.lock.Unlock()
+
+	if tokens.accessToken != "" && time.Now().Add(tokenExpiryMargin).Before(tokens.expiry) {
+		return tokens.accessToken, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {tokens.refreshToken},
+		"client_id":     {t.clientId},
+		"client_secret": {t.clientSecret},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.tokenUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", tracerr.Wrap(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := baseTransport(t.transport).RoundTrip(req)
+	if err != nil {
+		return "", tracerr.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("OAuth 2.0 token refresh failed with status [%d]", resp.StatusCode)
+	}
+
+	var token struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", tracerr.Wrap(err)
+	}
+
+	tokens.accessToken = token.AccessToken
+	tokens.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	if token.RefreshToken != "" && token.RefreshToken != tokens.refreshToken { // refresh tokens might be rotated
+		tokens.refreshToken = token.RefreshToken
+		t.storeRotated(ctx, token.RefreshToken)
+	}
+
+	return tokens.accessToken, nil
+}
+
+// Writes rotated refresh token back to credentials, previous one might not be accepted any more once process ends
+func (t *oauth2Transport) storeRotated(ctx context.Context, refreshToken string) {
+	if t.store == nil {
+		telemetry.Warnf(ctx, "OAuth 2.0 refresh token was rotated, it is kept in memory only as credentials are not read from secrets provider")
+		return
+	}
+
+	if err := t.store(ctx, refreshToken); err != nil {
+		telemetry.Warnf(ctx, "OAuth 2.0 refresh token was rotated, but could not be stored: %s", err.Error())
+		return
+	}
+	t.tokens.seed = refreshToken
+}
+
+// Signs requests with OAuth 1.0a RSA-SHA1 signature, as required by Jira application links
+type oauth1Transport struct {
+	consumerKey string
+	privateKey  *rsa.PrivateKey
+	token       string
+	transport   http.RoundTripper
+}
+
+func (t *oauth1Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	params := map[string]string{
+		"oauth_consumer_key":     t.consumerKey,
+		"oauth_nonce":            base64.RawURLEncoding.EncodeToString(nonce),
+		"oauth_signature_method": "RSA-SHA1",
+		"oauth_timestamp":        strconv.FormatInt(time.Now().Unix(), 10),
+		"oauth_token":            t.token,
+		"oauth_version":          "1.0",
+	}
+
+	digest := sha1.Sum([]byte(OAuth1BaseString(req, params)))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, t.privateKey, crypto.SHA1, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	params["oauth_signature"] = base64.StdEncoding.EncodeToString(signature)
+
+	header := make([]string, 0, len(params))
+	for key, value := range params {
+		header = append(header, fmt.Sprintf(`%s="%s"`, oauthEscape(key), oauthEscape(value)))
+	}
+	sort.Strings(header)
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "OAuth "+strings.Join(header, ", "))
+	return baseTransport(t.transport).RoundTrip(req)
+}
+
+// Builds signature base string out of method, URL without query and sorted query and OAuth params (RFC 5849)
+func OAuth1BaseString(req *http.Request, oauthParams map[string]string) string {
+	params := make([]string, 0)
+	for key, values := range req.URL.Query() {
+		for _, value := range values {
+			params = append(params, oauthEscape(key)+"="+oauthEscape(value))
+		}
+	}
+	for key, value := range oauthParams {
+		params = append(params, oauthEscape(key)+"="+oauthEscape(value))
+	}
+	sort.Strings(params)
+
+	baseUrl := strings.ToLower(req.URL.Scheme) + "://" + strings.ToLower(req.URL.Host) + req.URL.EscapedPath()
+	return strings.ToUpper(req.Method) + "&" + oauthEscape(baseUrl) + "&" + oauthEscape(strings.Join(params, "&"))
+}
+
+// Percent-encodes value as required by OAuth 1.0a (RFC 3986 unreserved characters are kept)
+func oauthEscape(value string) string {
+	escaped := url.QueryEscape(value)
+	escaped = strings.ReplaceAll(escaped, "+", "%20")
+	escaped = strings.ReplaceAll(escaped, "*", "%2A")
+	return strings.ReplaceAll(escaped, "%7E", "~")
+}
+
+// Parses PEM encoded RSA private key, either in PKCS#1 or PKCS#8 format
+func parsePrivateKey(encoded string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil {
+		return nil, fmt.Errorf("OAuth private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("OAuth private key is not RSA key")
+	}
+	return rsaKey, nil
+}
@@ -0,0 +1,166 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/VirtusLab/jira-stats/analyzer/telemetry"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/ztrue/tracerr"
+	"time"
+)
+
+// Tables of single tenant deployments, before tables were partitioned by tenant
+const LegacyConfigTable = "Config"
+const LegacyTicketTable = "Ticket"
+const LegacySprintTable = "Sprint"
+
+// Copies tickets, sprints and sync cursor of single tenant deployment into partition of tenant bound to context.
+// Items already stored for the tenant are kept and the later sync cursor wins, so migration can be repeated
+// and can be run after tenant was already fetched
+func MigrateLegacyTables(ctx context.Context) (string, error) {
+	ctx, done := trackPhase(ctx, "migrate_legacy_tables")
+	defer done()
+
+	tickets, skippedTickets := 0, 0
+	err := scanLegacyTable(ctx, LegacyTicketTable, func(item map[string]*dynamodb.AttributeValue) error {
+		var ticket domain.Ticket
+		if err := dynamodbattribute.UnmarshalMap(item, &ticket); err != nil {
+			return err
+		}
+
+		// ticket is known as it was at its last update, which is the best guess of its sync time
+		version, err := versionItem(ctx, domain.TicketVersion{Ticket: ticket, SyncTime: ticket.UpdateTime})
+		if err != nil {
+			return err
+		}
+		if _, err := putMissing(ctx, TicketVersionTable, VersionAttribute, version); err != nil {
+			return err
+		}
+
+		copied, err := putMissing(ctx, TicketTable, "Id", withTenant(ctx, item))
+		if err != nil {
+			return err
+		}
+		if copied {
+			tickets++
+		} else {
+			skippedTickets++
+		}
+		return nil
+	})
+	if err != nil {
+		return "", tracerr.Wrap(err)
+	}
+
+	sprints := 0
+	err = scanLegacyTable(ctx, LegacySprintTable, func(item map[string]*dynamodb.AttributeValue) error {
+		copied, err := putMissing(ctx, SprintTable, "Id", withTenant(ctx, item))
+		if copied {
+			sprints++
+		}
+		return err
+	})
+	if err != nil {
+		return "", tracerr.Wrap(err)
+	}
+
+	lastUpdate, err := migrateLastUpdate(ctx)
+	if err != nil {
+		return "", tracerr.Wrap(err)
+	}
+
+	return fmt.Sprintf("Migrated %d tickets (%d already stored) and %d sprints into tenant %s, last update is %s",
+		tickets, skippedTickets, sprints, tenantOf(ctx).Id, lastUpdate.Format(time.RFC3339)), nil
+}
+
+// Stores legacy sync cursor unless tenant already got further, returns resulting cursor
+func migrateLastUpdate(ctx context.Context) (time.Time, error) {
+	result, err := dbClient().GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		Key:       map[string]*dynamodb.AttributeValue{"ConfigName": {S: aws.String(LastUpdateConfig)}},
+		TableName: aws.String(LegacyConfigTable),
+	})
+	if err != nil {
+		return time.Time{}, tracerr.Wrap(err)
+	}
+
+	lastUpdate, err := getLastUpdate(ctx)
+	if err != nil {
+		return time.Time{}, tracerr.Wrap(err)
+	}
+
+	configItem := domain.ConfigItem{}
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &configItem); err != nil {
+		return time.Time{}, tracerr.Wrap(err)
+	}
+	if configItem.ConfigValue == "" {
+		return lastUpdate, nil
+	}
+
+	legacyUpdate, err := time.Parse(time.RFC3339, configItem.ConfigValue)
+	if err != nil {
+		return time.Time{}, tracerr.Wrap(err)
+	}
+	if !legacyUpdate.After(lastUpdate) {
+		return lastUpdate, nil
+	}
+
+	if err := storeLastUpdate(ctx, legacyUpdate); err != nil {
+		return time.Time{}, tracerr.Wrap(err)
+	}
+	return legacyUpdate, nil
+}
+
+// Scans through all the pages of table of single tenant deployment
+func scanLegacyTable(ctx context.Context, tableName string, handle func(item map[string]*dynamodb.AttributeValue) error) error {
+	var handleErr error
+	err := dbClient().ScanPagesWithContext(ctx, &dynamodb.ScanInput{TableName: aws.String(tableName)},
+		func(page *dynamodb.ScanOutput, lastPage bool) bool {
+			for _, item := range page.Items {
+				handleErr = handle(item)
+				if handleErr != nil {
+					return false
+				}
+			}
+			return true
+		})
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if handleErr != nil {
+		return tracerr.Wrap(handleErr)
+	}
+
+	telemetry.Infof(ctx, "Scanned legacy table %s", tableName)
+	return nil
+}
+
+// Copy of item assigned to tenant bound to context
+func withTenant(ctx context.Context, item map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	tenantItem := make(map[string]*dynamodb.AttributeValue, len(item)+1)
+	for name, value := range item {
+		tenantItem[name] = value
+	}
+	tenantItem[TenantAttribute] = &dynamodb.AttributeValue{S: aws.String(tenantOf(ctx).Id)}
+	return tenantItem
+}
+
+// Puts item unless one with the same key is already stored, reports whether it was put
+func putMissing(ctx context.Context, tableName string, keyAttribute string, item map[string]*dynamodb.AttributeValue) (bool, error) {
+	_, err := dbClient().PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		Item:                     item,
+		TableName:                aws.String(tableName),
+		ConditionExpression:      aws.String("attribute_not_exists(#k)"),
+		ExpressionAttributeNames: map[string]*string{"#k": aws.String(keyAttribute)},
+	})
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+	if err != nil {
+		return false, tracerr.Wrap(err)
+	}
+	return true, nil
+}
@@ -0,0 +1,292 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"github.com/VirtusLab/jira-stats/analyzer/chart"
+	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/VirtusLab/jira-stats/analyzer/telemetry"
+	"github.com/ztrue/tracerr"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ReportDevTime = "devtime"
+const ReportEpics = "epics"
+const ReportSprints = "sprints"
+const ReportEstimates = "estimates"
+const ReportEstimateTeams = "estimate-teams"
+const ReportEstimatePoints = "estimate-points"
+const ReportWorklogs = "worklogs"
+const ReportWorklogPeople = "worklog-people"
+const ReportFlowEfficiency = "flow-efficiency"
+const ReportFlowEfficiencySummary = "flow-efficiency-summary"
+const ReportCumulativeFlow = "cumulative-flow"
+const ReportThroughput = "throughput"
+const ReportAgingWip = "aging-wip"
+const ReportForecast = "forecast"
+const ReportRework = "rework"
+const ReportReworkProjects = "rework-projects"
+const ReportBlocked = "blocked"
+
+const MaxForecastTrials = 100000
+
+const FormatCsv = "csv"
+const FormatJson = "json"
+const FormatSvg = "svg"
+const FormatPng = "png"
+
+type ReportRequest struct {
+	Name            string
+	StartDate       time.Time
+	EndDate         time.Time
+	Mode            string   // how sub-tasks are treated in dev time report
+	SubtractBlocked bool     // flagged time is not counted as dev time in dev time report
+	SprintId        int      // sprint to report on, all sprints within dates when not set
+	GroupBy         []string // dimensions summary reports are aggregated by (project, type, day, week, month)
+	Period          string   // sampling period of time series reports, report specific default when not set
+	Scope           domain.Scope
+	Forecast        domain.Forecast
+	Format          string
+	Tenant          string    // tenant reported on, first configured tenant when not set
+	AsOf            time.Time // tickets are reported as they were synced until then, current ones when not set
+}
+
+// Builds report request out of query params
+func ParseReportRequest(params map[string]string) (ReportRequest, error) {
+	request := ReportRequest{
+		Name:   strings.ToLower(params["report"]),
+		Mode:   strings.ToLower(params["mode"]),
+		Tenant: params["tenant"],
+	}
+
+	if request.Name == "" {
+		request.Name = ReportDevTime
+	}
+
+	if request.Mode == "" {
+		request.Mode = domain.ModeAll
+	}
+
+	request.SubtractBlocked = strings.ToLower(params["subtractBlocked"]) == "true"
+
+	request.Period = strings.ToLower(params["period"])
+
+	request.Format = strings.ToLower(params["format"])
+	if request.Format == "" {
+		request.Format = FormatCsv
+	}
+
+	request.Scope = domain.Scope{
+		Projects: splitList(params["project"]),
+		Types:    splitList(params["type"]),
+	}
+
+	request.GroupBy = splitList(strings.ToLower(params["groupBy"]))
+	if len(request.GroupBy) == 0 {
+		request.GroupBy = []string{domain.GroupByProject}
+	}
+
+	if params["sprint"] != "" {
+		sprintId, err := strconv.Atoi(params["sprint"])
+		if err != nil {
+			return ReportRequest{}, tracerr.Wrap(err)
+		}
+		request.SprintId = sprintId
+	}
+
+	forecast, err := parseForecast(params)
+	if err != nil {
+		return ReportRequest{}, tracerr.Wrap(err)
+	}
+	request.Forecast = forecast
+
+	startDate, err := time.Parse(domain.DayFormat, params["startDate"])
+	if err != nil {
+		return ReportRequest{}, tracerr.Wrap(err)
+	}
+	request.StartDate = startDate
+
+	endDate, err := time.Parse(domain.DayFormat, params["endDate"])
+	if err != nil {
+		return ReportRequest{}, tracerr.Wrap(err)
+	}
+	request.EndDate = endDate
+
+	if params["asOf"] != "" {
+		asOf, err := parseAsOf(params["asOf"])
+		if err != nil {
+			return ReportRequest{}, tracerr.Wrap(err)
+		}
+		request.AsOf = asOf
+	}
+
+	return request, nil
+}
+
+// Parses time report is generated as of, either RFC3339 timestamp or day (syncs of the whole day are included)
+func parseAsOf(value string) (time.Time, error) {
+	if asOf, err := time.Parse(time.RFC3339, value); err == nil {
+		return asOf, nil
+	}
+
+	day, err := time.Parse(domain.DayFormat, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid asOf [%s], expected day or RFC3339 timestamp", value)
+	}
+	return day.Add(24*time.Hour - time.Nanosecond), nil
+}
+
+// Generates requested report of tenant from DB, out of ticket versions when it is requested as of past time
+func GetReport(ctx context.Context, request ReportRequest) (*domain.CsvContents, error) {
+	ctx, span := telemetry.StartSpan(ctx, "report "+request.Name, "report", request.Name)
+	defer span.End()
+
+	tenant, err := LookupTenant(request.Tenant)
+	if err != nil {
+		span.RecordError(err)
+		return &domain.CsvContents{}, tracerr.Wrap(err)
+	}
+
+	ctx = WithTenant(ctx, tenant)
+	if !request.AsOf.IsZero() {
+		span.SetAttribute("asOf", request.AsOf.Format(time.RFC3339))
+		ctx = withAsOf(ctx, request.AsOf)
+	}
+
+	csv, err := getReport(ctx, request)
+	if err != nil {
+		span.RecordError(err)
+		return csv, err
+	}
+
+	span.SetAttribute("rows", len(csv.Rows))
+	return csv, nil
+}
+
+func getReport(ctx context.Context, request ReportRequest) (*domain.CsvContents, error) {
+	switch request.Name {
+	case ReportDevTime:
+		return GetCsv(ctx, request.StartDate, request.EndDate, request.Mode, request.SubtractBlocked)
+	case ReportEpics:
+		return GetEpicCsv(ctx, request.StartDate, request.EndDate)
+	case ReportSprints:
+		return GetSprintCsv(ctx, request.StartDate, request.EndDate, request.SprintId)
+	case ReportEstimates:
+		return GetEstimatesCsv(ctx, request.StartDate, request.EndDate)
+	case ReportEstimateTeams:
+		return GetEstimateTeamsCsv(ctx, request.StartDate, request.EndDate)
+	case ReportEstimatePoints:
+		return GetEstimatePointsCsv(ctx, request.StartDate, request.EndDate)
+	case ReportWorklogs:
+		return GetWorklogsCsv(ctx, request.StartDate, request.EndDate)
+	case ReportWorklogPeople:
+		return GetWorklogPeopleCsv(ctx, request.StartDate, request.EndDate)
+	case ReportFlowEfficiency:
+		return GetFlowEfficiencyCsv(ctx, request.StartDate, request.EndDate)
+	case ReportFlowEfficiencySummary:
+		return GetFlowEfficiencySummaryCsv(ctx, request.StartDate, request.EndDate, request.GroupBy)
+	case ReportCumulativeFlow:
+		return GetCumulativeFlowCsv(ctx, request.StartDate, request.EndDate, periodOrDefault(request.Period, domain.PeriodDay), request.Scope)
+	case ReportThroughput:
+		return GetThroughputCsv(ctx, request.StartDate, request.EndDate, periodOrDefault(request.Period, domain.PeriodWeek), request.Scope)
+	case ReportForecast:
+		return GetForecastCsv(ctx, request.StartDate, request.EndDate, request.Forecast, request.Scope)
+	case ReportRework:
+		return GetReworkCsv(ctx, request.StartDate, request.EndDate, request.Scope)
+	case ReportReworkProjects:
+		return GetReworkProjectsCsv(ctx, request.StartDate, request.EndDate, request.Scope)
+	case ReportBlocked:
+		return GetBlockedCsv(ctx, request.StartDate, request.EndDate, request.Scope)
+	case ReportAgingWip:
+		return GetAgingWipCsv(ctx, request.StartDate, request.EndDate, request.Scope)
+	default:
+		return &domain.CsvContents{}, fmt.Errorf("unknown report [%s]", request.Name)
+	}
+}
+
+// Builds forecast settings out of query params, random seed is used when not given
+func parseForecast(params map[string]string) (domain.Forecast, error) {
+	forecast := domain.Forecast{
+		Trials: domain.DefaultForecastTrials,
+		Seed:   time.Now().UnixNano(),
+	}
+
+	var err error
+	if params["items"] != "" {
+		if forecast.Items, err = strconv.Atoi(params["items"]); err != nil {
+			return domain.Forecast{}, tracerr.Wrap(err)
+		}
+	}
+
+	if params["targetDate"] != "" {
+		if forecast.TargetDate, err = time.Parse(domain.DayFormat, params["targetDate"]); err != nil {
+			return domain.Forecast{}, tracerr.Wrap(err)
+		}
+	}
+
+	if params["trials"] != "" {
+		if forecast.Trials, err = strconv.Atoi(params["trials"]); err != nil {
+			return domain.Forecast{}, tracerr.Wrap(err)
+		}
+		if forecast.Trials > MaxForecastTrials {
+			return domain.Forecast{}, fmt.Errorf("requested trials [%d] bigger than allowed limit [%d]", forecast.Trials, MaxForecastTrials)
+		}
+	}
+
+	if params["seed"] != "" {
+		if forecast.Seed, err = strconv.ParseInt(params["seed"], 10, 64); err != nil {
+			return domain.Forecast{}, tracerr.Wrap(err)
+		}
+	}
+
+	return forecast, nil
+}
+
+func periodOrDefault(period string, defaultPeriod string) string {
+	if period == "" {
+		return defaultPeriod
+	}
+	return period
+}
+
+// Renders report in requested format, returns rendered report along with its content type
+func RenderReport(request ReportRequest, csv *domain.CsvContents) (string, string, error) {
+	switch request.Format {
+	case FormatCsv:
+		return csv.ToString(), "text/plain", nil
+	case FormatJson:
+		result, err := csv.ToJson()
+		if err != nil {
+			return "", "", tracerr.Wrap(err)
+		}
+		return result, "application/json", nil
+	case FormatSvg, FormatPng:
+		tenant, err := LookupTenant(request.Tenant)
+		if err != nil {
+			return "", "", tracerr.Wrap(err)
+		}
+
+		reportChart, err := ReportChart(request.Name, csv, tenant.Workflow)
+		if err != nil {
+			return "", "", tracerr.Wrap(err)
+		}
+
+		if request.Format == FormatSvg {
+			result, err := chart.RenderSvg(reportChart, chart.DefaultWidth, chart.DefaultHeight)
+			if err != nil {
+				return "", "", tracerr.Wrap(err)
+			}
+			return result, "image/svg+xml", nil
+		}
+
+		result, err := chart.RenderPng(reportChart, chart.DefaultWidth, chart.DefaultHeight)
+		if err != nil {
+			return "", "", tracerr.Wrap(err)
+		}
+		return string(result), "image/png", nil
+	default:
+		return "", "", fmt.Errorf("unknown format [%s]", request.Format)
+	}
+}
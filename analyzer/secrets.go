@@ -1,24 +1,294 @@
 package analyzer
 
 import (
-	"github.com/aws/aws-sdk-go/aws/session"
+	"context"
+	"fmt"
+	"github.com/VirtusLab/jira-stats/analyzer/telemetry"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/ztrue/tracerr"
-	"log"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 )
 
-func RetrieveSecrets() ([]byte, error) {
-	secretId := "JiraCreds"
+const SecretsManagerProviderName = "secretsmanager" // AWS Secrets Manager secret
+const SsmProviderName = "ssm"                       // AWS SSM Parameter Store parameter, decrypted if it is SecureString
+const EnvProviderName = "env"                       // env variable
+const FileProviderName = "file"                     // file within directory, e.g. mounted Kubernetes or Docker secret
 
-	sess := session.Must(session.NewSession())
-	secretMgr := secretsmanager.New(sess)
+const DefaultSecretName = "JiraCreds"
+const DefaultSecretsDir = "/run/secrets"
+const DefaultSecretsTtl = 5 * time.Minute
 
-	output, err := secretMgr.GetSecretValue(&secretsmanager.GetSecretValueInput{SecretId: &secretId})
+// Source of secrets, looked up by name
+type SecretProvider interface {
+	Secret(ctx context.Context, name string) ([]byte, error)
+}
+
+// Provider able to store secrets, e.g. rotated OAuth 2.0 refresh tokens
+type SecretStore interface {
+	StoreSecret(ctx context.Context, name string, value []byte) error
+}
+
+type SecretsManagerProvider struct{}
+
+func (p SecretsManagerProvider) Secret(ctx context.Context, name string) ([]byte, error) {
+	secretMgr := secretsmanager.New(awsSession())
+
+	output, err := secretMgr.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{SecretId: &name})
 	if err != nil {
 		return nil, tracerr.Wrap(err)
 	}
 
-	log.Printf("Fetched Secret id: %s\n", *output.Name)
+	telemetry.Infof(ctx, "Fetched Secret id: %s", *output.Name)
 
 	return []byte(*output.SecretString), nil
 }
+
+func (p SecretsManagerProvider) StoreSecret(ctx context.Context, name string, value []byte) error {
+	secretMgr := secretsmanager.New(awsSession())
+
+	_, err := secretMgr.PutSecretValueWithContext(ctx, &secretsmanager.PutSecretValueInput{SecretId: &name, SecretString: aws.String(string(value))})
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+
+	telemetry.Infof(ctx, "Stored Secret id: %s", name)
+	return nil
+}
+
+type SsmProvider struct{}
+
+func (p SsmProvider) Secret(ctx context.Context, name string) ([]byte, error) {
+	parameterStore := ssm.New(awsSession())
+
+	output, err := parameterStore.GetParameterWithContext(ctx, &ssm.GetParameterInput{Name: &name, WithDecryption: aws.Bool(true)})
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+
+	telemetry.Infof(ctx, "Fetched SSM parameter: %s", *output.Parameter.Name)
+
+	return []byte(*output.Parameter.Value), nil
+}
+
+// Overwrites existing parameter, its type and encryption key are kept
+func (p SsmProvider) StoreSecret(ctx context.Context, name string, value []byte) error {
+	parameterStore := ssm.New(awsSession())
+
+	_, err := parameterStore.PutParameterWithContext(ctx, &ssm.PutParameterInput{Name: &name, Value: aws.String(string(value)), Overwrite: aws.Bool(true)})
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+
+	telemetry.Infof(ctx, "Stored SSM parameter: %s", name)
+	return nil
+}
+
+type EnvProvider struct{}
+
+func (p EnvProvider) Secret(ctx context.Context, name string) ([]byte, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("env variable [%s] with secret is not set", name)
+	}
+	return []byte(value), nil
+}
+
+// Reads secrets from files named after them within Dir
+type FileProvider struct {
+	Dir string
+}
+
+func (p FileProvider) Secret(ctx context.Context, name string) ([]byte, error) {
+	content, err := ioutil.ReadFile(filepath.Join(p.Dir, name))
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return content, nil
+}
+
+func (p FileProvider) StoreSecret(ctx context.Context, name string, value []byte) error {
+	return tracerr.Wrap(ioutil.WriteFile(filepath.Join(p.Dir, name), value, 0600))
+}
+
+// Keeps secrets fetched by Provider in memory for Ttl, so that warm lambda invocations don't fetch them again
+type CachingProvider struct {
+	Provider SecretProvider
+	Ttl      time.Duration
+	ClockNow func() time.Time
+
+	lock    sync.Mutex
+	entries map[string]cachedSecret
+}
+
+type cachedSecret struct {
+	value   []byte
+	expires time.Time
+}
+
+func (p *CachingProvider) Secret(ctx context.Context, name string) ([]byte, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := p.now()
+	if entry, ok := p.entries[name]; ok && now.Before(entry.expires) {
+		return entry.value, nil
+	}
+
+	value, err := p.Provider.Secret(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.entries == nil {
+		p.entries = make(map[string]cachedSecret)
+	}
+	p.entries[name] = cachedSecret{value: value, expires: now.Add(p.Ttl)}
+
+	return value, nil
+}
+
+// Stores secret with Provider, if it supports storing, cached value is replaced
+func (p *CachingProvider) StoreSecret(ctx context.Context, name string, value []byte) error {
+	store, ok := p.Provider.(SecretStore)
+	if !ok {
+		return fmt.Errorf("secrets provider does not support storing secrets")
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := store.StoreSecret(ctx, name, value); err != nil {
+		return err
+	}
+
+	if p.entries != nil {
+		p.entries[name] = cachedSecret{value: value, expires: p.now().Add(p.Ttl)}
+	}
+	return nil
+}
+
+// Forgets cached secrets, e.g. after they were rotated
+func (p *CachingProvider) Invalidate() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.entries = nil
+}
+
+func (p *CachingProvider) now() time.Time {
+	if p.ClockNow != nil {
+		return p.ClockNow()
+	}
+	return time.Now()
+}
+
+// Builds provider by its name, file provider reads secrets from given directory
+func NewSecretProvider(name string, dir string) (SecretProvider, error) {
+	switch name {
+	case SecretsManagerProviderName:
+		return SecretsManagerProvider{}, nil
+	case SsmProviderName:
+		return SsmProvider{}, nil
+	case EnvProviderName:
+		return EnvProvider{}, nil
+	case FileProviderName:
+		return FileProvider{Dir: dir}, nil
+	default:
+		return nil, fmt.Errorf("unknown secrets provider [%s]", name)
+	}
+}
+
+var secretsCache = struct {
+	lock      sync.Mutex
+	providers map[string]*CachingProvider
+}{providers: make(map[string]*CachingProvider)}
+
+// Provider configured with JIRA_SECRETS_PROVIDER, JIRA_SECRETS_DIR and JIRA_SECRETS_TTL env vars, cached per process
+func secretProvider() (SecretProvider, error) {
+	name := strings.ToLower(os.Getenv("JIRA_SECRETS_PROVIDER"))
+	if name == "" {
+		name = SecretsManagerProviderName
+	}
+	dir := os.Getenv("JIRA_SECRETS_DIR")
+	if dir == "" {
+		dir = DefaultSecretsDir
+	}
+	ttl := DefaultSecretsTtl
+	if value, err := time.ParseDuration(os.Getenv("JIRA_SECRETS_TTL")); err == nil {
+		ttl = value
+	}
+
+	secretsCache.lock.Lock()
+	defer secretsCache.lock.Unlock()
+
+	key := fmt.Sprintf("%s|%s|%s", name, dir, ttl)
+	if provider, ok := secretsCache.providers[key]; ok {
+		return provider, nil
+	}
+
+	provider, err := NewSecretProvider(name, dir)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+
+	cachingProvider := &CachingProvider{Provider: provider, Ttl: ttl}
+	secretsCache.providers[key] = cachingProvider
+	return cachingProvider, nil
+}
+
+// Name of secret with Jira credentials, set with JIRA_SECRET_NAME env var, tenants may name their own
+func secretName() string {
+	if os.Getenv("JIRA_SECRET_NAME") != "" {
+		return os.Getenv("JIRA_SECRET_NAME")
+	}
+	return DefaultSecretName
+}
+
+// Fetches Jira credentials secret of tenant from configured provider
+func RetrieveSecrets(ctx context.Context) ([]byte, error) {
+	provider, err := secretProvider()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+
+	secret, err := provider.Secret(ctx, tenantOf(ctx).SecretName)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+
+	return secret, nil
+}
+
+// Stores Jira credentials secret of tenant with configured provider
+func storeSecrets(ctx context.Context, secret []byte) error {
+	provider, err := secretProvider()
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+
+	store, ok := provider.(SecretStore)
+	if !ok {
+		return fmt.Errorf("secrets provider does not support storing secrets")
+	}
+	return tracerr.Wrap(store.StoreSecret(ctx, tenantOf(ctx).SecretName, secret))
+}
+
+// Forgets cached secrets of configured provider, so that credentials rotated after Jira rejected them are read again
+func invalidateSecrets(ctx context.Context) {
+	provider, err := secretProvider()
+	if err != nil {
+		return
+	}
+
+	if caching, ok := provider.(*CachingProvider); ok {
+		telemetry.Warnf(ctx, "Jira rejected credentials, cached secrets are invalidated")
+		caching.Invalidate()
+	}
+}
@@ -0,0 +1,179 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (level Level) String() string {
+	if level < LevelDebug || level > LevelError {
+		return fmt.Sprintf("level%d", int(level))
+	}
+	return levelNames[level]
+}
+
+// Parses level name, case insensitive
+func ParseLevel(name string) (Level, bool) {
+	for i, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return Level(i), true
+		}
+	}
+	return LevelInfo, false
+}
+
+// Writes log entries as JSON lines, one object per entry with time, level, message and fields of context
+type Logger struct {
+	Out      io.Writer
+	Level    Level // entries below it are dropped
+	ClockNow func() time.Time
+
+	lock sync.Mutex
+}
+
+// Logger writing to standard error, keeping standard output for reports,
+// level is set with LOG_LEVEL env variable (info by default)
+var DefaultLogger = &Logger{Out: os.Stderr, Level: levelFromEnv()}
+
+func levelFromEnv() Level {
+	level, _ := ParseLevel(os.Getenv("LOG_LEVEL"))
+	return level
+}
+
+type fieldsKey struct{}
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+// Adds fields logged with every entry of context, e.g. request or run ID, given as key, value pairs
+func WithFields(ctx context.Context, keyValues ...interface{}) context.Context {
+	fields := append([]field{}, fieldsOf(ctx)...)
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		fields = append(fields, field{key: fmt.Sprint(keyValues[i]), value: keyValues[i+1]})
+	}
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// Fields added to context, in order they were added
+func fieldsOf(ctx context.Context) []field {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(fieldsKey{}).([]field)
+	return fields
+}
+
+// Field value of context, empty when not set
+func FieldValue(ctx context.Context, key string) string {
+	fields := fieldsOf(ctx)
+	for i := len(fields) - 1; i >= 0; i-- {
+		if fields[i].key == key {
+			return fmt.Sprint(fields[i].value)
+		}
+	}
+	return ""
+}
+
+// Random identifier of request or run
+func NewId() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(id)
+}
+
+func (logger *Logger) Log(ctx context.Context, level Level, format string, args ...interface{}) {
+	if level < logger.Level {
+		return
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteString(`{"time":`)
+	writeJson(&buffer, logger.now().UTC().Format(time.RFC3339Nano))
+	buffer.WriteString(`,"level":`)
+	writeJson(&buffer, level.String())
+	buffer.WriteString(`,"msg":`)
+	writeJson(&buffer, strings.TrimRight(fmt.Sprintf(format, args...), "\n"))
+	for _, field := range fieldsOf(ctx) {
+		buffer.WriteString(",")
+		writeJson(&buffer, field.key)
+		buffer.WriteString(":")
+		writeJson(&buffer, field.value)
+	}
+	buffer.WriteString("}\n")
+
+	logger.lock.Lock()
+	defer logger.lock.Unlock()
+	logger.Out.Write(buffer.Bytes())
+}
+
+func (logger *Logger) now() time.Time {
+	if logger.ClockNow != nil {
+		return logger.ClockNow()
+	}
+	return time.Now()
+}
+
+func writeJson(buffer *bytes.Buffer, value interface{}) {
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		encoded, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buffer.Write(encoded)
+}
+
+func Debugf(ctx context.Context, format string, args ...interface{}) {
+	DefaultLogger.Log(ctx, LevelDebug, format, args...)
+}
+
+func Infof(ctx context.Context, format string, args ...interface{}) {
+	DefaultLogger.Log(ctx, LevelInfo, format, args...)
+}
+
+func Warnf(ctx context.Context, format string, args ...interface{}) {
+	DefaultLogger.Log(ctx, LevelWarn, format, args...)
+}
+
+func Errorf(ctx context.Context, format string, args ...interface{}) {
+	DefaultLogger.Log(ctx, LevelError, format, args...)
+}
+
+// Routes standard library logger (used by code without context) through default logger at info level
+func RedirectStdLog() {
+	log.SetFlags(0)
+	log.SetOutput(stdLogWriter{})
+}
+
+type stdLogWriter struct{}
+
+func (writer stdLogWriter) Write(p []byte) (int, error) {
+	DefaultLogger.Log(context.Background(), LevelInfo, "%s", p)
+	return len(p), nil
+}
@@ -0,0 +1,319 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultNamespace = "JiraStats" // CloudWatch namespace
+const DefaultPrefix = "jira_stats"   // prefix of Prometheus metric names
+const PrometheusContentType = "text/plain; version=0.0.4"
+
+type Kind int
+
+const (
+	KindCounter  Kind = iota // summed up, e.g. number of fetched tickets
+	KindGauge                // last value, e.g. sync lag
+	KindDuration             // sum and count of durations in seconds
+)
+
+// Metrics kept in memory, exposed for Prometheus and, when enabled, emitted in CloudWatch Embedded Metric Format
+// (values recorded since last flush, meant to be flushed at the end of every lambda invocation)
+type Registry struct {
+	Namespace string
+	Prefix    string
+	ClockNow  func() time.Time
+
+	lock    sync.Mutex
+	series  map[string]*series
+	emf     bool
+	pending map[string]*emfGroup
+}
+
+type series struct {
+	name   string
+	kind   Kind
+	labels []string // key, value pairs
+	value  float64
+	count  int64
+}
+
+// Values of metrics sharing labels, recorded since last flush
+type emfGroup struct {
+	labels []string
+	kinds  map[string]Kind
+	values map[string][]float64
+}
+
+func NewRegistry() *Registry {
+	return &Registry{Namespace: DefaultNamespace, Prefix: DefaultPrefix}
+}
+
+var Default = NewRegistry()
+
+// Adds value to counter, labels are given as key, value pairs
+func (registry *Registry) Count(name string, value float64, labels ...string) {
+	registry.record(name, KindCounter, value, labels)
+}
+
+func (registry *Registry) Gauge(name string, value float64, labels ...string) {
+	registry.record(name, KindGauge, value, labels)
+}
+
+func (registry *Registry) Duration(name string, duration time.Duration, labels ...string) {
+	registry.record(name, KindDuration, duration.Seconds(), labels)
+}
+
+func (registry *Registry) record(name string, kind Kind, value float64, labels []string) {
+	if len(labels)%2 != 0 {
+		labels = labels[:len(labels)-1]
+	}
+
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	key := name + "|" + strings.Join(labels, "|")
+	if registry.series == nil {
+		registry.series = make(map[string]*series)
+	}
+	entry, ok := registry.series[key]
+	if !ok {
+		entry = &series{name: name, kind: kind, labels: labels}
+		registry.series[key] = entry
+	}
+	switch kind {
+	case KindGauge:
+		entry.value = value
+	default:
+		entry.value += value
+	}
+	entry.count++
+
+	if !registry.emf {
+		return
+	}
+	labelsKey := strings.Join(labels, "|")
+	if registry.pending == nil {
+		registry.pending = make(map[string]*emfGroup)
+	}
+	group, ok := registry.pending[labelsKey]
+	if !ok {
+		group = &emfGroup{labels: labels, kinds: make(map[string]Kind), values: make(map[string][]float64)}
+		registry.pending[labelsKey] = group
+	}
+	group.kinds[name] = kind
+	group.values[name] = append(group.values[name], value)
+}
+
+// Writes metrics in Prometheus text format
+func (registry *Registry) WritePrometheus(writer io.Writer) error {
+	registry.lock.Lock()
+	all := make([]series, 0, len(registry.series))
+	for _, entry := range registry.series {
+		all = append(all, *entry)
+	}
+	registry.lock.Unlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].name != all[j].name {
+			return all[i].name < all[j].name
+		}
+		return strings.Join(all[i].labels, "|") < strings.Join(all[j].labels, "|")
+	})
+
+	var buffer bytes.Buffer
+	for i, entry := range all {
+		name := registry.Prefix + "_" + entry.name
+		if i == 0 || all[i-1].name != entry.name {
+			fmt.Fprintf(&buffer, "# TYPE %s %s\n", prometheusName(name, entry.kind), prometheusType(entry.kind))
+		}
+
+		labels := prometheusLabels(entry.labels)
+		switch entry.kind {
+		case KindCounter:
+			fmt.Fprintf(&buffer, "%s_total%s %s\n", name, labels, formatValue(entry.value))
+		case KindGauge:
+			fmt.Fprintf(&buffer, "%s%s %s\n", name, labels, formatValue(entry.value))
+		case KindDuration:
+			fmt.Fprintf(&buffer, "%s_sum%s %s\n", name, labels, formatValue(entry.value))
+			fmt.Fprintf(&buffer, "%s_count%s %d\n", name, labels, entry.count)
+		}
+	}
+
+	_, err := writer.Write(buffer.Bytes())
+	return err
+}
+
+// Serves metrics for Prometheus scraping
+func (registry *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", PrometheusContentType)
+		registry.WritePrometheus(writer)
+	})
+}
+
+// Starts collecting values for Embedded Metric Format
+func (registry *Registry) EnableEmf() {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	registry.emf = true
+}
+
+// Writes values recorded since last flush as Embedded Metric Format documents (one per set of labels, which become
+// dimensions), properties are added to every document, e.g. request ID
+func (registry *Registry) FlushEmf(writer io.Writer, properties map[string]string) error {
+	registry.lock.Lock()
+	groups := registry.pending
+	registry.pending = nil
+	registry.lock.Unlock()
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		document, err := registry.emfDocument(groups[key], properties)
+		if err != nil {
+			return err
+		}
+		if _, err := writer.Write(append(document, '\n')); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (registry *Registry) emfDocument(group *emfGroup, properties map[string]string) ([]byte, error) {
+	document := make(map[string]interface{})
+	for key, value := range properties {
+		document[key] = value
+	}
+
+	dimensions := make([]string, 0)
+	for i := 0; i+1 < len(group.labels); i += 2 {
+		dimensions = append(dimensions, group.labels[i])
+		document[group.labels[i]] = group.labels[i+1]
+	}
+
+	names := make([]string, 0, len(group.values))
+	for name := range group.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	metrics := make([]map[string]string, 0, len(names))
+	for _, name := range names {
+		metrics = append(metrics, map[string]string{"Name": name, "Unit": emfUnit(group.kinds[name])})
+		values := group.values[name]
+		if len(values) == 1 {
+			document[name] = values[0]
+		} else {
+			document[name] = values
+		}
+	}
+
+	document["_aws"] = map[string]interface{}{
+		"Timestamp": registry.now().UnixNano() / int64(time.Millisecond),
+		"CloudWatchMetrics": []map[string]interface{}{{
+			"Namespace":  registry.Namespace,
+			"Dimensions": [][]string{dimensions},
+			"Metrics":    metrics,
+		}},
+	}
+
+	return json.Marshal(document)
+}
+
+func (registry *Registry) now() time.Time {
+	if registry.ClockNow != nil {
+		return registry.ClockNow()
+	}
+	return time.Now()
+}
+
+func Count(name string, value float64, labels ...string) {
+	Default.Count(name, value, labels...)
+}
+
+func Gauge(name string, value float64, labels ...string) {
+	Default.Gauge(name, value, labels...)
+}
+
+func Duration(name string, duration time.Duration, labels ...string) {
+	Default.Duration(name, duration, labels...)
+}
+
+// Emits metrics of default registry recorded so far to standard output, tagged with fields of context
+func FlushMetrics(ctx context.Context) {
+	properties := make(map[string]string)
+	for _, field := range fieldsOf(ctx) {
+		properties[field.key] = fmt.Sprint(field.value)
+	}
+
+	if err := Default.FlushEmf(os.Stdout, properties); err != nil {
+		Errorf(ctx, "Flushing metrics failed: %s", err.Error())
+	}
+}
+
+func prometheusName(name string, kind Kind) string {
+	if kind == KindCounter {
+		return name + "_total"
+	}
+	return name
+}
+
+func prometheusType(kind Kind) string {
+	switch kind {
+	case KindCounter:
+		return "counter"
+	case KindGauge:
+		return "gauge"
+	default:
+		return "summary"
+	}
+}
+
+func prometheusLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[i+1])
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], value))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		return fmt.Sprint(value)
+	}
+	return fmt.Sprintf("%g", value)
+}
+
+func emfUnit(kind Kind) string {
+	switch kind {
+	case KindCounter:
+		return "Count"
+	case KindDuration:
+		return "Seconds"
+	default:
+		return "None"
+	}
+}
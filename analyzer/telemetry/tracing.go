@@ -0,0 +1,479 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const TraceparentHeader = "traceparent" // W3C Trace Context header
+const DefaultServiceName = "jira-stats"
+const DefaultBatchSize = 512
+const DefaultQueueSize = 8 // batches waiting for export, further ones are dropped
+const ExportTimeout = 5 * time.Second
+const ExportInterval = 5 * time.Second // spans of long running processes are exported at least that often
+
+type SpanKind int
+
+// Values as defined by OpenTelemetry protocol
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+const (
+	statusOk    = 1
+	statusError = 2
+)
+
+// Identifies span within trace, either local or received from caller
+type spanContext struct {
+	traceId [16]byte
+	spanId  [8]byte
+	sampled bool
+}
+
+// Timed operation within trace, recorded once ended
+type Span struct {
+	tracer   *Tracer
+	context  spanContext
+	parentId [8]byte
+	name     string
+	kind     SpanKind
+	start    time.Time
+
+	lock       sync.Mutex
+	end        time.Time
+	attributes []field
+	err        error
+	ended      bool
+}
+
+// Creates spans and hands ended ones to exporter, spans are not recorded without exporter
+// but trace context is still propagated
+type Tracer struct {
+	Exporter *OtlpExporter
+	ClockNow func() time.Time
+}
+
+// Tracer exporting to OTLP endpoint set with OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT
+// env variable (e.g. http://localhost:4318 of local collector), service is named with OTEL_SERVICE_NAME
+var DefaultTracer = &Tracer{Exporter: exporterFromEnv()}
+
+func exporterFromEnv() *OtlpExporter {
+	url := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if url == "" && os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" {
+		url = strings.TrimSuffix(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "/") + "/v1/traces"
+	}
+	if url == "" {
+		return nil
+	}
+
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	return NewOtlpExporter(url, serviceName)
+}
+
+type spanKey struct{}
+type remoteParentKey struct{}
+
+// Starts span as child of span in context (or remote parent extracted from request), attributes are given
+// as key, value pairs
+func (tracer *Tracer) Start(ctx context.Context, name string, kind SpanKind, attributes ...interface{}) (context.Context, *Span) {
+	span := &Span{tracer: tracer, name: name, kind: kind, start: tracer.now()}
+
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.context.traceId = parent.context.traceId
+		span.context.sampled = parent.context.sampled
+		span.parentId = parent.context.spanId
+	} else if remote, ok := ctx.Value(remoteParentKey{}).(spanContext); ok {
+		span.context.traceId = remote.traceId
+		span.context.sampled = remote.sampled
+		span.parentId = remote.spanId
+	} else {
+		rand.Read(span.context.traceId[:])
+		span.context.sampled = true
+	}
+	rand.Read(span.context.spanId[:])
+
+	for i := 0; i+1 < len(attributes); i += 2 {
+		span.SetAttribute(fmt.Sprint(attributes[i]), attributes[i+1])
+	}
+
+	if SpanFromContext(ctx) == nil {
+		ctx = WithFields(ctx, "traceId", span.TraceId())
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func (tracer *Tracer) now() time.Time {
+	if tracer.ClockNow != nil {
+		return tracer.ClockNow()
+	}
+	return time.Now()
+}
+
+// Starts internal span with default tracer
+func StartSpan(ctx context.Context, name string, attributes ...interface{}) (context.Context, *Span) {
+	return DefaultTracer.Start(ctx, name, KindInternal, attributes...)
+}
+
+// Starts span of given kind with default tracer, e.g. server span of handled request
+func StartSpanOfKind(ctx context.Context, name string, kind SpanKind, attributes ...interface{}) (context.Context, *Span) {
+	return DefaultTracer.Start(ctx, name, kind, attributes...)
+}
+
+// Current span, nil if there is none
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+func (span *Span) SetAttribute(key string, value interface{}) {
+	span.lock.Lock()
+	defer span.lock.Unlock()
+
+	span.attributes = append(span.attributes, field{key: key, value: value})
+}
+
+// Marks span as failed, nil error is ignored
+func (span *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+
+	span.lock.Lock()
+	defer span.lock.Unlock()
+
+	span.err = err
+}
+
+// Ends span and exports it, only the first call has effect
+func (span *Span) End() {
+	span.lock.Lock()
+	if span.ended {
+		span.lock.Unlock()
+		return
+	}
+	span.ended = true
+	span.end = span.tracer.now()
+	span.lock.Unlock()
+
+	if span.tracer.Exporter != nil && span.context.sampled {
+		span.tracer.Exporter.export(span)
+	}
+}
+
+func (span *Span) TraceId() string {
+	return hex.EncodeToString(span.context.traceId[:])
+}
+
+func (span *Span) SpanId() string {
+	return hex.EncodeToString(span.context.spanId[:])
+}
+
+// Value of traceparent header identifying span as parent
+func (span *Span) Traceparent() string {
+	flags := "00"
+	if span.context.sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", span.TraceId(), span.SpanId(), flags)
+}
+
+// Continues trace of caller given traceparent header value, invalid values are ignored
+func Extract(ctx context.Context, traceparent string) context.Context {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return ctx
+	}
+
+	remote := spanContext{}
+	traceId, err := hex.DecodeString(parts[1])
+	if err != nil || bytes.Equal(traceId, remote.traceId[:]) {
+		return ctx
+	}
+	spanId, err := hex.DecodeString(parts[2])
+	if err != nil || bytes.Equal(spanId, remote.spanId[:]) {
+		return ctx
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return ctx
+	}
+
+	copy(remote.traceId[:], traceId)
+	copy(remote.spanId[:], spanId)
+	remote.sampled = flags&1 == 1
+	return context.WithValue(ctx, remoteParentKey{}, remote)
+}
+
+// Propagates current span to called service
+func Inject(ctx context.Context, header http.Header) {
+	if span := SpanFromContext(ctx); span != nil {
+		header.Set(TraceparentHeader, span.Traceparent())
+	}
+}
+
+// Value of header with given name, compared case insensitively as in headers of lambda events
+func HeaderValue(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// Sends ended spans to OpenTelemetry collector with OTLP over HTTP (JSON encoding), in batches. Batches are sent
+// from background goroutine, so ending span never waits for collector
+type OtlpExporter struct {
+	Url         string
+	ServiceName string
+	BatchSize   int
+	QueueSize   int
+	Client      *http.Client
+
+	lock  sync.Mutex
+	spans []*Span
+	start sync.Once
+	queue chan exportJob
+}
+
+// Batch of spans to send, done is notified once it is sent when someone waits for it
+type exportJob struct {
+	spans []*Span
+	done  chan error
+}
+
+func NewOtlpExporter(url string, serviceName string) *OtlpExporter {
+	return &OtlpExporter{
+		Url:         url,
+		ServiceName: serviceName,
+		BatchSize:   DefaultBatchSize,
+		QueueSize:   DefaultQueueSize,
+		Client:      &http.Client{Timeout: ExportTimeout},
+	}
+}
+
+func (exporter *OtlpExporter) export(span *Span) {
+	exporter.lock.Lock()
+	exporter.spans = append(exporter.spans, span)
+	var batch []*Span
+	if len(exporter.spans) >= exporter.BatchSize {
+		batch = exporter.spans
+		exporter.spans = nil
+	}
+	exporter.lock.Unlock()
+
+	if batch == nil {
+		return
+	}
+
+	select {
+	case exporter.jobs() <- exportJob{spans: batch}:
+	default:
+		Warnf(context.Background(), "Export queue is full, dropping %d spans", len(batch))
+	}
+}
+
+// Queue of export goroutine, started with the first batch
+func (exporter *OtlpExporter) jobs() chan exportJob {
+	exporter.start.Do(func() {
+		queueSize := exporter.QueueSize
+		if queueSize <= 0 {
+			queueSize = DefaultQueueSize
+		}
+		exporter.queue = make(chan exportJob, queueSize)
+		go exporter.run()
+	})
+	return exporter.queue
+}
+
+// Sends queued batches, spans which do not fill batch are sent every ExportInterval
+func (exporter *OtlpExporter) run() {
+	ticker := time.NewTicker(ExportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case job := <-exporter.queue:
+			err := exporter.send(context.Background(), job.spans)
+			if job.done != nil {
+				job.done <- err
+			} else if err != nil {
+				Warnf(context.Background(), "Exporting spans failed: %s", err.Error())
+			}
+		case <-ticker.C:
+			if err := exporter.send(context.Background(), exporter.takeSpans()); err != nil {
+				Warnf(context.Background(), "Exporting spans failed: %s", err.Error())
+			}
+		}
+	}
+}
+
+func (exporter *OtlpExporter) takeSpans() []*Span {
+	exporter.lock.Lock()
+	defer exporter.lock.Unlock()
+
+	spans := exporter.spans
+	exporter.spans = nil
+	return spans
+}
+
+// Sends spans ended since last flush after batches queued so far, e.g. at the end of lambda invocation or on shutdown
+func (exporter *OtlpExporter) Flush(ctx context.Context) error {
+	job := exportJob{spans: exporter.takeSpans(), done: make(chan error, 1)}
+
+	select {
+	case exporter.jobs() <- job:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-job.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (exporter *OtlpExporter) send(ctx context.Context, spans []*Span) error {
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(exporter.request(spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, exporter.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := exporter.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector responded with status [%d]", resp.StatusCode)
+	}
+	return nil
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceId           string         `json:"traceId"`
+	SpanId            string         `json:"spanId"`
+	ParentSpanId      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// Export request as defined by OTLP JSON encoding (IDs are hex encoded)
+func (exporter *OtlpExporter) request(spans []*Span) map[string]interface{} {
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		span.lock.Lock()
+		entry := otlpSpan{
+			TraceId:           span.TraceId(),
+			SpanId:            span.SpanId(),
+			Name:              span.name,
+			Kind:              span.kind,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+			Status:            otlpStatus{Code: statusOk},
+		}
+		if span.parentId != [8]byte{} {
+			entry.ParentSpanId = hex.EncodeToString(span.parentId[:])
+		}
+		for _, attribute := range span.attributes {
+			entry.Attributes = append(entry.Attributes, otlpAttribute(attribute.key, attribute.value))
+		}
+		if span.err != nil {
+			entry.Status = otlpStatus{Code: statusError, Message: span.err.Error()}
+		}
+		span.lock.Unlock()
+
+		encoded = append(encoded, entry)
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": []otlpKeyValue{otlpAttribute("service.name", exporter.ServiceName)},
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": DefaultServiceName},
+				"spans": encoded,
+			}},
+		}},
+	}
+}
+
+func otlpAttribute(key string, value interface{}) otlpKeyValue {
+	switch typed := value.(type) {
+	case bool:
+		return otlpKeyValue{Key: key, Value: map[string]interface{}{"boolValue": typed}}
+	case int:
+		return otlpKeyValue{Key: key, Value: map[string]interface{}{"intValue": strconv.Itoa(typed)}}
+	case int64:
+		return otlpKeyValue{Key: key, Value: map[string]interface{}{"intValue": strconv.FormatInt(typed, 10)}}
+	case float64:
+		return otlpKeyValue{Key: key, Value: map[string]interface{}{"doubleValue": typed}}
+	default:
+		return otlpKeyValue{Key: key, Value: map[string]interface{}{"stringValue": fmt.Sprint(value)}}
+	}
+}
+
+// Sends spans of default tracer ended so far, at the end of lambda invocation or on shutdown, export is not bound
+// to given context as it may be already done
+func FlushTraces(ctx context.Context) {
+	if DefaultTracer.Exporter == nil {
+		return
+	}
+
+	if err := DefaultTracer.Exporter.Flush(context.Background()); err != nil {
+		Warnf(ctx, "Exporting spans failed: %s", err.Error())
+	}
+}
+
+// Emits metrics and spans recorded so far
+func Flush(ctx context.Context) {
+	FlushMetrics(ctx)
+	FlushTraces(ctx)
+}
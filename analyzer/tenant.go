@@ -0,0 +1,162 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/VirtusLab/jira-stats/analyzer/telemetry"
+	"github.com/ztrue/tracerr"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const DefaultTenantId = "default"
+
+// Default scope of tickets, used by tenant configured with env vars
+const DefaultJiraQuery = "(project in (\"Traffic & Ordering\", \"Amazing Delivery\", ROB) OR " +
+	"kanban = \"Traffic & Ordering\" OR " +
+	"labels in (traffic-external, traffic-team) " +
+	") AND " +
+	"project != NIR AND " +
+	"NOT (project = DL AND status = Closed)"
+
+// Team or Jira instance with its own tickets, sync cursor and calculation settings
+type Tenant struct {
+	Id            string              `json:"id"`
+	JiraUrl       string              `json:"jiraUrl"`
+	Deployment    string              `json:"deployment"`    // server or cloud
+	Auth          string              `json:"auth"`          // authentication method, basic by default
+	SecretName    string              `json:"secretName"`    // name of Jira credentials within secrets provider
+	Query         string              `json:"query"`         // JQL selecting tickets of tenant, without ordering
+	WebhookSecret string              `json:"webhookSecret"` // shared with Jira of tenant only, webhooks are rejected when not set
+	Fields        domain.CustomFields `json:"fields"`
+	Workflow      domain.Workflow     `json:"workflow"`
+
+	envCreds bool // credentials may be given with env vars, only to tenant configured with env vars
+}
+
+func (tenant Tenant) JiraConfig() domain.JiraConfig {
+	return domain.JiraConfig{Deployment: tenant.Deployment, Fields: tenant.Fields}
+}
+
+// Tenant configured with JIRA_* env vars, used when no tenants are configured
+func envTenant() Tenant {
+	query := DefaultJiraQuery
+	if os.Getenv("JIRA_QUERY") != "" {
+		query = os.Getenv("JIRA_QUERY")
+	}
+
+	config := jiraConfig()
+	return Tenant{
+		Id:            DefaultTenantId,
+		JiraUrl:       jiraUrl(),
+		Deployment:    config.Deployment,
+		Auth:          jiraAuthMethod(),
+		SecretName:    secretName(),
+		Query:         query,
+		WebhookSecret: os.Getenv("JIRA_WEBHOOK_SECRET"),
+		Fields:        config.Fields,
+		Workflow:      workflow(),
+		envCreds:      true,
+	}
+}
+
+// Reads tenants from JSON list in JIRA_TENANTS env var or in file pointed by JIRA_TENANTS_FILE,
+// single tenant configured with JIRA_* env vars is returned when neither is set
+func Tenants() ([]Tenant, error) {
+	content := []byte(os.Getenv("JIRA_TENANTS"))
+	if len(content) == 0 && os.Getenv("JIRA_TENANTS_FILE") != "" {
+		var err error
+		content, err = ioutil.ReadFile(os.Getenv("JIRA_TENANTS_FILE"))
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+	}
+
+	if len(strings.TrimSpace(string(content))) == 0 {
+		return []Tenant{envTenant()}, nil
+	}
+
+	return parseTenants(content)
+}
+
+// Parses tenants, settings not given fall back to defaults
+func parseTenants(content []byte) ([]Tenant, error) {
+	entries := make([]json.RawMessage, 0)
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return nil, fmt.Errorf("invalid tenants config: %s", err.Error())
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("tenants config has no tenants")
+	}
+
+	tenants := make([]Tenant, 0, len(entries))
+	ids := make(map[string]bool)
+	for _, entry := range entries {
+		tenant := Tenant{
+			Deployment: domain.DeploymentServer,
+			Auth:       AuthBasic,
+			SecretName: secretName(),
+			Fields:     domain.DefaultCustomFields,
+			Workflow:   domain.DefaultWorkflow,
+		}
+		if err := json.Unmarshal(entry, &tenant); err != nil {
+			return nil, fmt.Errorf("invalid tenants config: %s", err.Error())
+		}
+		tenant.Deployment = strings.ToLower(tenant.Deployment)
+		tenant.Auth = strings.ToLower(tenant.Auth)
+
+		switch {
+		case tenant.Id == "":
+			return nil, fmt.Errorf("tenant id is missing")
+		case ids[tenant.Id]:
+			return nil, fmt.Errorf("tenant [%s] is configured twice", tenant.Id)
+		case tenant.JiraUrl == "":
+			return nil, fmt.Errorf("Jira URL of tenant [%s] is missing", tenant.Id)
+		case tenant.Query == "":
+			return nil, fmt.Errorf("query of tenant [%s] is missing", tenant.Id)
+		}
+		ids[tenant.Id] = true
+
+		tenants = append(tenants, tenant)
+	}
+
+	return tenants, nil
+}
+
+// Finds tenant by id, first configured tenant is returned for empty id
+func LookupTenant(id string) (Tenant, error) {
+	tenants, err := Tenants()
+	if err != nil {
+		return Tenant{}, tracerr.Wrap(err)
+	}
+
+	if id == "" {
+		return tenants[0], nil
+	}
+
+	for _, tenant := range tenants {
+		if tenant.Id == id {
+			return tenant, nil
+		}
+	}
+	return Tenant{}, fmt.Errorf("unknown tenant [%s]", id)
+}
+
+type tenantContextKey struct{}
+
+// Binds tenant to context, all the Jira and DB calls made with it concern the tenant
+func WithTenant(ctx context.Context, tenant Tenant) context.Context {
+	ctx = telemetry.WithFields(ctx, "tenant", tenant.Id)
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// Tenant bound to context, tenant configured with env vars when there is none
+func tenantOf(ctx context.Context) Tenant {
+	if tenant, ok := ctx.Value(tenantContextKey{}).(Tenant); ok {
+		return tenant
+	}
+	return envTenant()
+}
@@ -0,0 +1,343 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/VirtusLab/jira-stats/analyzer/telemetry"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("too many failed Jira requests, circuit breaker is open")
+
+// Settings of resilient transport Jira client uses
+type TransportConfig struct {
+	MaxRetries       int           // retries of single request, 0 disables retries
+	BaseDelay        time.Duration // delay before first retry, doubled with every next one
+	MaxDelay         time.Duration // upper bound of delay, also of delay requested with Retry-After
+	RateLimit        float64       // requests per second, 0 disables limiting
+	Timeout          time.Duration // time to wait for response headers of single attempt
+	BreakerThreshold int           // consecutive failures opening circuit breaker, 0 disables breaker
+	BreakerCooldown  time.Duration // time circuit breaker stays open
+
+	Sleep func(ctx context.Context, delay time.Duration) error // waits between retries, interrupted by context
+}
+
+var DefaultTransportConfig = TransportConfig{
+	MaxRetries:       5,
+	BaseDelay:        time.Second,
+	MaxDelay:         time.Minute,
+	RateLimit:        10,
+	Timeout:          30 * time.Second,
+	BreakerThreshold: 10,
+	BreakerCooldown:  time.Minute,
+}
+
+// Reads transport settings from env vars, falls back to defaults if not set
+func transportConfig() TransportConfig {
+	config := DefaultTransportConfig
+
+	if value, err := strconv.Atoi(os.Getenv("JIRA_MAX_RETRIES")); err == nil {
+		config.MaxRetries = value
+	}
+	if value, err := strconv.ParseFloat(os.Getenv("JIRA_RATE_LIMIT"), 64); err == nil {
+		config.RateLimit = value
+	}
+	if value, err := time.ParseDuration(os.Getenv("JIRA_TIMEOUT")); err == nil {
+		config.Timeout = value
+	}
+	if value, err := strconv.Atoi(os.Getenv("JIRA_BREAKER_THRESHOLD")); err == nil {
+		config.BreakerThreshold = value
+	}
+	if value, err := time.ParseDuration(os.Getenv("JIRA_BREAKER_COOLDOWN")); err == nil {
+		config.BreakerCooldown = value
+	}
+
+	return config
+}
+
+// Transport retrying transient failures with exponential backoff, limiting request rate
+// and failing fast when Jira keeps failing
+type resilientTransport struct {
+	config    TransportConfig
+	transport http.RoundTripper
+	state     *transportState
+}
+
+// Rate limit and circuit breaker state, shared by transports of all clients of the same Jira within process
+type transportState struct {
+	lock        sync.Mutex
+	nextRequest time.Time // earliest time next request is allowed by rate limit
+	failures    int       // consecutive failures
+	openUntil   time.Time // circuit breaker rejects requests until then
+}
+
+var jiraTransportStates = struct {
+	lock   sync.Mutex
+	states map[string]*transportState
+}{states: make(map[string]*transportState)}
+
+// State of Jira with given address, tenants using the same Jira share its rate limit and circuit breaker
+func jiraTransportState(jiraUrl string) *transportState {
+	jiraTransportStates.lock.Lock()
+	defer jiraTransportStates.lock.Unlock()
+
+	state, ok := jiraTransportStates.states[jiraUrl]
+	if !ok {
+		state = &transportState{}
+		jiraTransportStates.states[jiraUrl] = state
+	}
+	return state
+}
+
+// Wraps transport with retries, rate limiting and circuit breaker
+func NewResilientTransport(transport http.RoundTripper, config TransportConfig) http.RoundTripper {
+	return newResilientTransport(transport, config, &transportState{})
+}
+
+func newResilientTransport(transport http.RoundTripper, config TransportConfig, state *transportState) http.RoundTripper {
+	if config.Sleep == nil {
+		config.Sleep = sleep
+	}
+
+	return &resilientTransport{config: config, transport: transport, state: state}
+}
+
+func (t *resilientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := t.allow(); err != nil {
+			return nil, err
+		}
+		if err := t.config.Sleep(req.Context(), t.reserve()); err != nil {
+			return nil, err
+		}
+
+		attemptReq, err := rewind(req)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := t.transport.RoundTrip(attemptReq)
+		if req.Context().Err() != nil {
+			return resp, err // cancelled by caller (e.g. deadline approaching), Jira did not fail
+		}
+
+		t.record(failed(resp, err))
+		if failed(resp, err) {
+			telemetry.Count(MetricJiraErrors, 1, "status", failureStatus(resp))
+		}
+
+		if attempt >= t.config.MaxRetries || !retryable(req, resp, err) {
+			return resp, err
+		}
+
+		delay := t.backoff(attempt, resp)
+		if resp != nil {
+			telemetry.Warnf(req.Context(), "Jira responded with %d to %s, retrying in %s...", resp.StatusCode, req.URL.Path, delay)
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		} else {
+			telemetry.Warnf(req.Context(), "Jira request %s failed (%s), retrying in %s...", req.URL.Path, err.Error(), delay)
+		}
+
+		if err := t.config.Sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Checks circuit breaker
+func (t *resilientTransport) allow() error {
+	t.state.lock.Lock()
+	defer t.state.lock.Unlock()
+
+	if time.Now().Before(t.state.openUntil) {
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// Records outcome of request in circuit breaker, single failure after cooldown opens breaker again
+func (t *resilientTransport) record(failure bool) {
+	t.state.lock.Lock()
+	defer t.state.lock.Unlock()
+
+	if !failure {
+		t.state.failures = 0
+		return
+	}
+
+	t.state.failures++
+	if t.config.BreakerThreshold > 0 && t.state.failures >= t.config.BreakerThreshold {
+		telemetry.Errorf(context.Background(), "%d consecutive Jira requests failed, pausing requests for %s...", t.state.failures, t.config.BreakerCooldown)
+		t.state.openUntil = time.Now().Add(t.config.BreakerCooldown)
+	}
+}
+
+// Reserves slot for request within rate limit, returns time to wait for it
+func (t *resilientTransport) reserve() time.Duration {
+	if t.config.RateLimit <= 0 {
+		return 0
+	}
+
+	t.state.lock.Lock()
+	defer t.state.lock.Unlock()
+
+	now := time.Now()
+	if t.state.nextRequest.Before(now) {
+		t.state.nextRequest = now
+	}
+	wait := t.state.nextRequest.Sub(now)
+	t.state.nextRequest = t.state.nextRequest.Add(time.Duration(float64(time.Second) / t.config.RateLimit))
+
+	return wait
+}
+
+// Delay before next attempt, Retry-After header takes precedence over exponential backoff with jitter
+func (t *resilientTransport) backoff(attempt int, resp *http.Response) time.Duration {
+	delay := t.config.BaseDelay
+	for i := 0; i < attempt; i++ { // doubled until capped, so that it does not overflow
+		if delay > t.config.MaxDelay/2 {
+			delay = t.config.MaxDelay
+			break
+		}
+		delay *= 2
+	}
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+
+	if resp != nil {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			delay = retryAfter
+		}
+	}
+
+	if delay < 0 {
+		return 0
+	}
+	if delay > t.config.MaxDelay {
+		return t.config.MaxDelay
+	}
+	return delay
+}
+
+// Retry-After is either number of seconds or HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date), true
+	}
+	return 0, false
+}
+
+func failed(resp *http.Response, err error) bool {
+	return err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// Status of failed response, "network" when no response came
+func failureStatus(resp *http.Response) string {
+	if resp == nil {
+		return "network"
+	}
+	return strconv.Itoa(resp.StatusCode)
+}
+
+// Transient failures are retried, unless request was cancelled or its body can not be sent again
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Body != nil && req.GetBody == nil {
+		return false
+	}
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// Copies request with fresh body, so that it can be sent again
+func rewind(req *http.Request) (*http.Request, error) {
+	attemptReq := req.Clone(req.Context())
+	if req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		attemptReq.Body = body
+	}
+	return attemptReq, nil
+}
+
+// Binds requests to context, as Jira client API does not take one
+type contextTransport struct {
+	ctx       context.Context
+	transport http.RoundTripper
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.transport.RoundTrip(req.WithContext(t.ctx))
+}
+
+// Traces every request sent to Jira as client span, propagating trace context
+type tracingTransport struct {
+	transport http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := telemetry.StartSpanOfKind(req.Context(), "Jira "+req.Method+" "+req.URL.Path, telemetry.KindClient,
+		"http.method", req.Method, "http.url", req.URL.String())
+	defer span.End()
+
+	req = req.Clone(ctx)
+	telemetry.Inject(ctx, req.Header)
+
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		return resp, err
+	}
+
+	span.SetAttribute("http.status_code", resp.StatusCode)
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.RecordError(fmt.Errorf("Jira responded with status [%d]", resp.StatusCode))
+	}
+	return resp, nil
+}
+
+// Default transport waiting for response headers at most given time
+func timeoutTransport(timeout time.Duration) http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = timeout
+	return transport
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -1,11 +1,28 @@
 package analyzer
 
 import (
-	"log"
+	"context"
+	"github.com/VirtusLab/jira-stats/analyzer/telemetry"
 	"time"
 )
 
-func timeTrack(start time.Time, name string) {
-	elapsed := time.Since(start)
-	log.Printf("TIMING [%s] took %s", name, elapsed)
+const MetricTicketsFetched = "tickets_fetched" // tickets returned by Jira search
+const MetricTicketsStored = "tickets_stored"   // tickets written to DB
+const MetricTicketsSkipped = "tickets_skipped" // fetched tickets not stored because deadline approached
+const MetricSyncLag = "sync_lag_seconds"       // age of most recent update stored from Jira
+const MetricPhaseDuration = "phase_duration_seconds"
+const MetricJiraErrors = "jira_errors" // failed Jira requests, by status or "network"
+
+// Starts span of phase, returned func ends it, logs its duration and records it as metric,
+// phase names are meant to be stable, e.g. "fetch_issues"
+func trackPhase(ctx context.Context, phase string, attributes ...interface{}) (context.Context, func()) {
+	start := time.Now()
+	ctx, span := telemetry.StartSpan(ctx, phase, attributes...)
+
+	return ctx, func() {
+		span.End()
+		elapsed := time.Since(start)
+		telemetry.Duration(MetricPhaseDuration, elapsed, "phase", phase)
+		telemetry.Infof(telemetry.WithFields(ctx, "phase", phase, "durationMs", elapsed.Milliseconds()), "TIMING [%s] took %s", phase, elapsed)
+	}
 }
@@ -0,0 +1,136 @@
+package analyzer
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/VirtusLab/jira-stats/analyzer/telemetry"
+	"github.com/andygrunwald/go-jira"
+	"github.com/ztrue/tracerr"
+	"strings"
+	"time"
+)
+
+const WebhookIssueCreated = "jira:issue_created"
+const WebhookIssueUpdated = "jira:issue_updated"
+const WebhookIssueDeleted = "jira:issue_deleted"
+
+const WebhookSignatureHeader = "X-Hub-Signature"
+
+var ErrWebhookUnauthorized = errors.New("webhook secret does not match")
+
+type WebhookEvent struct {
+	WebhookEvent string     `json:"webhookEvent"`
+	Timestamp    int64      `json:"timestamp"`
+	Issue        jira.Issue `json:"issue"`
+}
+
+// Applies Jira webhook events to DB, scheduled fetch stays in place to catch up on missed events
+type WebhookProcessor struct {
+	Secret     string                                                    // shared with Jira, webhooks are rejected when not set
+	FetchIssue func(ctx context.Context, key string) (jira.Issue, error) // fetches issue with its full changelog and worklogs
+	Store      func(ctx context.Context, tickets []domain.Ticket) error  // upserts tickets
+	Delete     func(ctx context.Context, ticketId string) error          // removes ticket
+	Config     domain.JiraConfig
+}
+
+// Creates processor working against Jira and DB of tenant, only webhooks signed with secret of the tenant are accepted,
+// so that Jira of one tenant cannot change tickets of another. Events have to be handled with context tenant is bound to
+func NewWebhookProcessor(tenant Tenant) WebhookProcessor {
+	return WebhookProcessor{
+		Secret:     tenant.WebhookSecret,
+		FetchIssue: fetchIssue,
+		Store:      storeWebhookTickets,
+		Delete:     removeWebhookTicket,
+		Config:     tenant.JiraConfig(),
+	}
+}
+
+// Handles webhook request body, secret is verified against either HMAC signature of body
+// (sha256=<hex> as sent by Jira in X-Hub-Signature header) or secret passed as query param
+func (processor WebhookProcessor) Handle(ctx context.Context, body []byte, signature string, secret string) (string, error) {
+	ctx, span := telemetry.StartSpan(ctx, "webhook")
+	defer span.End()
+
+	result, err := processor.handle(ctx, span, body, signature, secret)
+	span.RecordError(err)
+	return result, err
+}
+
+func (processor WebhookProcessor) handle(ctx context.Context, span *telemetry.Span, body []byte, signature string, secret string) (string, error) {
+	if !processor.verify(body, signature, secret) {
+		return "", ErrWebhookUnauthorized
+	}
+
+	event := WebhookEvent{}
+	if err := json.Unmarshal(body, &event); err != nil {
+		return "", tracerr.Wrap(err)
+	}
+	span.SetAttribute("webhook.event", event.WebhookEvent)
+	span.SetAttribute("ticket", event.Issue.Key)
+	telemetry.Infof(ctx, "Webhook event %s for ticket %s (%s)", event.WebhookEvent, event.Issue.Key, event.Issue.ID)
+
+	switch event.WebhookEvent {
+	case WebhookIssueCreated, WebhookIssueUpdated:
+		issue, err := processor.FetchIssue(ctx, event.Issue.Key)
+		if err != nil {
+			return "", tracerr.Wrap(err)
+		}
+
+		ticket, err := domain.JiraToDomain(issue, processor.Config)
+		if err != nil {
+			return "", tracerr.Wrap(err)
+		}
+
+		if err := processor.Store(ctx, []domain.Ticket{ticket}); err != nil {
+			return "", tracerr.Wrap(err)
+		}
+		return fmt.Sprintf("Stored ticket %s", ticket.Key), nil
+	case WebhookIssueDeleted:
+		if err := processor.Delete(ctx, event.Issue.ID); err != nil {
+			return "", tracerr.Wrap(err)
+		}
+		return fmt.Sprintf("Deleted ticket %s", event.Issue.Key), nil
+	default:
+		return fmt.Sprintf("Ignored event %s", event.WebhookEvent), nil
+	}
+}
+
+func (processor WebhookProcessor) verify(body []byte, signature string, secret string) bool {
+	if processor.Secret == "" {
+		telemetry.Warnf(context.Background(), "Webhook secret is not configured, rejecting webhook...")
+		return false
+	}
+
+	if signature != "" {
+		mac := hmac.New(sha256.New, []byte(processor.Secret))
+		mac.Write(body)
+		expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		return hmac.Equal([]byte(strings.ToLower(signature)), []byte(expected))
+	}
+
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(processor.Secret)) == 1
+}
+
+// Stores tickets along with sprints they belong to, as synced now
+func storeWebhookTickets(ctx context.Context, tickets []domain.Ticket) error {
+	syncTime := time.Now()
+	for _, ticket := range tickets {
+		if err := store(ctx, ticket, syncTime); err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+
+	return storeSprints(ctx, tickets)
+}
+
+// Removes ticket, as synced now
+func removeWebhookTicket(ctx context.Context, ticketId string) error {
+	return remove(ctx, ticketId, time.Now())
+}
@@ -2,29 +2,36 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"github.com/VirtusLab/jira-stats/analyzer"
+	"github.com/VirtusLab/jira-stats/analyzer/telemetry"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	"log"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/ztrue/tracerr"
 	"time"
 )
 
-// Handler is our lambda invoked by CloudWatch event
+// Handler is our lambda invoked by CloudWatch event, fetches all tenants, fetch stops before lambda deadline
+// and continues on next invocation
 func fetchHandler(ctx context.Context, request events.CloudWatchEvent) (interface{}, error) {
-	log.Printf("Jira fetch invoked by: %s at %s\n", request.DetailType, request.Time.Format(time.RFC3339))
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		ctx = telemetry.WithFields(ctx, "requestId", lc.AwsRequestID)
+	}
+	defer telemetry.Flush(ctx)
 
-	number, err := analyzer.ProcessTickets(100)
+	telemetry.Infof(ctx, "Jira fetch invoked by: %s at %s", request.DetailType, request.Time.Format(time.RFC3339))
 
-	result := fmt.Sprintf("Number of processed Jiras: %d", number)
+	result, err := analyzer.FetchData(ctx, "")
 	if err != nil {
-		result = err.Error()
+		telemetry.Errorf(ctx, "%s", tracerr.Sprint(err))
+	} else {
+		telemetry.Infof(ctx, "%s", result)
 	}
-
-	log.Printf("%s\n", result)
 	return result, nil
 }
 
 func main() {
+	telemetry.RedirectStdLog()
+	telemetry.Default.EnableEmf()
 	lambda.Start(fetchHandler)
 }
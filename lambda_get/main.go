@@ -2,39 +2,47 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"github.com/VirtusLab/jira-stats/analyzer"
-	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/VirtusLab/jira-stats/analyzer/telemetry"
 	"github.com/ztrue/tracerr"
-	"log"
-	"strings"
-	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
 )
 
+const DashboardResource = "/dashboard"
+const ReportResource = "generate_csv" // relative to dashboard
+
 // Handler is our lambda handler invoked by the `lambda.Start` function call
 func mainHandler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		ctx = telemetry.WithFields(ctx, "requestId", lc.AwsRequestID)
+	}
+	defer telemetry.Flush(ctx)
 
-	var result string
-	var contentType string
+	ctx = telemetry.Extract(ctx, telemetry.HeaderValue(request.Headers, telemetry.TraceparentHeader))
+	ctx, span := telemetry.StartSpanOfKind(ctx, request.HTTPMethod+" "+request.Resource, telemetry.KindServer,
+		"http.method", request.HTTPMethod, "http.route", request.Resource)
+	defer span.End()
 
-	csv, err := process(request)
+	result, contentType, err := process(ctx, request)
 	if err != nil {
-		tracerr.PrintSourceColor(err)
+		telemetry.Errorf(ctx, "%s", tracerr.Sprint(err))
 		result = fmt.Sprintf("Error while generating CSV: %s", err.Error())
-		contentType = "application/json"
+		contentType = "text/plain"
 	}
 
-	result = csv.ToString()
-	log.Printf("Generated CSV with: %d rows...", len(csv.Rows)+1)
-
-	contentType = "text/plain"
+	binary := contentType == "image/png"
+	if binary {
+		result = base64.StdEncoding.EncodeToString([]byte(result))
+	}
 
 	resp := events.APIGatewayProxyResponse{
 		StatusCode:      200,
-		IsBase64Encoded: false,
+		IsBase64Encoded: binary,
 		Body:            result,
 		Headers: map[string]string{
 			"Content-Type": contentType,
@@ -44,37 +52,17 @@ func mainHandler(ctx context.Context, request events.APIGatewayProxyRequest) (ev
 	return resp, nil
 }
 
-func process(request events.APIGatewayProxyRequest) (*domain.CsvContents, error) {
-	params := request.QueryStringParameters
-
-	log.Printf("Path params are: %s", params)
-
-	forceFetch := params["forceFetch"]
-	if strings.ToLower(forceFetch) == "true" {
-		_, err := analyzer.ProcessTickets(100)
-		return &domain.CsvContents{}, err
-	}
-
-	startDateString := params["startDate"]
-	startDate, err := time.Parse(domain.DayFormat, startDateString)
-	if err != nil {
-		return &domain.CsvContents{}, tracerr.Wrap(err)
-	}
-
-	endDateString := params["endDate"]
-	endDate, err := time.Parse(domain.DayFormat, endDateString)
-	if err != nil {
-		return &domain.CsvContents{}, tracerr.Wrap(err)
-	}
-
-	csv, err := analyzer.GetCsv(startDate, endDate)
-	if err != nil {
-		return &domain.CsvContents{}, tracerr.Wrap(err)
+func process(ctx context.Context, request events.APIGatewayProxyRequest) (string, string, error) {
+	if request.Resource == DashboardResource {
+		result, err := analyzer.Dashboard(ReportResource)
+		return result, "text/html", err
 	}
 
-	return csv, nil
+	return analyzer.GenerateReport(ctx, request.QueryStringParameters)
 }
 
 func main() {
+	telemetry.RedirectStdLog()
+	telemetry.Default.EnableEmf()
 	lambda.Start(mainHandler)
 }
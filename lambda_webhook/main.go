@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"github.com/VirtusLab/jira-stats/analyzer"
+	"github.com/VirtusLab/jira-stats/analyzer/telemetry"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/ztrue/tracerr"
+	"net/http"
+	"strings"
+)
+
+// Handler is our lambda invoked by Jira webhook, tenant param tells which tenant it is registered for
+func webhookHandler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		ctx = telemetry.WithFields(ctx, "requestId", lc.AwsRequestID)
+	}
+	defer telemetry.Flush(ctx)
+
+	ctx = telemetry.Extract(ctx, telemetry.HeaderValue(request.Headers, telemetry.TraceparentHeader))
+	ctx, span := telemetry.StartSpanOfKind(ctx, request.HTTPMethod+" "+request.Resource, telemetry.KindServer,
+		"http.method", request.HTTPMethod, "http.route", request.Resource)
+	defer span.End()
+
+	body := []byte(request.Body)
+	if request.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(request.Body)
+		if err != nil {
+			return response(http.StatusBadRequest, err.Error()), nil
+		}
+		body = decoded
+	}
+
+	signature := ""
+	for name, value := range request.Headers {
+		if strings.EqualFold(name, analyzer.WebhookSignatureHeader) {
+			signature = value
+		}
+	}
+
+	tenant, err := analyzer.LookupTenant(request.QueryStringParameters["tenant"])
+	if err != nil {
+		return response(http.StatusBadRequest, err.Error()), nil
+	}
+
+	ctx = analyzer.WithTenant(ctx, tenant)
+	result, err := analyzer.NewWebhookProcessor(tenant).Handle(ctx, body, signature, request.QueryStringParameters["secret"])
+	if err == analyzer.ErrWebhookUnauthorized {
+		return response(http.StatusUnauthorized, err.Error()), nil
+	}
+	if err != nil {
+		telemetry.Errorf(ctx, "%s", tracerr.Sprint(err))
+		return response(http.StatusInternalServerError, err.Error()), nil
+	}
+
+	return response(http.StatusOK, result), nil
+}
+
+func response(status int, body string) events.APIGatewayProxyResponse {
+	return events.APIGatewayProxyResponse{
+		StatusCode: status,
+		Body:       body,
+		Headers: map[string]string{
+			"Content-Type": "text/plain",
+		},
+	}
+}
+
+func main() {
+	telemetry.RedirectStdLog()
+	telemetry.Default.EnableEmf()
+	lambda.Start(webhookHandler)
+}
@@ -1,25 +1,133 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"github.com/VirtusLab/jira-stats/analyzer"
-	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/VirtusLab/jira-stats/analyzer/telemetry"
 	"github.com/ztrue/tracerr"
-	"log"
+	"io/ioutil"
+	"os"
 	"time"
 )
 
+// Runs reports locally, every flag is passed as report param, e.g.
+// local -report=forecast -startDate=2020-01-01 -endDate=2020-03-31 -items=30 -seed=1
+// (-tenant selects tenant to report on, fetch or check credentials of, fetch covers all tenants when not given)
 func main() {
-	//_, err := analyzer.ProcessTickets(100)
-	//if err != nil {
-	//	tracerr.PrintSourceColor(err)
-	//}
-
-	start, _ := time.Parse(domain.DayFormat, "2020-01-01")
-	end, _ := time.Parse(domain.DayFormat, "2020-03-31")
-	csv, err := analyzer.GetCsv(start, end)
+	fetch := flag.Bool("fetch", false, "fetch tickets from Jira before generating report")
+	authCheck := flag.Bool("authCheck", false, "only check whether Jira accepts configured credentials")
+	address := flag.String("serve", "", "serve API endpoints and dashboard on given address instead, e.g. localhost:8080")
+	fetchInterval := flag.Duration("fetchEvery", 4*time.Hour, "interval of fetching tickets from Jira when serving, 0 disables")
+	createTables := flag.Bool("createTables", false, "create DB tables missing in local store before serving")
+	migrate := flag.Bool("migrate", false, "copy tickets, sprints and last update of single tenant tables into tenant partition")
+	out := flag.String("out", "", "file to write report to instead of standard output, e.g. chart.png")
+
+	paramNames := []string{"tenant", "report", "startDate", "endDate", "mode", "subtractBlocked", "sprint", "groupBy", "period", "project", "type",
+		"items", "targetDate", "trials", "seed", "format", "asOf"}
+	paramValues := make(map[string]*string)
+	for _, name := range paramNames {
+		paramValues[name] = flag.String(name, "", fmt.Sprintf("%s report param", name))
+	}
+
+	flag.Parse()
+
+	telemetry.RedirectStdLog()
+	ctx := telemetry.WithFields(context.Background(), "runId", telemetry.NewId())
+
+	if *authCheck {
+		tenant, err := analyzer.LookupTenant(*paramValues["tenant"])
+		if err != nil {
+			tracerr.PrintSourceColor(err)
+			os.Exit(1)
+		}
+
+		result, err := analyzer.CheckAuth(analyzer.WithTenant(ctx, tenant))
+		if err != nil {
+			tracerr.PrintSourceColor(err)
+			os.Exit(1)
+		}
+		fmt.Println(result)
+		return
+	}
+
+	if *migrate {
+		tenant, err := analyzer.LookupTenant(*paramValues["tenant"])
+		if err != nil {
+			tracerr.PrintSourceColor(err)
+			os.Exit(1)
+		}
+
+		result, err := analyzer.MigrateLegacyTables(analyzer.WithTenant(ctx, tenant))
+		if err != nil {
+			tracerr.PrintSourceColor(err)
+			os.Exit(1)
+		}
+		fmt.Println(result)
+		return
+	}
+
+	if *fetch {
+		result, err := analyzer.FetchData(ctx, *paramValues["tenant"])
+		if err != nil {
+			tracerr.PrintSourceColor(err)
+			os.Exit(1)
+		}
+		telemetry.Infof(ctx, "%s", result)
+	}
+
+	if *address != "" {
+		if *createTables {
+			if err := analyzer.CreateTables(ctx); err != nil {
+				tracerr.PrintSourceColor(err)
+				os.Exit(1)
+			}
+		}
+
+		if err := serve(*address, *fetchInterval); err != nil {
+			tracerr.PrintSourceColor(tracerr.Wrap(err))
+			os.Exit(1)
+		}
+		return
+	}
+
+	params := make(map[string]string)
+	for name, value := range paramValues {
+		if *value != "" {
+			params[name] = *value
+		}
+	}
+
+	request, err := analyzer.ParseReportRequest(params)
+	if err != nil {
+		tracerr.PrintSourceColor(err)
+		os.Exit(1)
+	}
+
+	defer telemetry.FlushTraces(ctx)
+
+	csv, err := analyzer.GetReport(ctx, request)
 	if err != nil {
 		tracerr.PrintSourceColor(err)
+		os.Exit(1)
 	}
 
-	log.Printf("%s", csv.ToString())
+	result, _, err := analyzer.RenderReport(request, csv)
+	if err != nil {
+		tracerr.PrintSourceColor(err)
+		os.Exit(1)
+	}
+
+	telemetry.Infof(ctx, "Generated report with: %d rows...", len(csv.Rows)+1)
+	if *out == "" {
+		fmt.Println(result)
+		return
+	}
+
+	if err := ioutil.WriteFile(*out, []byte(result), 0644); err != nil {
+		tracerr.PrintSourceColor(tracerr.Wrap(err))
+		os.Exit(1)
+	}
+	telemetry.Infof(ctx, "Report written to %s", *out)
 }
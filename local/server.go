@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/VirtusLab/jira-stats/analyzer"
+	"github.com/VirtusLab/jira-stats/analyzer/telemetry"
+	"github.com/ztrue/tracerr"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const ShutdownTimeout = 30 * time.Second
+
+// Serves lambda endpoints and dashboard on given address, fetching data from Jira every fetchInterval (0 disables)
+func serve(address string, fetchInterval time.Duration) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/dashboard", dashboardHandler)
+	mux.HandleFunc("/generate_csv", reportHandler)
+	mux.HandleFunc("/fetch_data", fetchHandler)
+	mux.HandleFunc("/webhook", webhookHandler)
+	mux.Handle("/metrics", telemetry.Default.Handler())
+
+	server := &http.Server{Addr: address, Handler: withTelemetry(mux)}
+
+	// cancelled on shutdown, interrupting scheduled fetch in progress
+	fetchCtx, cancelFetch := context.WithCancel(context.Background())
+	defer cancelFetch()
+
+	stop := make(chan struct{})
+	if fetchInterval > 0 {
+		telemetry.Infof(fetchCtx, "Fetching data every %s", fetchInterval)
+		go analyzer.Schedule(fetchInterval, func() { scheduledFetch(fetchCtx) }, stop)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	shutDown := make(chan struct{})
+	go func() {
+		defer close(shutDown)
+		<-signals
+		telemetry.Infof(fetchCtx, "Shutting down...")
+		close(stop)
+		cancelFetch()
+
+		ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			telemetry.Errorf(ctx, "Shutdown failed: %s", err.Error())
+		}
+		// spans are exported in background, remaining ones are sent once requests are done
+		telemetry.FlushTraces(ctx)
+	}()
+
+	telemetry.Infof(fetchCtx, "Serving dashboard at %s/dashboard and metrics at %s/metrics", address, address)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return tracerr.Wrap(err)
+	}
+	<-shutDown
+	return nil
+}
+
+// Tags logs of every request with its ID and traces it as server span, continuing trace of caller
+func withTelemetry(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		ctx := telemetry.WithFields(request.Context(), "requestId", telemetry.NewId())
+		ctx = telemetry.Extract(ctx, request.Header.Get(telemetry.TraceparentHeader))
+		ctx, span := telemetry.StartSpanOfKind(ctx, request.Method+" "+request.URL.Path, telemetry.KindServer,
+			"http.method", request.Method, "http.route", request.URL.Path)
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: writer, status: http.StatusOK}
+		handler.ServeHTTP(recorder, request.WithContext(ctx))
+
+		span.SetAttribute("http.status_code", recorder.status)
+		if recorder.status >= http.StatusInternalServerError {
+			span.RecordError(fmt.Errorf("responded with status [%d]", recorder.status))
+		}
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	recorder.status = status
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+func dashboardHandler(writer http.ResponseWriter, request *http.Request) {
+	result, err := analyzer.Dashboard("generate_csv")
+	if err != nil {
+		telemetry.Errorf(request.Context(), "%s", tracerr.Sprint(err))
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "text/html")
+	fmt.Fprint(writer, result)
+}
+
+// Mirrors generate_csv lambda
+func reportHandler(writer http.ResponseWriter, request *http.Request) {
+	params := make(map[string]string)
+	for name, values := range request.URL.Query() {
+		params[name] = values[0]
+	}
+
+	result, contentType, err := analyzer.GenerateReport(request.Context(), params)
+	if err != nil {
+		telemetry.Errorf(request.Context(), "%s", tracerr.Sprint(err))
+		http.Error(writer, fmt.Sprintf("Error while generating CSV: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", contentType)
+	fmt.Fprint(writer, result)
+}
+
+// Mirrors fetch_data lambda, triggered on demand, tenant param limits fetch to single tenant
+func fetchHandler(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "fetch has to be triggered with POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	result, err := analyzer.FetchData(request.Context(), request.URL.Query().Get("tenant"))
+	if err != nil {
+		telemetry.Errorf(request.Context(), "%s", tracerr.Sprint(err))
+		http.Error(writer, result, http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "text/plain")
+	fmt.Fprint(writer, result)
+}
+
+// Mirrors webhook lambda
+func webhookHandler(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "webhook has to be sent with POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenant, err := analyzer.LookupTenant(request.URL.Query().Get("tenant"))
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := analyzer.WithTenant(request.Context(), tenant)
+	signature := request.Header.Get(analyzer.WebhookSignatureHeader)
+	result, err := analyzer.NewWebhookProcessor(tenant).Handle(ctx, body, signature, request.URL.Query().Get("secret"))
+	if err == analyzer.ErrWebhookUnauthorized {
+		http.Error(writer, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		telemetry.Errorf(ctx, "%s", tracerr.Sprint(err))
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "text/plain")
+	fmt.Fprint(writer, result)
+}
+
+func scheduledFetch(ctx context.Context) {
+	telemetry.Infof(ctx, "Jira fetch invoked by schedule at %s", time.Now().Format(time.RFC3339))
+
+	result, err := analyzer.FetchData(ctx, "")
+	if err != nil {
+		telemetry.Errorf(ctx, "%s", tracerr.Sprint(err))
+		return
+	}
+	telemetry.Infof(ctx, "%s", result)
+}
@@ -0,0 +1,49 @@
+package unit
+
+import (
+	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Tests age of tickets in progress compared with cycle time of done tickets
+func TestAgingWip(t *testing.T) {
+	tickets := make([]domain.Ticket, 0)
+	for _, doneDate := range []string{"2020-02-04T09:00:00", "2020-02-05T09:00:00", "2020-02-06T09:00:00"} {
+		done := createTicket("Done", dirtyDate("2020-02-01T09:00:00"))
+		done.Transitions = domain.MakeIntervals(done,
+			createTransition("To Do", "In Development", dirtyDate("2020-02-03T09:00:00")),
+			createTransition("In Development", "Done", dirtyDate(doneDate)),
+		)
+		tickets = append(tickets, done)
+	}
+
+	old := createTicket("In Review", dirtyDate("2020-02-01T09:00:00"))
+	old.Key = "ABC-1"
+	old.Transitions = domain.MakeIntervals(old,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-05T09:00:00")),
+		createTransition("In Development", "In Review", dirtyDate("2020-02-09T09:00:00")),
+	)
+
+	fresh := createTicket("In Development", dirtyDate("2020-02-01T09:00:00"))
+	fresh.Key = "ABC-2"
+	fresh.Transitions = domain.MakeIntervals(fresh,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-09T09:00:00")),
+	)
+
+	todo := createTicket("To Do", dirtyDate("2020-02-01T09:00:00"))
+	todo.Transitions = domain.MakeIntervals(todo)
+
+	tickets = append(tickets, fresh, old, todo)
+
+	aging := domain.CalculateAgingWip(tickets, domain.DefaultWorkflow,
+		dirtyDate("2020-02-01T00:00:00"), dirtyDate("2020-02-29T23:59:59"), dirtyDate("2020-02-10T09:00:00"))
+
+	assert.Equal(t, 2, len(aging), "Only tickets in progress should be listed")
+	assert.Equal(t, "ABC-1", aging[0].Ticket.Key, "Oldest ticket should be first")
+	assert.Equal(t, 1.0, aging[0].StateAgeDays, "Incorrect age in current state")
+	assert.Equal(t, 5.0, aging[0].AgeDays, "Incorrect age since start")
+	assert.InDelta(t, 2.7, aging[0].CycleTimeDays, 0.0001, "Incorrect cycle time percentile")
+	assert.True(t, aging[0].Exceeded, "Old ticket should be flagged")
+	assert.False(t, aging[1].Exceeded, "Fresh ticket should not be flagged")
+}
@@ -182,6 +182,175 @@ func TestTwoInDevStates(t *testing.T) {
 	assert.Equal(t, tickets[0].DevEndDate, endDate.Unix(), "End date should be set correctly")
 }
 
+// Epic link is taken from custom field, parent is used when it's missing
+func TestEpicLinkAssignment(t *testing.T) {
+	issue := createJiraIssue(changeLog([]jira.ChangelogHistory{}))
+	issue.Fields.Unknowns = map[string]interface{}{domain.DefaultCustomFields.EpicLink: "ABC-1"}
+
+	tickets, err := jiraProcessor.BuildModel([]jira.Issue{issue})
+	if err != nil {
+		tracerr.PrintSourceColor(err)
+		t.Errorf("Found error: %s", err.Error())
+	}
+
+	assert.Equal(t, "ABC-1", tickets[0].EpicKey, "Epic link should be taken from custom field")
+
+	issue = createJiraIssue(changeLog([]jira.ChangelogHistory{}))
+	issue.Fields.Parent = &jira.Parent{Key: "ABC-2"}
+
+	tickets, err = jiraProcessor.BuildModel([]jira.Issue{issue})
+	if err != nil {
+		tracerr.PrintSourceColor(err)
+		t.Errorf("Found error: %s", err.Error())
+	}
+
+	assert.Equal(t, "ABC-2", tickets[0].EpicKey, "Epic link should be taken from parent")
+}
+
+// Sub-tasks should point to their parents, parents should list their sub-tasks
+func TestSubTaskRelations(t *testing.T) {
+	issue := createJiraIssue(changeLog([]jira.ChangelogHistory{}))
+	issue.Fields.Type = jira.IssueType{Name: "Sub-task", Subtask: true}
+	issue.Fields.Parent = &jira.Parent{Key: "ABC-1"}
+
+	parent := createJiraIssue(changeLog([]jira.ChangelogHistory{}))
+	parent.Fields.Subtasks = []*jira.Subtasks{{Key: "ABC-112"}}
+
+	tickets, err := jiraProcessor.BuildModel([]jira.Issue{issue, parent})
+	if err != nil {
+		tracerr.PrintSourceColor(err)
+		t.Errorf("Found error: %s", err.Error())
+	}
+
+	assert.Equal(t, "ABC-1", tickets[0].ParentKey, "Parent should be taken from sub-task")
+	assert.Equal(t, "", tickets[0].EpicKey, "Parent of sub-task is not an epic")
+	assert.Equal(t, []string{"ABC-112"}, tickets[1].SubTaskKeys, "Sub-tasks should be taken from parent")
+}
+
+// Sprints are taken from custom field and sprint changes from changelog
+func TestSprintAssignment(t *testing.T) {
+	issue := createJiraIssue(
+		changeLog(
+			[]jira.ChangelogHistory{
+				changeLogHistoryItem(
+					"2006-01-02T15:04:05.000-0700",
+					[]jira.ChangelogItems{{Field: "Sprint", From: "", To: "12"}},
+				),
+				changeLogHistoryItem(
+					"2006-01-16T15:04:05.000-0700",
+					[]jira.ChangelogItems{{Field: "Sprint", From: "12", To: "12, 13"}},
+				),
+			},
+		),
+	)
+	issue.Fields.Unknowns = map[string]interface{}{
+		domain.DefaultCustomFields.Sprint: []interface{}{
+			"com.atlassian.greenhopper.service.sprint.Sprint@1a2b3c[id=12,rapidViewId=5,state=CLOSED,name=Sprint 1]",
+			map[string]interface{}{"id": float64(13), "name": "Sprint 2"},
+		},
+	}
+
+	tickets, err := jiraProcessor.BuildModel([]jira.Issue{issue})
+	if err != nil {
+		tracerr.PrintSourceColor(err)
+		t.Errorf("Found error: %s", err.Error())
+	}
+
+	assert.Equal(t, []int{12, 13}, tickets[0].Sprints, "Sprints should be taken from custom field")
+	assert.Equal(t, 2, len(tickets[0].SprintChanges), "Sprint changes should be taken from changelog")
+	assert.Equal(t, 13, tickets[0].SprintChanges[1].SprintId, "Sprint changes should be taken from changelog")
+	assert.True(t, tickets[0].SprintChanges[1].Added, "Ticket should be added to sprint")
+}
+
+// Flag changes are taken from changelog
+func TestFlagAssignment(t *testing.T) {
+	issue := createJiraIssue(
+		changeLog(
+			[]jira.ChangelogHistory{
+				changeLogHistoryItem(
+					"2006-01-02T15:04:05.000-0700",
+					[]jira.ChangelogItems{{Field: "Flagged", FromString: "", ToString: "Impediment"}},
+				),
+				changeLogHistoryItem(
+					"2006-01-04T15:04:05.000-0700",
+					[]jira.ChangelogItems{{Field: "Flagged", FromString: "Impediment", ToString: ""}},
+				),
+			},
+		),
+	)
+
+	tickets, err := jiraProcessor.BuildModel([]jira.Issue{issue})
+	if err != nil {
+		tracerr.PrintSourceColor(err)
+		t.Errorf("Found error: %s", err.Error())
+	}
+
+	assert.Equal(t, 1, len(tickets[0].FlagIntervals), "Flag interval should be taken from changelog")
+	assert.Equal(t, 48.0, tickets[0].FlagIntervals[0].End.Sub(tickets[0].FlagIntervals[0].Start).Hours(), "Flag interval should last until flag is cleared")
+}
+
+// Jira Cloud identifies authors by accountId, display names are kept aside
+func TestCloudAuthorAssignment(t *testing.T) {
+	history := changeLogHistoryItem("2020-02-03T09:00:00.123+0000", []jira.ChangelogItems{changeLogItem("status", "To Do", "In Development")})
+	history.Author = jira.User{AccountID: "5b10a2844c20165700ede21g", DisplayName: "Test User"}
+
+	issue := createJiraIssue(changeLog([]jira.ChangelogHistory{history}))
+	issue.Fields.Type = jira.IssueType{Name: "Story"}
+
+	config := domain.DefaultJiraConfig
+	config.Deployment = domain.DeploymentCloud
+
+	ticket, err := domain.JiraToDomain(issue, config)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "5b10a2844c20165700ede21g", ticket.Transitions[0].Author, "Author should be identified by accountId")
+	assert.Equal(t, "Test User", domain.DisplayName(domain.AuthorNames([]domain.Ticket{ticket}), ticket.Transitions[0].Author))
+	assert.Equal(t, "unknown.id", domain.DisplayName(ticket.AuthorNames, "unknown.id"), "Unknown author should be shown by id")
+}
+
+// Timestamps are parsed in formats of both Jira Server and Cloud
+func TestJiraTimestampFormats(t *testing.T) {
+	expected := dirtyDate("2020-02-03T09:00:00").UTC()
+
+	for _, value := range []string{
+		"2020-02-03T09:00:00.000+0000",
+		"2020-02-03T09:00:00+0000",
+		"2020-02-03T09:00:00.000000+0000",
+		"2020-02-03T09:00:00Z",
+		"2020-02-03T10:00:00.000+01:00",
+	} {
+		timestamp, err := domain.ParseJiraTime(value)
+		assert.Nil(t, err, value)
+		assert.True(t, expected.Equal(timestamp), value)
+	}
+
+	_, err := domain.ParseJiraTime("03/02/2020")
+	assert.NotNil(t, err, "Unknown format should be rejected")
+}
+
+// Worklogs are taken from worklog field
+func TestWorklogAssignment(t *testing.T) {
+	started := jira.Time(dirtyDate("2020-02-03T09:00:00"))
+
+	issue := createJiraIssue(changeLog([]jira.ChangelogHistory{}))
+	issue.Fields.Worklog = &jira.Worklog{
+		Total: 1,
+		Worklogs: []jira.WorklogRecord{
+			{ID: "7", Author: &jira.User{Name: "test.user"}, Started: &started, TimeSpentSeconds: 3600},
+		},
+	}
+
+	tickets, err := jiraProcessor.BuildModel([]jira.Issue{issue})
+	if err != nil {
+		tracerr.PrintSourceColor(err)
+		t.Errorf("Found error: %s", err.Error())
+	}
+
+	assert.Equal(t, []domain.Worklog{
+		{Id: "7", Author: "test.user", Started: dirtyDate("2020-02-03T09:00:00"), TimeSpentSeconds: 3600},
+	}, tickets[0].Worklogs, "Worklogs should be taken from worklog field")
+}
+
 func createJiraIssue(changeLog jira.Changelog) jira.Issue {
 	return jira.Issue{
 		ID:  "11232",
@@ -0,0 +1,141 @@
+package unit
+
+import (
+	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Tests building flag intervals out of unordered flag changes
+func TestFlagIntervals(t *testing.T) {
+	intervals := domain.MakeFlagIntervals(
+		domain.FlagChange{Flagged: false, Timestamp: dirtyDate("2020-02-04T09:00:00")},
+		domain.FlagChange{Flagged: true, Timestamp: dirtyDate("2020-02-03T09:00:00"), Author: "test.user"},
+		domain.FlagChange{Flagged: true, Timestamp: dirtyDate("2020-02-05T09:00:00")},
+	)
+
+	assert.Equal(t, 2, len(intervals), "Each flagging should start new interval")
+	assert.Equal(t, dirtyDate("2020-02-04T09:00:00"), intervals[0].End, "Interval should end when flag is cleared")
+	assert.Equal(t, "test.user", intervals[0].Author, "Interval should keep author of flagging")
+	assert.Equal(t, domain.EndOfTime, intervals[1].End, "Interval should be open while ticket is flagged")
+}
+
+// Tests summing flagged and blocked state time, overlapping periods counted once
+func TestBlockedTime(t *testing.T) {
+	startDate := dirtyDate("2020-02-01T00:00:00")
+	endDate := dirtyDate("2020-02-29T23:59:59")
+
+	blocked := createTicket("Done", dirtyDate("2020-02-01T09:00:00"))
+	blocked.Key = "ABC-1"
+	blocked.Transitions = domain.MakeIntervals(blocked,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-10T08:00:00")),
+		createTransition("In Development", "Blocked", dirtyDate("2020-02-10T09:00:00")),
+		createTransition("Blocked", "In Development", dirtyDate("2020-02-10T15:00:00")),
+		createTransition("In Development", "Done", dirtyDate("2020-02-11T09:00:00")),
+	)
+	blocked.FlagIntervals = domain.MakeFlagIntervals(
+		domain.FlagChange{Flagged: true, Timestamp: dirtyDate("2020-02-10T14:00:00")},
+		domain.FlagChange{Flagged: false, Timestamp: dirtyDate("2020-02-10T17:00:00")},
+	)
+
+	clean := createTicket("Done", dirtyDate("2020-02-01T09:00:00"))
+	clean.Key = "XYZ-1"
+	clean.Transitions = domain.MakeIntervals(clean,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-03T09:00:00")),
+		createTransition("In Development", "Done", dirtyDate("2020-02-04T19:00:00")),
+	)
+
+	calculator := domain.DaysCalculator{
+		ClockNow: func() time.Time {
+			return dirtyDate("2020-12-31T00:00:00")
+		},
+	}
+	result := domain.CalculateBlockedTime(calculator, []domain.Ticket{clean, blocked}, domain.DefaultWorkflow, startDate, endDate)
+
+	assert.Equal(t, 1, len(result), "Only blocked ticket should be listed")
+	assert.Equal(t, "ABC-1", result[0].Ticket.Key)
+	assert.Equal(t, 8.0, result[0].BlockedHours, "Overlapping flag and blocked state should be counted once")
+	assert.Equal(t, 2, result[0].Blockers, "Both flagging and moving to blocked state should be counted")
+}
+
+// Tests flagged time being cut out of dev time on request
+func TestSubtractBlockedDevTime(t *testing.T) {
+	startDate := dirtyDate("2020-02-01T00:00:00")
+	endDate := dirtyDate("2020-02-29T23:59:59")
+
+	ticket := createTicket("Done", dirtyDate("2020-02-01T09:00:00"))
+	ticket.Transitions = domain.MakeIntervals(ticket,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-03T09:00:00")),
+		createTransition("In Development", "Done", dirtyDate("2020-02-07T19:00:00")),
+	)
+	ticket.FlagIntervals = domain.MakeFlagIntervals(
+		domain.FlagChange{Flagged: true, Timestamp: dirtyDate("2020-02-04T09:00:00")},
+		domain.FlagChange{Flagged: false, Timestamp: dirtyDate("2020-02-06T09:00:00")},
+	)
+
+	clockNow := func() time.Time {
+		return dirtyDate("2020-12-31T00:00:00")
+	}
+	calculator := domain.DaysCalculator{ClockNow: clockNow}
+	subtracting := domain.DaysCalculator{ClockNow: clockNow, SubtractBlocked: true}
+
+	assert.Equal(t, 5.0, calculator.CalculateDevDays(ticket, startDate, endDate), "Flagged time should count by default")
+	assert.Equal(t, 3.0, subtracting.CalculateDevDays(ticket, startDate, endDate), "Flagged time should be cut out of dev time")
+}
+
+// Tests that short flag does not split dev interval into separately rounded parts
+func TestShortFlagDoesNotIncreaseDevTime(t *testing.T) {
+	startDate := dirtyDate("2020-02-01T00:00:00")
+	endDate := dirtyDate("2020-02-29T23:59:59")
+
+	ticket := createTicket("Done", dirtyDate("2020-02-01T09:00:00"))
+	ticket.Transitions = domain.MakeIntervals(ticket,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-03T09:00:00")),
+		createTransition("In Development", "Done", dirtyDate("2020-02-05T17:00:00")),
+	)
+	ticket.FlagIntervals = domain.MakeFlagIntervals(
+		domain.FlagChange{Flagged: true, Timestamp: dirtyDate("2020-02-04T10:00:00")},
+		domain.FlagChange{Flagged: false, Timestamp: dirtyDate("2020-02-04T11:00:00")},
+	)
+
+	clockNow := func() time.Time {
+		return dirtyDate("2020-12-31T00:00:00")
+	}
+	calculator := domain.DaysCalculator{ClockNow: clockNow}
+	subtracting := domain.DaysCalculator{ClockNow: clockNow, SubtractBlocked: true}
+
+	assert.Equal(t, 3.0, calculator.CalculateDevDays(ticket, startDate, endDate))
+	assert.Equal(t, 23.0/8.0, subtracting.CalculateDevDays(ticket, startDate, endDate), "Flagged hour should be cut out of dev time")
+	assert.Equal(t, map[string]float64{"": 23.0 / 8.0}, subtracting.CalculateDevDaysByAuthor(ticket, startDate, endDate))
+}
+
+// Tests that ticket flagged for its whole dev interval has no dev time, also outside working hours
+func TestWhollyFlaggedDevTime(t *testing.T) {
+	startDate := dirtyDate("2020-02-01T00:00:00")
+	endDate := dirtyDate("2020-02-29T23:59:59")
+	subtracting := domain.DaysCalculator{ClockNow: func() time.Time { return dirtyDate("2020-12-31T00:00:00") }, SubtractBlocked: true}
+
+	short := createTicket("Done", dirtyDate("2020-02-01T09:00:00"))
+	short.Transitions = domain.MakeIntervals(short,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-03T10:00:00")),
+		createTransition("In Development", "Done", dirtyDate("2020-02-03T11:00:00")),
+	)
+	short.FlagIntervals = domain.MakeFlagIntervals(
+		domain.FlagChange{Flagged: true, Timestamp: dirtyDate("2020-02-03T09:00:00")},
+		domain.FlagChange{Flagged: false, Timestamp: dirtyDate("2020-02-03T12:00:00")},
+	)
+	assert.Equal(t, 0.0, subtracting.CalculateDevDays(short, startDate, endDate), "Flagged interval should not count")
+
+	evening := createTicket("Done", dirtyDate("2020-02-01T09:00:00"))
+	evening.Transitions = domain.MakeIntervals(evening,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-03T18:00:00")),
+		createTransition("In Development", "Done", dirtyDate("2020-02-03T21:00:00")),
+	)
+	evening.FlagIntervals = domain.MakeFlagIntervals(
+		domain.FlagChange{Flagged: true, Timestamp: dirtyDate("2020-02-03T18:00:00")},
+		domain.FlagChange{Flagged: false, Timestamp: dirtyDate("2020-02-03T20:00:00")},
+	)
+	assert.Equal(t, 2.0/8.0, subtracting.CalculateDevDays(evening, startDate, endDate),
+		"Flag outside working hours should be cut out of interval counted outside them")
+}
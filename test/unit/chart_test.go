@@ -0,0 +1,104 @@
+package unit
+
+import (
+	"bytes"
+	jiraProcessor "github.com/VirtusLab/jira-stats/analyzer"
+	"github.com/VirtusLab/jira-stats/analyzer/chart"
+	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/stretchr/testify/assert"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// Tests drawing bar chart as SVG
+func TestBarChartSvg(t *testing.T) {
+	barChart := chart.Chart{
+		Kind:       chart.KindBar,
+		Title:      "Throughput <weekly>",
+		Categories: []string{"2020-01-06", "2020-01-13"},
+		Series: []chart.Series{
+			{Name: "Created", Values: []float64{3, 5}},
+			{Name: "Done", Values: []float64{2, 4}},
+		},
+	}
+
+	svg, err := chart.RenderSvg(barChart, chart.DefaultWidth, chart.DefaultHeight)
+	assert.Nil(t, err)
+
+	assert.True(t, strings.HasPrefix(svg, "<svg"), "Chart should be SVG document")
+	assert.Contains(t, svg, "Throughput &lt;weekly&gt;", "Title should be escaped")
+	assert.Equal(t, 1+4+2, strings.Count(svg, "<rect"), "Background, bars and legend should be drawn")
+}
+
+// Tests drawing area and scatter charts as PNG
+func TestChartPng(t *testing.T) {
+	charts := []chart.Chart{
+		{
+			Kind:       chart.KindArea,
+			Categories: []string{"2020-01-01", "2020-01-02", "2020-01-03"},
+			Series:     []chart.Series{{Name: "Done", Values: []float64{1, 2, 3}}, {Name: "To Do", Values: []float64{3, 2, 1}}},
+		},
+		{
+			Kind:    chart.KindScatter,
+			Points:  []chart.Point{{Date: dirtyDate("2020-01-01T00:00:00"), Value: 2}},
+			Markers: []chart.Marker{{Name: "85%", Value: 2}},
+		},
+	}
+
+	for _, pngChart := range charts {
+		data, err := chart.RenderPng(pngChart, 400, 300)
+		assert.Nil(t, err)
+
+		image, err := png.Decode(bytes.NewReader(data))
+		assert.Nil(t, err)
+		assert.Equal(t, 400, image.Bounds().Dx())
+		assert.Equal(t, 300, image.Bounds().Dy())
+	}
+}
+
+// Tests rejecting charts which can not be drawn
+func TestInvalidChart(t *testing.T) {
+	_, err := chart.RenderSvg(chart.Chart{Kind: "pie"}, chart.DefaultWidth, chart.DefaultHeight)
+	assert.NotNil(t, err, "Unknown kind should be rejected")
+
+	invalid := chart.Chart{Kind: chart.KindBar, Categories: []string{"A"}, Series: []chart.Series{{Name: "B"}}}
+	_, err = chart.RenderSvg(invalid, chart.DefaultWidth, chart.DefaultHeight)
+	assert.NotNil(t, err, "Series not matching categories should be rejected")
+}
+
+// Tests building charts out of report data
+func TestReportChart(t *testing.T) {
+	devTime := &domain.CsvContents{
+		Header: []string{"Key", "Type", "Summary", "Project", "Dev Time (days)"},
+		Rows: []domain.CsvRow{
+			{Entries: []string{"XYZ-1", "Story", "Test", "XYZ", "1.50"}},
+			{Entries: []string{"ABC-1", "Story", "Test", "ABC", "2.00"}},
+			{Entries: []string{"XYZ-2", "Bug", "Test", "XYZ", "0.50"}},
+		},
+	}
+
+	devTimeChart, err := jiraProcessor.ReportChart(jiraProcessor.ReportDevTime, devTime, domain.DefaultWorkflow)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ABC", "XYZ"}, devTimeChart.Categories, "Dev time should be drawn per project")
+	assert.Equal(t, []float64{2, 2}, devTimeChart.Series[0].Values, "Dev time should be summed per project")
+
+	cumulativeFlow := &domain.CsvContents{
+		Header: []string{"Date", "Status", "Count"},
+		Rows: []domain.CsvRow{
+			{Entries: []string{"2020-01-01", "Done", "1"}},
+			{Entries: []string{"2020-01-01", "To Do", "3"}},
+			{Entries: []string{"2020-01-02", "Done", "2"}},
+			{Entries: []string{"2020-01-02", "To Do", "2"}},
+		},
+	}
+
+	flowChart, err := jiraProcessor.ReportChart(jiraProcessor.ReportCumulativeFlow, cumulativeFlow, domain.DefaultWorkflow)
+	assert.Nil(t, err)
+	assert.Equal(t, chart.KindArea, flowChart.Kind)
+	assert.Equal(t, "Done", flowChart.Series[0].Name, "Done tickets should be drawn at the bottom")
+	assert.Equal(t, []float64{3, 2}, flowChart.Series[1].Values)
+
+	_, err = jiraProcessor.ReportChart(jiraProcessor.ReportEpics, devTime, domain.DefaultWorkflow)
+	assert.NotNil(t, err, "Reports without chart should be rejected")
+}
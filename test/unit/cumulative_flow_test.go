@@ -0,0 +1,51 @@
+package unit
+
+import (
+	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Tests daily counts of tickets in statuses
+func TestCumulativeFlow(t *testing.T) {
+	first := createTicket("Done", dirtyDate("2020-02-01T09:00:00"))
+	first.Transitions = domain.MakeIntervals(first,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-02T09:00:00")),
+		createTransition("In Development", "Done", dirtyDate("2020-02-03T09:00:00")),
+	)
+
+	second := createTicket("To Do", dirtyDate("2020-02-02T09:00:00"))
+	second.Transitions = domain.MakeIntervals(second)
+
+	counts, err := domain.CumulativeFlow([]domain.Ticket{first, second},
+		dirtyDate("2020-02-01T00:00:00"), dirtyDate("2020-02-03T23:59:59"), domain.PeriodDay)
+	assert.Nil(t, err, "Cumulative flow should be calculated")
+
+	assert.Equal(t, []domain.StatusCount{
+		{Date: dirtyDate("2020-02-01T00:00:00"), Status: "Done", Count: 0},
+		{Date: dirtyDate("2020-02-01T00:00:00"), Status: "In Development", Count: 0},
+		{Date: dirtyDate("2020-02-01T00:00:00"), Status: "To Do", Count: 1},
+		{Date: dirtyDate("2020-02-02T00:00:00"), Status: "Done", Count: 0},
+		{Date: dirtyDate("2020-02-02T00:00:00"), Status: "In Development", Count: 1},
+		{Date: dirtyDate("2020-02-02T00:00:00"), Status: "To Do", Count: 1},
+		{Date: dirtyDate("2020-02-03T00:00:00"), Status: "Done", Count: 1},
+		{Date: dirtyDate("2020-02-03T00:00:00"), Status: "In Development", Count: 0},
+		{Date: dirtyDate("2020-02-03T00:00:00"), Status: "To Do", Count: 1},
+	}, counts, "Incorrect cumulative flow")
+
+	_, err = domain.CumulativeFlow([]domain.Ticket{first}, dirtyDate("2020-02-01T00:00:00"), dirtyDate("2020-02-03T23:59:59"), domain.PeriodMonth)
+	assert.Error(t, err, "Only hourly and daily periods should be supported")
+}
+
+func TestCsvToJson(t *testing.T) {
+	csv := domain.CsvContents{
+		Header:  []string{"Date", "Status", "Count", "Outlier"},
+		Rows:    []domain.CsvRow{{Entries: []string{"2020-02-01", "1984", "3", "true"}}},
+		Numeric: []string{"Count"},
+	}
+
+	json, err := csv.ToJson()
+	assert.Nil(t, err, "JSON should be generated")
+	assert.JSONEq(t, `[{"Date": "2020-02-01", "Status": "1984", "Count": 3, "Outlier": true}]`, json,
+		"Only numeric columns should be converted to numbers")
+}
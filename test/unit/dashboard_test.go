@@ -0,0 +1,20 @@
+package unit
+
+import (
+	jiraProcessor "github.com/VirtusLab/jira-stats/analyzer"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Tests rendering dashboard with reports it offers
+func TestDashboard(t *testing.T) {
+	page, err := jiraProcessor.Dashboard("generate_csv")
+	assert.Nil(t, err)
+
+	assert.Contains(t, page, `var reportPath = "generate_csv";`, "Report path should be passed to script")
+	assert.Contains(t, page, `"cumulative-flow"`, "Reports should be offered")
+	assert.Contains(t, page, `var chartReports = ["devtime","throughput","cumulative-flow","flow-efficiency"];`,
+		"Chart reports should be passed to script")
+	assert.Contains(t, page, `var scopedReports = ["cumulative-flow","throughput","aging-wip","forecast","rework","rework-projects","blocked"];`,
+		"Reports supporting project and type filters should be passed to script")
+}
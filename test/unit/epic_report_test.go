@@ -0,0 +1,97 @@
+package unit
+
+import (
+	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Tests rolling up children dev time to epic
+func TestEpicRollUp(t *testing.T) {
+	startDate := dirtyDate("2020-02-01T00:00:00")
+	endDate := dirtyDate("2020-02-29T23:59:59")
+
+	epic := createTicket("In Progress", dirtyDate("2020-01-01T09:00:00"))
+	epic.Key = "ABC-1"
+	epic.Type = domain.TypeEpic
+	epic.Title = "Epic title"
+
+	first := createTicket("Done", dirtyDate("2020-01-01T09:00:00"))
+	first.Key = "ABC-2"
+	first.EpicKey = "ABC-1"
+	first.Transitions = domain.MakeIntervals(first,
+		createTransition("To Do", "In Development", dirtyDate("2020-01-27T09:00:00")),
+		createTransition("In Development", "Done", dirtyDate("2020-02-04T19:00:00")),
+	)
+	first.DevStartDate = dirtyDate("2020-01-27T09:00:00").Unix()
+	first.DevEndDate = dirtyDate("2020-02-04T19:00:00").Unix()
+
+	second := createTicket("In Development", dirtyDate("2020-01-01T09:00:00"))
+	second.Key = "ABC-3"
+	second.EpicKey = "ABC-1"
+	second.Transitions = domain.MakeIntervals(second,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-10T09:00:00")),
+	)
+	second.DevStartDate = dirtyDate("2020-02-10T09:00:00").Unix()
+
+	unrelated := createTicket("Done", dirtyDate("2020-01-01T09:00:00"))
+	unrelated.Key = "ABC-4"
+
+	calculator := domain.DaysCalculator{
+		ClockNow: func() time.Time {
+			return dirtyDate("2020-02-11T19:00:00")
+		},
+	}
+	epics := domain.SummarizeEpics(calculator, []domain.Ticket{epic, first, second, unrelated}, domain.DefaultWorkflow, startDate, endDate)
+
+	assert.Equal(t, 1, len(epics), "There should be one epic")
+	assert.Equal(t, "Epic title", epics[0].Title, "Epic title should be taken from epic ticket")
+	assert.Equal(t, 2, epics[0].ChildCount, "Incorrect number of children")
+	assert.Equal(t, map[string]int{"Done": 1, "In Development": 1}, epics[0].ChildrenByState, "Incorrect children states")
+	assert.Equal(t, 2.0+2.0, epics[0].DevDays, "Incorrect dev time within boundaries")
+	assert.Equal(t, 7.0+2.0, epics[0].DevDaysTotal, "Incorrect total dev time")
+	assert.Equal(t, dirtyDate("2020-01-27T09:00:00"), epics[0].StartDate, "Epic should start with first child")
+	assert.Equal(t, domain.BeginingOfTime, epics[0].EndDate, "Epic should not end while child is in development")
+
+	second.State = "Done"
+	second.Transitions = domain.MakeIntervals(second,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-10T09:00:00")),
+		createTransition("In Development", "Done", dirtyDate("2020-02-11T17:00:00")),
+	)
+	second.DevEndDate = dirtyDate("2020-02-11T17:00:00").Unix()
+
+	epics = domain.SummarizeEpics(calculator, []domain.Ticket{epic, first, second, unrelated}, domain.DefaultWorkflow, startDate, endDate)
+	assert.Equal(t, dirtyDate("2020-02-11T17:00:00"), epics[0].EndDate, "Epic should end with last child")
+}
+
+// Tests that epic does not end while child which left development is not done yet
+func TestEpicNotEndedWhileChildInReview(t *testing.T) {
+	child := createTicket("In Review", dirtyDate("2020-01-01T09:00:00"))
+	child.EpicKey = "ABC-1"
+	child.Transitions = domain.MakeIntervals(child,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-03T09:00:00")),
+		createTransition("In Development", "In Review", dirtyDate("2020-02-04T17:00:00")),
+	)
+	child.DevStartDate = dirtyDate("2020-02-03T09:00:00").Unix()
+	child.DevEndDate = dirtyDate("2020-02-04T17:00:00").Unix()
+
+	epics := domain.SummarizeEpics(domain.DaysCalculator{}, []domain.Ticket{child}, domain.DefaultWorkflow,
+		dirtyDate("2020-02-01T00:00:00"), dirtyDate("2020-02-29T00:00:00"))
+
+	assert.Equal(t, domain.BeginingOfTime, epics[0].EndDate, "Epic should not end while child is in review")
+}
+
+// Tests children of epic which is not stored
+func TestEpicRollUpForMissingEpic(t *testing.T) {
+	child := createTicket("To Do", dirtyDate("2020-01-01T09:00:00"))
+	child.EpicKey = "XYZ-7"
+
+	epics := domain.SummarizeEpics(domain.DaysCalculator{}, []domain.Ticket{child}, domain.DefaultWorkflow,
+		dirtyDate("2020-01-01T00:00:00"), dirtyDate("2020-01-31T00:00:00"))
+
+	assert.Equal(t, 1, len(epics), "There should be one epic")
+	assert.Equal(t, "XYZ-7", epics[0].Key, "Epic key should be taken from child")
+	assert.Equal(t, "XYZ", epics[0].Project(), "Epic project should be taken from its key")
+	assert.Equal(t, 0.0, epics[0].DevDaysTotal, "There should be no dev time")
+}
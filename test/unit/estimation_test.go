@@ -0,0 +1,73 @@
+package unit
+
+import (
+	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Tests comparing dev time with original estimate and story points
+func TestEstimateAccuracy(t *testing.T) {
+	ticket := createTicket("Done", dirtyDate("2020-02-01T09:00:00"))
+	ticket.StoryPoints = 2
+	ticket.OriginalEstimate = 8 * 60 * 60
+	ticket.Transitions = domain.MakeIntervals(ticket,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-03T09:00:00")),
+		createTransition("In Development", "Done", dirtyDate("2020-02-05T19:00:00")),
+	)
+
+	notEstimated := createTicket("Done", dirtyDate("2020-02-01T09:00:00"))
+
+	calculator := domain.DaysCalculator{
+		ClockNow: func() time.Time {
+			return dirtyDate("2020-12-31T00:00:00")
+		},
+	}
+	accuracies := domain.CalculateEstimateAccuracy(calculator, []domain.Ticket{ticket, notEstimated})
+
+	assert.Equal(t, 1, len(accuracies), "Tickets with no estimates should be skipped")
+	assert.Equal(t, 3.0, accuracies[0].DevDays, "Incorrect dev time")
+	assert.Equal(t, 1.0, accuracies[0].EstimateDays, "Incorrect estimate")
+	assert.Equal(t, 1.5, accuracies[0].DaysPerPoint, "Incorrect days per point")
+	assert.Equal(t, 3.0, accuracies[0].EstimateRatio, "Incorrect ratio")
+	assert.True(t, accuracies[0].Outlier, "Ticket three times over estimate should be an outlier")
+}
+
+// Tests distribution of dev time for story points values and outliers within them
+func TestPointsDistribution(t *testing.T) {
+	accuracies := make([]domain.EstimateAccuracy, 0)
+	for _, devEnd := range []string{"2020-02-03T17:00:00", "2020-02-04T19:00:00", "2020-02-04T19:00:00", "2020-02-05T19:00:00", "2020-02-28T19:00:00"} {
+		ticket := createTicket("Done", dirtyDate("2020-02-01T09:00:00"))
+		ticket.StoryPoints = 3
+		ticket.Transitions = domain.MakeIntervals(ticket,
+			createTransition("To Do", "In Development", dirtyDate("2020-02-03T09:00:00")),
+			createTransition("In Development", "Done", dirtyDate(devEnd)),
+		)
+		accuracies = append(accuracies, domain.CalculateEstimateAccuracy(domain.DaysCalculator{}, []domain.Ticket{ticket})...)
+	}
+
+	distributions := domain.DistributeByPoints(accuracies)
+	assert.Equal(t, 1, len(distributions), "There should be one points value")
+	assert.Equal(t, 5, distributions[0].Count, "Incorrect number of tickets")
+	assert.Equal(t, 1.0, distributions[0].Min, "Incorrect min")
+	assert.Equal(t, 2.0, distributions[0].Median, "Incorrect median")
+	assert.Equal(t, 20.0, distributions[0].Max, "Incorrect max")
+
+	tickets := make([]domain.Ticket, 0)
+	for _, accuracy := range accuracies {
+		tickets = append(tickets, accuracy.Ticket)
+	}
+
+	flagged := domain.CalculateEstimateAccuracy(domain.DaysCalculator{}, tickets)
+	assert.False(t, flagged[0].Outlier, "Typical ticket should not be an outlier")
+	assert.True(t, flagged[4].Outlier, "Ticket far above others should be an outlier")
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{4, 1, 3, 2}
+	assert.Equal(t, 1.0, domain.Percentile(values, 0), "Incorrect min")
+	assert.Equal(t, 2.5, domain.Percentile(values, 50), "Incorrect median")
+	assert.Equal(t, 4.0, domain.Percentile(values, 100), "Incorrect max")
+	assert.Equal(t, 0.0, domain.Percentile([]float64{}, 50), "Empty values should give 0")
+}
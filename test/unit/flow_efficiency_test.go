@@ -0,0 +1,53 @@
+package unit
+
+import (
+	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Tests calculating active and waiting time within cycle
+func TestFlowEfficiency(t *testing.T) {
+	ticket := createTicket("Done", dirtyDate("2020-02-01T09:00:00"))
+	ticket.Transitions = domain.MakeIntervals(ticket,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-03T09:00:00")),
+		createTransition("In Development", "In Review", dirtyDate("2020-02-03T15:00:00")),
+		createTransition("In Review", "In Development", dirtyDate("2020-02-04T09:00:00")),
+		createTransition("In Development", "Done", dirtyDate("2020-02-04T11:00:00")),
+	)
+
+	efficiency, ok := domain.CalculateFlowEfficiency(ticket, domain.DefaultWorkflow)
+	assert.True(t, ok, "Done ticket should have flow efficiency")
+	assert.Equal(t, 8.0, efficiency.ActiveHours, "Incorrect active time")
+	assert.Equal(t, 18.0, efficiency.WaitingHours, "Incorrect waiting time")
+	assert.Equal(t, 8.0/26.0, efficiency.Efficiency(), "Incorrect flow efficiency")
+	assert.Equal(t, dirtyDate("2020-02-03T09:00:00"), efficiency.Start, "Cycle should start with first active state")
+	assert.Equal(t, dirtyDate("2020-02-04T11:00:00"), efficiency.End, "Cycle should end with done state")
+
+	notDone := createTicket("In Development", dirtyDate("2020-02-01T09:00:00"))
+	notDone.Transitions = domain.MakeIntervals(notDone,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-03T09:00:00")),
+	)
+	_, ok = domain.CalculateFlowEfficiency(notDone, domain.DefaultWorkflow)
+	assert.False(t, ok, "Ticket which is not done should be skipped")
+}
+
+// Tests aggregating flow efficiency by project and week
+func TestFlowEfficiencyAggregation(t *testing.T) {
+	efficiencies := []domain.FlowEfficiency{
+		{Ticket: domain.Ticket{Key: "ABC-1"}, ActiveHours: 2, WaitingHours: 2, End: dirtyDate("2020-02-04T11:00:00")},
+		{Ticket: domain.Ticket{Key: "ABC-2"}, ActiveHours: 4, WaitingHours: 0, End: dirtyDate("2020-02-09T11:00:00")},
+		{Ticket: domain.Ticket{Key: "XYZ-1"}, ActiveHours: 1, WaitingHours: 3, End: dirtyDate("2020-02-10T11:00:00")},
+	}
+
+	groups, err := domain.AggregateFlowEfficiency(efficiencies, []string{domain.GroupByProject, domain.PeriodWeek})
+	assert.Nil(t, err, "Grouping should succeed")
+	assert.Equal(t, 2, len(groups), "Incorrect number of groups")
+	assert.Equal(t, []string{"ABC", "2020-02-03"}, groups[0].Keys, "Incorrect group keys")
+	assert.Equal(t, 2, groups[0].Tickets, "Incorrect number of tickets")
+	assert.Equal(t, 0.75, groups[0].Efficiency(), "Incorrect aggregated flow efficiency")
+	assert.Equal(t, []string{"XYZ", "2020-02-10"}, groups[1].Keys, "Incorrect group keys")
+
+	_, err = domain.AggregateFlowEfficiency(efficiencies, []string{"unknown"})
+	assert.Error(t, err, "Unknown dimension should not be accepted")
+}
@@ -0,0 +1,90 @@
+package unit
+
+import (
+	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDailyThroughput(t *testing.T) {
+	first := createTicket("Done", dirtyDate("2020-02-01T09:00:00"))
+	first.Transitions = domain.MakeIntervals(first, createTransition("To Do", "Done", dirtyDate("2020-02-02T09:00:00")))
+
+	second := createTicket("Done", dirtyDate("2020-02-01T09:00:00"))
+	second.Transitions = domain.MakeIntervals(second, createTransition("To Do", "Done", dirtyDate("2020-02-02T19:00:00")))
+
+	throughput := domain.DailyThroughput([]domain.Ticket{first, second}, domain.DefaultWorkflow,
+		dirtyDate("2020-02-01T00:00:00"), dirtyDate("2020-02-04T00:00:00"))
+
+	assert.Equal(t, []int{0, 2, 0}, throughput, "Incorrect daily throughput")
+}
+
+// Tests forecast with constant throughput, where all the trials give the same result
+func TestForecastWithConstantThroughput(t *testing.T) {
+	forecast := domain.Forecast{
+		Items:      10,
+		TargetDate: dirtyDate("2020-02-11T00:00:00"),
+		Trials:     100,
+		Seed:       1,
+	}
+	history := []int{2, 2, 2}
+
+	results, err := forecast.ItemsByDate(history, dirtyDate("2020-02-01T15:00:00"))
+	assert.Nil(t, err, "Forecast should be calculated")
+	assert.Equal(t, 4, len(results), "There should be result for each confidence level")
+	assert.Equal(t, 20, results[0].Items, "Incorrect number of items")
+	assert.Equal(t, 20, results[3].Items, "Incorrect number of items")
+
+	results, err = forecast.DateForItems(history, dirtyDate("2020-02-01T15:00:00"))
+	assert.Nil(t, err, "Forecast should be calculated")
+	assert.Equal(t, dirtyDate("2020-02-06T00:00:00"), results[0].Date, "Incorrect date")
+	assert.Equal(t, dirtyDate("2020-02-06T00:00:00"), results[3].Date, "Incorrect date")
+}
+
+// Tests that same seed gives same results and higher confidence gives more conservative forecast
+func TestForecastIsRepeatable(t *testing.T) {
+	forecast := domain.Forecast{Items: 30, TargetDate: dirtyDate("2020-03-01T00:00:00"), Trials: 1000, Seed: 42}
+	history := []int{0, 1, 3, 0, 2, 5, 0, 1}
+	from := dirtyDate("2020-02-01T00:00:00")
+
+	first, _ := forecast.ItemsByDate(history, from)
+	second, _ := forecast.ItemsByDate(history, from)
+	assert.Equal(t, first, second, "Same seed should give same results")
+	assert.True(t, first[0].Items >= first[3].Items, "Higher confidence should give fewer items")
+
+	firstDates, _ := forecast.DateForItems(history, from)
+	secondDates, _ := forecast.DateForItems(history, from)
+	assert.Equal(t, firstDates, secondDates, "Same seed should give same results")
+	assert.False(t, firstDates[3].Date.Before(firstDates[0].Date), "Higher confidence should give later date")
+
+	_, err := forecast.DateForItems([]int{0, 0}, from)
+	assert.Error(t, err, "Forecast without throughput should fail")
+}
+
+func TestForecastTargetDateTooFar(t *testing.T) {
+	forecast := domain.Forecast{TargetDate: dirtyDate("2040-01-01T00:00:00"), Trials: 10}
+
+	_, err := forecast.ItemsByDate([]int{1, 2}, dirtyDate("2020-02-01T15:00:00"))
+	assert.EqualError(t, err, "target date [2040-01-01] is more than 3660 days ahead")
+}
+
+// Tests that trials abandoned at forecast horizon leave out only confidence levels they are needed for
+func TestForecastWithSparseThroughput(t *testing.T) {
+	forecast := domain.Forecast{Items: 10, Trials: 1000, Seed: 7}
+	history := make([]int, 300)
+	history[0] = 1
+	from := dirtyDate("2020-02-01T00:00:00")
+
+	results, err := forecast.DateForItems(history, from)
+	assert.Nil(t, err, "Forecast should be calculated when most trials finish")
+	assert.Equal(t, 2, len(results), "Confidence levels not reached should be left out")
+	assert.Equal(t, 50, results[0].Confidence)
+	assert.Equal(t, 70, results[1].Confidence)
+	for _, result := range results {
+		assert.False(t, result.Date.After(from.AddDate(0, 0, 3660)), "Dates should be within forecast horizon")
+	}
+
+	forecast.Items = 20
+	_, err = forecast.DateForItems(history, from)
+	assert.EqualError(t, err, "20 items can not be done within 3660 days in most trials at historical throughput")
+}
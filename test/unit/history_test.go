@@ -0,0 +1,199 @@
+package unit
+
+import (
+	"context"
+	jiraProcessor "github.com/VirtusLab/jira-stats/analyzer"
+	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/stretchr/testify/assert"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func createVersion(id string, state string, syncTime string) domain.TicketVersion {
+	ticket := createTicket(state, dirtyDate("2020-01-01T00:00:00"))
+	ticket.Id = id
+	ticket.Key = id
+	return domain.TicketVersion{Ticket: ticket, SyncTime: dirtyDate(syncTime)}
+}
+
+// Tests that latest versions synced until given time are used, deleted tickets are skipped
+func TestTicketsAsOf(t *testing.T) {
+	deleted := createVersion("ABC-2", "", "2020-03-01T00:00:00")
+	deleted.Deleted = true
+
+	versions := []domain.TicketVersion{
+		createVersion("ABC-1", "To Do", "2020-01-01T00:00:00"),
+		createVersion("ABC-1", "Done", "2020-03-01T00:00:00"),
+		createVersion("ABC-1", "In Progress", "2020-02-01T00:00:00"),
+		createVersion("ABC-2", "To Do", "2020-01-15T00:00:00"),
+		deleted,
+		createVersion("ABC-3", "To Do", "2020-04-01T00:00:00"),
+	}
+
+	states := func(tickets []domain.Ticket) map[string]string {
+		result := make(map[string]string)
+		for _, ticket := range tickets {
+			result[ticket.Id] = ticket.State
+		}
+		return result
+	}
+
+	assert.Equal(t, map[string]string{}, states(domain.TicketsAsOf(versions, dirtyDate("2019-12-31T00:00:00"))))
+	assert.Equal(t, map[string]string{"ABC-1": "To Do"}, states(domain.TicketsAsOf(versions, dirtyDate("2020-01-01T00:00:00"))))
+	assert.Equal(t, map[string]string{"ABC-1": "In Progress", "ABC-2": "To Do"},
+		states(domain.TicketsAsOf(versions, dirtyDate("2020-02-15T00:00:00"))))
+	assert.Equal(t, map[string]string{"ABC-1": "Done"}, states(domain.TicketsAsOf(versions, dirtyDate("2020-03-15T00:00:00"))))
+	assert.Equal(t, map[string]string{"ABC-1": "Done", "ABC-3": "To Do"}, states(domain.TicketsAsOf(versions, domain.EndOfTime)))
+
+	tickets := domain.TicketsAsOf(versions, domain.EndOfTime)
+	assert.Equal(t, "ABC-1", tickets[0].Id, "Tickets should keep order of their first versions")
+}
+
+func TestVersionExpiry(t *testing.T) {
+	superseded := dirtyDate("2020-03-01T00:00:00")
+
+	assert.True(t, domain.VersionExpiry(superseded, 0).IsZero(), "Versions should be kept forever without retention")
+	assert.Equal(t, dirtyDate("2020-03-31T00:00:00"), domain.VersionExpiry(superseded, 30*24*time.Hour))
+}
+
+func TestDevTimeOverlaps(t *testing.T) {
+	ticket := createTicket("Done", dirtyDate("2020-01-01T00:00:00"))
+	ticket.DevStartDate = dirtyDate("2020-02-10T00:00:00").Unix()
+	ticket.DevEndDate = dirtyDate("2020-02-20T00:00:00").Unix()
+
+	assert.True(t, ticket.DevTimeOverlaps(dirtyDate("2020-02-01T00:00:00"), dirtyDate("2020-02-15T00:00:00")))
+	assert.True(t, ticket.DevTimeOverlaps(dirtyDate("2020-02-15T00:00:00"), dirtyDate("2020-02-28T00:00:00")))
+	assert.True(t, ticket.DevTimeOverlaps(dirtyDate("2020-02-12T00:00:00"), dirtyDate("2020-02-14T00:00:00")))
+	assert.True(t, ticket.DevTimeOverlaps(dirtyDate("2020-02-01T00:00:00"), dirtyDate("2020-02-28T00:00:00")))
+	assert.False(t, ticket.DevTimeOverlaps(dirtyDate("2020-03-01T00:00:00"), dirtyDate("2020-03-31T00:00:00")))
+}
+
+func TestReportAsOf(t *testing.T) {
+	params := map[string]string{"startDate": "2020-01-01", "endDate": "2020-03-31"}
+
+	request, err := jiraProcessor.ParseReportRequest(params)
+	assert.Nil(t, err)
+	assert.True(t, request.AsOf.IsZero(), "Current tickets should be reported by default")
+
+	params["asOf"] = "2020-03-31"
+	request, err = jiraProcessor.ParseReportRequest(params)
+	assert.Nil(t, err)
+	assert.Equal(t, dirtyDate("2020-04-01T00:00:00").Add(-time.Nanosecond), request.AsOf, "Syncs of the whole day should be included")
+
+	params["asOf"] = "2020-03-31T12:30:00Z"
+	request, err = jiraProcessor.ParseReportRequest(params)
+	assert.Nil(t, err)
+	assert.Equal(t, dirtyDate("2020-03-31T12:30:00"), request.AsOf)
+
+	params["asOf"] = "last quarter"
+	_, err = jiraProcessor.ParseReportRequest(params)
+	assert.NotNil(t, err)
+}
+
+// Tests that every sync changing ticket stores version of it, previous version expires once retention passes after it was
+// superseded and reports as of given time read versions synced until then
+func TestStoredVersions(t *testing.T) {
+	db := newFakeDynamoDb(t)
+	defer db.server.Close()
+
+	env := map[string]string{
+		"JIRA_URL":                    "http://jira.example.com",
+		"JIRA_AUTH":                   "pat",
+		"JIRA_TOKEN":                  "token",
+		"JIRA_WEBHOOK_SECRET":         "secret",
+		"JIRA_HISTORY_RETENTION_DAYS": "30",
+	}
+	db.run(t, func() {
+		withEnv(t, env, func() {
+			tenant, err := jiraProcessor.LookupTenant("")
+			assert.Nil(t, err)
+			ctx := jiraProcessor.WithTenant(context.Background(), tenant)
+
+			processor := jiraProcessor.NewWebhookProcessor(tenant)
+			processor.FetchIssue = webhookProcessor(t, &webhookStore{}).FetchIssue
+
+			beforeSync := time.Now()
+			_, err = processor.Handle(ctx, readPayload(t, "webhook_issue_updated.json"), "", "secret")
+			assert.Nil(t, err)
+			afterUpdate := time.Now()
+			_, err = processor.Handle(ctx, readPayload(t, "webhook_issue_updated.json"), "", "secret")
+			assert.Nil(t, err)
+			assert.Equal(t, 1, len(db.items(jiraProcessor.TicketVersionTable)), "Unchanged ticket should not be stored again")
+			_, err = processor.Handle(ctx, readPayload(t, "webhook_issue_deleted.json"), "", "secret")
+			assert.Nil(t, err)
+			afterDelete := time.Now()
+
+			versions := db.items(jiraProcessor.TicketVersionTable)
+			assert.Equal(t, 2, len(versions), "Update and delete should store versions")
+			sort.Slice(versions, func(i, j int) bool { return scalar(versions[i]["Version"]) < scalar(versions[j]["Version"]) })
+
+			retention := 30 * 24 * time.Hour
+			expiresAt, err := strconv.ParseInt(scalar(versions[0]["ExpiresAt"]), 10, 64)
+			assert.Nil(t, err, "Superseded version should expire")
+			assert.True(t, expiresAt >= afterUpdate.Add(retention).Unix() && expiresAt <= afterDelete.Add(retention).Unix(),
+				"Superseded version should expire once retention passes after it was superseded")
+			assert.Nil(t, versions[1]["ExpiresAt"], "Latest version should not expire")
+
+			rows := func(asOf time.Time) int {
+				request := jiraProcessor.ReportRequest{
+					Name:      jiraProcessor.ReportDevTime,
+					Mode:      domain.ModeAll,
+					StartDate: dirtyDate("2020-02-01T00:00:00"),
+					EndDate:   dirtyDate("2020-02-29T23:59:59"),
+					AsOf:      asOf,
+				}
+				csv, err := jiraProcessor.GetReport(ctx, request)
+				assert.Nil(t, err)
+				return len(csv.Rows)
+			}
+			assert.Equal(t, 0, rows(beforeSync), "Ticket was not synced yet")
+			assert.Equal(t, 1, rows(afterUpdate), "Updated ticket should be reported")
+			assert.Equal(t, 0, rows(afterDelete), "Deleted ticket should not be reported")
+		})
+	})
+}
+
+// Tests that aging of tickets reported as of past time is measured until then
+func TestAgingWipAsOf(t *testing.T) {
+	db := newFakeDynamoDb(t)
+	defer db.server.Close()
+
+	env := map[string]string{
+		"JIRA_URL":            "http://jira.example.com",
+		"JIRA_AUTH":           "pat",
+		"JIRA_TOKEN":          "token",
+		"JIRA_WEBHOOK_SECRET": "secret",
+	}
+	db.run(t, func() {
+		withEnv(t, env, func() {
+			tenant, err := jiraProcessor.LookupTenant("")
+			assert.Nil(t, err)
+			ctx := jiraProcessor.WithTenant(context.Background(), tenant)
+
+			processor := jiraProcessor.NewWebhookProcessor(tenant)
+			processor.FetchIssue = webhookProcessor(t, &webhookStore{}).FetchIssue
+			_, err = processor.Handle(ctx, readPayload(t, "webhook_issue_updated.json"), "", "secret")
+			assert.Nil(t, err)
+
+			// ticket moved to development at 2020-02-03T09:30 was synced shortly after
+			version := db.items(jiraProcessor.TicketVersionTable)[0]
+			syncTime := dirtyDate("2020-02-03T10:00:00")
+			version["SyncTime"] = attributeValue{"S": syncTime.Format(time.RFC3339)}
+			version["SyncedAt"] = attributeValue{"N": strconv.FormatInt(syncTime.UnixNano(), 10)}
+			db.put(jiraProcessor.TicketVersionTable, version)
+
+			request := jiraProcessor.ReportRequest{
+				Name:      jiraProcessor.ReportAgingWip,
+				StartDate: dirtyDate("2020-01-01T00:00:00"),
+				EndDate:   dirtyDate("2020-02-29T23:59:59"),
+				AsOf:      dirtyDate("2020-02-10T09:30:00"),
+			}
+			csv, err := jiraProcessor.GetReport(ctx, request)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, len(csv.Rows))
+			assert.Equal(t, []string{"7.00", "7.00"}, csv.Rows[0].Entries[5:7], "Age should be measured until reported time")
+		})
+	})
+}
@@ -0,0 +1,210 @@
+package unit
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	jiraProcessor "github.com/VirtusLab/jira-stats/analyzer"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Sets env variables for the time of test
+func withEnv(t *testing.T, env map[string]string, test func()) {
+	for name, value := range env {
+		assert.Nil(t, os.Setenv(name, value))
+	}
+	defer func() {
+		for name := range env {
+			assert.Nil(t, os.Unsetenv(name))
+		}
+	}()
+
+	test()
+}
+
+// Fake Jira answering /myself when authorization header is accepted
+func fakeJira(t *testing.T, authorized func(header string) bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/rest/oauth2/latest/token":
+			assert.Equal(t, "refresh", request.FormValue("refresh_token"))
+			fmt.Fprint(writer, `{"access_token": "access", "expires_in": 3600}`)
+		case "/rest/api/2/myself":
+			if !authorized(request.Header.Get("Authorization")) {
+				writer.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(writer, `{"name": "test.user", "displayName": "Test User"}`)
+		default:
+			writer.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+// Personal Access Token is sent as bearer token
+func TestPatAuth(t *testing.T) {
+	server := fakeJira(t, func(header string) bool { return header == "Bearer secret-token" })
+	defer server.Close()
+
+	withEnv(t, map[string]string{"JIRA_URL": server.URL, "JIRA_AUTH": "pat", "JIRA_TOKEN": "secret-token"}, func() {
+		result, err := jiraProcessor.CheckAuth(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, "Authenticated as Test User (test.user) with [pat] auth", result)
+	})
+
+	withEnv(t, map[string]string{"JIRA_URL": server.URL, "JIRA_AUTH": "pat", "JIRA_TOKEN": "wrong-token"}, func() {
+		_, err := jiraProcessor.CheckAuth(context.Background())
+		assert.NotNil(t, err, "Rejected credentials should be reported")
+	})
+}
+
+// OAuth 2.0 access token is obtained with refresh token
+func TestOAuth2Auth(t *testing.T) {
+	server := fakeJira(t, func(header string) bool { return header == "Bearer access" })
+	defer server.Close()
+
+	env := map[string]string{
+		"JIRA_URL":                 server.URL,
+		"JIRA_AUTH":                "oauth2",
+		"JIRA_OAUTH_CLIENT_ID":     "client",
+		"JIRA_OAUTH_CLIENT_SECRET": "secret",
+		"JIRA_OAUTH_REFRESH_TOKEN": "refresh",
+	}
+	withEnv(t, env, func() {
+		_, err := jiraProcessor.CheckAuth(context.Background())
+		assert.Nil(t, err)
+	})
+}
+
+// OAuth 2.0 access token is obtained once per tenant and reused by following Jira clients, rotated refresh token
+// is written back to credentials secret
+func TestOAuth2TokensReusedAndRotated(t *testing.T) {
+	refreshes := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/rest/oauth2/latest/token":
+			refreshes = append(refreshes, request.FormValue("refresh_token"))
+			fmt.Fprint(writer, `{"access_token": "access", "refresh_token": "rotated", "expires_in": 3600}`)
+		case "/rest/api/2/myself":
+			assert.Equal(t, "Bearer access", request.Header.Get("Authorization"))
+			fmt.Fprint(writer, `{"name": "test.user", "displayName": "Test User"}`)
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "secrets")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	secretFile := filepath.Join(dir, "oauth2-creds")
+	assert.Nil(t, ioutil.WriteFile(secretFile, []byte(`{"clientId": "client", "clientSecret": "secret", "refreshToken": "refresh"}`), 0600))
+
+	env := map[string]string{
+		"JIRA_URL":              server.URL,
+		"JIRA_AUTH":             "oauth2",
+		"JIRA_SECRETS_PROVIDER": "file",
+		"JIRA_SECRETS_DIR":      dir,
+		"JIRA_SECRET_NAME":      "oauth2-creds",
+	}
+	withEnv(t, env, func() {
+		for i := 0; i < 2; i++ {
+			_, err := jiraProcessor.CheckAuth(context.Background())
+			assert.Nil(t, err)
+		}
+	})
+
+	assert.Equal(t, []string{"refresh"}, refreshes, "Access token should be reused by following clients")
+
+	secret, err := ioutil.ReadFile(secretFile)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"clientId": "client", "clientSecret": "secret", "refreshToken": "rotated"}`, string(secret))
+}
+
+// OAuth 1.0a requests are signed with consumer private key
+func TestOAuth1Auth(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.Nil(t, err)
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	var request *http.Request
+	server := fakeJira(t, func(header string) bool {
+		if !strings.HasPrefix(header, "OAuth ") {
+			return false
+		}
+
+		params := make(map[string]string)
+		for _, param := range strings.Split(strings.TrimPrefix(header, "OAuth "), ", ") {
+			pair := strings.SplitN(param, "=", 2)
+			value, err := url.QueryUnescape(strings.Trim(pair[1], `"`))
+			assert.Nil(t, err)
+			params[pair[0]] = value
+		}
+		assert.Equal(t, "consumer", params["oauth_consumer_key"])
+		assert.Equal(t, "RSA-SHA1", params["oauth_signature_method"])
+		assert.Equal(t, "access", params["oauth_token"])
+
+		signature, err := base64.StdEncoding.DecodeString(params["oauth_signature"])
+		assert.Nil(t, err)
+		delete(params, "oauth_signature")
+
+		digest := sha1.Sum([]byte(jiraProcessor.OAuth1BaseString(request, params)))
+		return rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA1, digest[:], signature) == nil
+	})
+	defer server.Close()
+
+	// request as seen by client, before it was sent
+	request, err = http.NewRequest("GET", server.URL+"/rest/api/2/myself", nil)
+	assert.Nil(t, err)
+
+	env := map[string]string{
+		"JIRA_URL":                server.URL,
+		"JIRA_AUTH":               "oauth1",
+		"JIRA_OAUTH_CONSUMER_KEY": "consumer",
+		"JIRA_OAUTH_PRIVATE_KEY":  string(privateKey),
+		"JIRA_OAUTH_ACCESS_TOKEN": "access",
+	}
+	withEnv(t, env, func() {
+		_, err := jiraProcessor.CheckAuth(context.Background())
+		assert.Nil(t, err, "Signature should be verified with public key of consumer")
+	})
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.Nil(t, err)
+	env["JIRA_OAUTH_PRIVATE_KEY"] = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(otherKey)}))
+	withEnv(t, env, func() {
+		_, err := jiraProcessor.CheckAuth(context.Background())
+		assert.NotNil(t, err, "Signature made with another key should be rejected")
+	})
+}
+
+// Requests are not sent once context is done, e.g. when lambda deadline has passed
+func TestCancelledContextAbortsJiraRequests(t *testing.T) {
+	requests := 0
+	server := fakeJira(t, func(header string) bool {
+		requests++
+		return true
+	})
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	withEnv(t, map[string]string{"JIRA_URL": server.URL, "JIRA_AUTH": "pat", "JIRA_TOKEN": "secret-token"}, func() {
+		_, err := jiraProcessor.CheckAuth(ctx)
+		assert.NotNil(t, err)
+		assert.Equal(t, 0, requests)
+	})
+}
@@ -0,0 +1,337 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	jiraProcessor "github.com/VirtusLab/jira-stats/analyzer"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type attributeValue map[string]interface{}
+type dbItem map[string]attributeValue
+
+// Fake DynamoDB keeping items in memory, supports expressions used by analyzer only
+type fakeDynamoDb struct {
+	server  *httptest.Server
+	lock    sync.Mutex
+	keys    map[string][]string // key attributes by table, hash key first
+	indexes map[string]string   // range key attributes by index name
+	tables  map[string][]dbItem
+	stall   func(operation string, input map[string]json.RawMessage) bool
+}
+
+func newFakeDynamoDb(t *testing.T) *fakeDynamoDb {
+	db := &fakeDynamoDb{
+		keys: map[string][]string{
+			jiraProcessor.LegacyTicketTable:  {"Id"},
+			jiraProcessor.LegacySprintTable:  {"Id"},
+			jiraProcessor.LegacyConfigTable:  {"ConfigName"},
+			jiraProcessor.TicketTable:        {"Tenant", "Id"},
+			jiraProcessor.SprintTable:        {"Tenant", "Id"},
+			jiraProcessor.ConfigTable:        {"Tenant", "ConfigName"},
+			jiraProcessor.TicketVersionTable: {"Tenant", "Version"},
+		},
+		indexes: map[string]string{jiraProcessor.SyncedAtIndex: jiraProcessor.SyncedAtAttribute},
+		tables:  make(map[string][]dbItem),
+	}
+	db.server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ := ioutil.ReadAll(request.Body)
+		var input map[string]json.RawMessage
+		assert.Nil(t, json.Unmarshal(body, &input))
+
+		operation := strings.TrimPrefix(request.Header.Get("X-Amz-Target"), "DynamoDB_20120810.")
+		if db.stalled(operation, input) {
+			<-request.Context().Done()
+			return
+		}
+		output, errorType := db.handle(t, operation, input)
+		writer.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		if errorType != "" {
+			writer.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(writer, `{"__type": "com.amazonaws.dynamodb.v20120810#%s", "message": "%s"}`, errorType, errorType)
+			return
+		}
+		json.NewEncoder(writer).Encode(output)
+	}))
+	return db
+}
+
+// Runs test against fake DB
+func (db *fakeDynamoDb) run(t *testing.T, test func()) {
+	env := map[string]string{
+		"DYNAMODB_ENDPOINT":     db.server.URL,
+		"AWS_REGION":            "eu-west-1",
+		"AWS_ACCESS_KEY_ID":     "test",
+		"AWS_SECRET_ACCESS_KEY": "test",
+	}
+	withEnv(t, env, test)
+}
+
+// Leaves requests matching given condition without response until client gives up
+func (db *fakeDynamoDb) stallOn(stall func(operation string, input map[string]json.RawMessage) bool) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.stall = stall
+}
+
+func (db *fakeDynamoDb) stalled(operation string, input map[string]json.RawMessage) bool {
+	db.lock.Lock()
+	stall := db.stall
+	db.lock.Unlock()
+
+	return stall != nil && stall(operation, input)
+}
+
+func (db *fakeDynamoDb) put(table string, item dbItem) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	index := db.find(table, item)
+	if index >= 0 {
+		db.tables[table][index] = item
+	} else {
+		db.tables[table] = append(db.tables[table], item)
+	}
+}
+
+func (db *fakeDynamoDb) items(table string) []dbItem {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	return append([]dbItem{}, db.tables[table]...)
+}
+
+func (db *fakeDynamoDb) find(table string, key dbItem) int {
+	for i, item := range db.tables[table] {
+		matches := true
+		for _, attribute := range db.keys[table] {
+			matches = matches && scalar(item[attribute]) == scalar(key[attribute])
+		}
+		if matches {
+			return i
+		}
+	}
+	return -1
+}
+
+func (db *fakeDynamoDb) handle(t *testing.T, operation string, input map[string]json.RawMessage) (interface{}, string) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	var table string
+	json.Unmarshal(input["TableName"], &table)
+	names := make(map[string]string)
+	json.Unmarshal(input["ExpressionAttributeNames"], &names)
+	values := make(map[string]attributeValue)
+	json.Unmarshal(input["ExpressionAttributeValues"], &values)
+
+	switch operation {
+	case "PutItem":
+		var item dbItem
+		json.Unmarshal(input["Item"], &item)
+		index := db.find(table, item)
+		if condition := stringField(input["ConditionExpression"]); condition != "" {
+			if !strings.HasPrefix(condition, "attribute_not_exists") {
+				t.Errorf("unsupported condition %s", condition)
+			}
+			if index >= 0 {
+				return nil, "ConditionalCheckFailedException"
+			}
+		}
+		if index >= 0 {
+			db.tables[table][index] = item
+		} else {
+			db.tables[table] = append(db.tables[table], item)
+		}
+		return map[string]interface{}{}, ""
+	case "GetItem":
+		var key dbItem
+		json.Unmarshal(input["Key"], &key)
+		if index := db.find(table, key); index >= 0 {
+			return map[string]interface{}{"Item": db.tables[table][index]}, ""
+		}
+		return map[string]interface{}{}, ""
+	case "DeleteItem":
+		var key dbItem
+		json.Unmarshal(input["Key"], &key)
+		if index := db.find(table, key); index >= 0 {
+			db.tables[table] = append(db.tables[table][:index], db.tables[table][index+1:]...)
+		}
+		return map[string]interface{}{}, ""
+	case "UpdateItem":
+		var key dbItem
+		json.Unmarshal(input["Key"], &key)
+		match := regexp.MustCompile(`^set (\S+) = (:\w+)$`).FindStringSubmatch(stringField(input["UpdateExpression"]))
+		if match == nil {
+			t.Errorf("unsupported update %s", stringField(input["UpdateExpression"]))
+			return nil, "ValidationException"
+		}
+		index := db.find(table, key)
+		if index < 0 {
+			db.tables[table] = append(db.tables[table], key)
+			index = len(db.tables[table]) - 1
+		}
+		db.tables[table][index][attributeName(match[1], names)] = values[match[2]]
+		return map[string]interface{}{}, ""
+	case "Scan":
+		return map[string]interface{}{"Items": db.tables[table], "Count": len(db.tables[table])}, ""
+	case "Query":
+		items := make([]dbItem, 0)
+		for _, item := range db.tables[table] {
+			if matchesAll(item, stringField(input["KeyConditionExpression"]), names, values) &&
+				matchesAll(item, stringField(input["FilterExpression"]), names, values) {
+				items = append(items, item)
+			}
+		}
+
+		rangeKey := db.keys[table][1]
+		if index := stringField(input["IndexName"]); index != "" {
+			rangeKey = db.indexes[index]
+		}
+		sort.SliceStable(items, func(i, j int) bool { return less(items[i][rangeKey], items[j][rangeKey]) })
+		if string(input["ScanIndexForward"]) == "false" {
+			for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+				items[i], items[j] = items[j], items[i]
+			}
+		}
+		var limit int
+		json.Unmarshal(input["Limit"], &limit)
+		if limit > 0 && len(items) > limit {
+			items = items[:limit]
+		}
+		return map[string]interface{}{"Items": items, "Count": len(items)}, ""
+	default:
+		t.Errorf("unsupported operation %s", operation)
+		return nil, "ValidationException"
+	}
+}
+
+var conditionPattern = regexp.MustCompile(`^(?:begins_with \((#\w+), (:\w+)\)|(#\w+) (=|<=) (:\w+))$`)
+
+// Checks conditions joined with AND, only equality, <= and begins_with are supported
+func matchesAll(item dbItem, expression string, names map[string]string, values map[string]attributeValue) bool {
+	if expression == "" {
+		return true
+	}
+
+	for _, condition := range strings.Split(expression, " AND ") {
+		if strings.HasPrefix(condition, "(") && strings.HasSuffix(condition, ")") {
+			condition = condition[1 : len(condition)-1]
+		}
+		match := conditionPattern.FindStringSubmatch(condition)
+		if match == nil {
+			panic("unsupported condition " + condition)
+		}
+
+		if match[1] != "" {
+			if !strings.HasPrefix(scalar(item[names[match[1]]]), scalar(values[match[2]])) {
+				return false
+			}
+			continue
+		}
+
+		value, ok := item[names[match[3]]]
+		if !ok {
+			return false
+		}
+		if match[4] == "=" && scalar(value) != scalar(values[match[5]]) {
+			return false
+		}
+		if match[4] == "<=" && less(values[match[5]], value) {
+			return false
+		}
+	}
+	return true
+}
+
+func attributeName(name string, names map[string]string) string {
+	if strings.HasPrefix(name, "#") {
+		return names[name]
+	}
+	return name
+}
+
+func scalar(value attributeValue) string {
+	for _, typed := range value {
+		return fmt.Sprint(typed)
+	}
+	return ""
+}
+
+func less(a attributeValue, b attributeValue) bool {
+	if _, ok := a["N"]; ok {
+		x, _ := strconv.ParseFloat(scalar(a), 64)
+		y, _ := strconv.ParseFloat(scalar(b), 64)
+		return x < y
+	}
+	return scalar(a) < scalar(b)
+}
+
+func stringField(raw json.RawMessage) string {
+	var value string
+	json.Unmarshal(raw, &value)
+	return value
+}
+
+func legacyTicket(id string, updated string) dbItem {
+	return dbItem{
+		"Id":         {"S": id},
+		"Key":        {"S": "ABC-" + id},
+		"State":      {"S": "Done"},
+		"UpdateTime": {"S": updated},
+	}
+}
+
+// Tests copying items of single tenant tables into tenant partition, items stored for tenant are kept
+func TestMigrateLegacyTables(t *testing.T) {
+	db := newFakeDynamoDb(t)
+	defer db.server.Close()
+
+	db.put(jiraProcessor.LegacyTicketTable, legacyTicket("1", "2020-03-01T10:00:00Z"))
+	db.put(jiraProcessor.LegacyTicketTable, legacyTicket("2", "2020-03-02T10:00:00Z"))
+	db.put(jiraProcessor.LegacySprintTable, dbItem{"Id": {"N": "7"}, "Name": {"S": "Sprint 7"}})
+	db.put(jiraProcessor.LegacyConfigTable, dbItem{"ConfigName": {"S": "LastUpdate"}, "ConfigValue": {"S": "2020-03-02T10:00:00Z"}})
+
+	// already fetched into tenant partition
+	fetched := legacyTicket("2", "2020-04-01T10:00:00Z")
+	fetched["Tenant"] = attributeValue{"S": "default"}
+	db.put(jiraProcessor.TicketTable, fetched)
+
+	db.run(t, func() {
+		ctx := jiraProcessor.WithTenant(context.Background(), jiraProcessor.Tenant{Id: "default"})
+
+		result, err := jiraProcessor.MigrateLegacyTables(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "Migrated 1 tickets (1 already stored) and 1 sprints into tenant default, last update is 2020-03-02T10:00:00Z", result)
+
+		tickets := db.items(jiraProcessor.TicketTable)
+		assert.Equal(t, 2, len(tickets))
+		for _, ticket := range tickets {
+			assert.Equal(t, "default", scalar(ticket["Tenant"]))
+		}
+		assert.Equal(t, "2020-04-01T10:00:00Z", scalar(tickets[0]["UpdateTime"]), "Fetched ticket should be kept")
+		assert.Equal(t, "1", scalar(tickets[1]["Id"]))
+
+		assert.Equal(t, 2, len(db.items(jiraProcessor.TicketVersionTable)))
+		assert.Equal(t, "Sprint 7", scalar(db.items(jiraProcessor.SprintTable)[0]["Name"]))
+		config := db.items(jiraProcessor.ConfigTable)
+		assert.Equal(t, "2020-03-02T10:00:00Z", scalar(config[0]["ConfigValue"]))
+
+		// repeated migration changes nothing, later cursor is kept
+		db.put(jiraProcessor.ConfigTable, dbItem{"Tenant": {"S": "default"}, "ConfigName": {"S": "LastUpdate"}, "ConfigValue": {"S": "2020-05-01T00:00:00Z"}})
+		result, err = jiraProcessor.MigrateLegacyTables(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "Migrated 0 tickets (2 already stored) and 0 sprints into tenant default, last update is 2020-05-01T00:00:00Z", result)
+	})
+}
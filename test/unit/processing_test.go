@@ -0,0 +1,115 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	jiraProcessor "github.com/VirtusLab/jira-stats/analyzer"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Fake Jira returning given issues to search, leaves search without response until client gives up when stalled
+func searchJira(t *testing.T, stalled bool, issues ...string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != "/rest/api/2/search" {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if stalled {
+			<-request.Context().Done()
+			return
+		}
+		fmt.Fprintf(writer, `{"startAt": 0, "maxResults": 10, "total": %d, "issues": [%s]}`, len(issues), strings.Join(issues, ","))
+	}))
+}
+
+func searchIssue(id string, updated string) string {
+	return fmt.Sprintf(`{
+		"id": "%s",
+		"key": "ABC-%s",
+		"fields": {
+			"summary": "Test ticket",
+			"issuetype": {"name": "Story"},
+			"status": {"name": "To Do"},
+			"project": {"key": "ABC"},
+			"created": "2020-02-01T09:00:00.000+0000",
+			"updated": "%s",
+			"worklog": {"startAt": 0, "maxResults": 20, "total": 0, "worklogs": []}
+		},
+		"changelog": {"histories": []}
+	}`, id, id, updated)
+}
+
+// Runs sync of default tenant against given Jira with deadline shortly after deadline margin
+func processWithDeadline(t *testing.T, jiraUrl string) (int, error) {
+	env := map[string]string{"JIRA_URL": jiraUrl, "JIRA_AUTH": "pat", "JIRA_TOKEN": "token", "JIRA_MAX_RETRIES": "0"}
+
+	var count int
+	var err error
+	withEnv(t, env, func() {
+		tenant, tenantErr := jiraProcessor.LookupTenant("")
+		assert.Nil(t, tenantErr)
+
+		ctx, cancel := context.WithTimeout(jiraProcessor.WithTenant(context.Background(), tenant),
+			jiraProcessor.DeadlineMargin+500*time.Millisecond)
+		defer cancel()
+
+		count, err = jiraProcessor.ProcessTickets(ctx, 10)
+		assert.Nil(t, ctx.Err(), "Work should end deadline margin before deadline")
+	})
+	return count, err
+}
+
+// Tests that storing tickets stops once deadline approaches, update time of the stored ones is saved as progress
+func TestProcessTicketsStopsBeforeDeadline(t *testing.T) {
+	db := newFakeDynamoDb(t)
+	defer db.server.Close()
+
+	jira := searchJira(t, false,
+		searchIssue("1", "2020-02-01T10:00:00.000+0000"),
+		searchIssue("2", "2020-02-02T10:00:00.000+0000"),
+		searchIssue("3", "2020-02-03T10:00:00.000+0000"))
+	defer jira.Close()
+
+	// storing the third ticket lasts until work is interrupted
+	db.stallOn(func(operation string, input map[string]json.RawMessage) bool {
+		var item dbItem
+		json.Unmarshal(input["Item"], &item)
+		return operation == "PutItem" && stringField(input["TableName"]) == jiraProcessor.TicketTable &&
+			scalar(item["Id"]) == "3"
+	})
+
+	db.run(t, func() {
+		count, err := processWithDeadline(t, jira.URL)
+		assert.Nil(t, err, "Approaching deadline should not fail sync")
+		assert.Equal(t, 2, count, "Tickets stored before deadline should be reported")
+
+		config := db.items(jiraProcessor.ConfigTable)
+		if assert.Equal(t, 1, len(config), "Progress should be saved") {
+			assert.Equal(t, "2020-02-02T10:00:00Z", scalar(config[0]["ConfigValue"]),
+				"Update time of the last stored ticket should be saved")
+		}
+	})
+}
+
+// Tests that batch interrupted while fetching tickets is dropped without error and without progress
+func TestProcessTicketsInterruptedWhileFetching(t *testing.T) {
+	db := newFakeDynamoDb(t)
+	defer db.server.Close()
+
+	jira := searchJira(t, true)
+	defer jira.Close()
+
+	db.run(t, func() {
+		count, err := processWithDeadline(t, jira.URL)
+		assert.Nil(t, err, "Approaching deadline should not fail sync")
+		assert.Equal(t, 0, count)
+		assert.Equal(t, 0, len(db.items(jiraProcessor.TicketTable)))
+		assert.Equal(t, 0, len(db.items(jiraProcessor.ConfigTable)), "Progress should not be saved")
+	})
+}
@@ -0,0 +1,57 @@
+package unit
+
+import (
+	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Tests counting backward transitions and dev time after first exit from development
+func TestRework(t *testing.T) {
+	startDate := dirtyDate("2020-02-01T00:00:00")
+	endDate := dirtyDate("2020-02-29T23:59:59")
+
+	reworked := createTicket("Done", dirtyDate("2020-02-01T09:00:00"))
+	reworked.Key = "ABC-1"
+	reworked.Transitions = domain.MakeIntervals(reworked,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-03T09:00:00")),
+		createTransition("In Development", "In Testing", dirtyDate("2020-02-03T17:00:00")),
+		createTransition("In Testing", "In Development", dirtyDate("2020-02-04T09:00:00")),
+		createTransition("In Development", "In Testing", dirtyDate("2020-02-05T19:00:00")),
+		createTransition("In Testing", "Done", dirtyDate("2020-02-06T09:00:00")),
+	)
+
+	clean := createTicket("Done", dirtyDate("2020-02-01T09:00:00"))
+	clean.Key = "XYZ-1"
+	clean.Transitions = domain.MakeIntervals(clean,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-03T09:00:00")),
+		createTransition("In Development", "Done", dirtyDate("2020-02-04T19:00:00")),
+	)
+
+	calculator := domain.DaysCalculator{
+		ClockNow: func() time.Time {
+			return dirtyDate("2020-12-31T00:00:00")
+		},
+	}
+	tickets := []domain.Ticket{clean, reworked}
+	reworks := domain.CalculateRework(calculator, tickets, domain.DefaultWorkflow, startDate, endDate)
+
+	assert.Equal(t, 1, len(reworks), "Only reworked ticket should be listed")
+	assert.Equal(t, "ABC-1", reworks[0].Ticket.Key, "Incorrect reworked ticket")
+	assert.Equal(t, 1, reworks[0].BackwardTransitions, "Incorrect number of backward transitions")
+	assert.Equal(t, 2.0, reworks[0].ExtraDevDays, "Incorrect extra dev time")
+
+	projects := domain.SummarizeProjectRework(tickets, reworks)
+	assert.Equal(t, []domain.ProjectRework{
+		{Project: "ABC", Tickets: 1, ReworkedTickets: 1, BackwardTransitions: 1, ExtraDevDays: 2.0},
+		{Project: "XYZ", Tickets: 1},
+	}, projects, "Incorrect project rework")
+}
+
+func TestBackwardTransition(t *testing.T) {
+	workflow := domain.DefaultWorkflow
+	assert.True(t, workflow.IsBackward("In Testing", "In Development"), "Moving to earlier state is backward")
+	assert.False(t, workflow.IsBackward("In Development", "In Testing"), "Moving to later state is not backward")
+	assert.False(t, workflow.IsBackward("Unknown", "To Do"), "States out of order are not backward")
+}
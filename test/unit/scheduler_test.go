@@ -0,0 +1,36 @@
+package unit
+
+import (
+	jiraProcessor "github.com/VirtusLab/jira-stats/analyzer"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Tests running scheduled job until schedule is stopped
+func TestSchedule(t *testing.T) {
+	runs := make(chan struct{}, 10)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		jiraProcessor.Schedule(10*time.Millisecond, func() { runs <- struct{}{} }, stop)
+		close(done)
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-runs:
+		case <-time.After(time.Second):
+			t.Fatalf("Job should be run every interval")
+		}
+	}
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Schedule should end when stopped")
+	}
+	assert.True(t, len(runs) <= 1, "Job should not be run after schedule is stopped")
+}
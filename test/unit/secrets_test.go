@@ -0,0 +1,144 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	jiraProcessor "github.com/VirtusLab/jira-stats/analyzer"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Provider counting lookups
+type countingProvider struct {
+	values map[string]string
+	calls  int
+}
+
+func (p *countingProvider) Secret(ctx context.Context, name string) ([]byte, error) {
+	p.calls++
+	value, ok := p.values[name]
+	if !ok {
+		return nil, errors.New("no secret " + name)
+	}
+	return []byte(value), nil
+}
+
+func TestCachingProviderKeepsSecretsForTtl(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	provider := &countingProvider{values: map[string]string{"JiraCreds": `{"token": "a"}`}}
+	cache := &jiraProcessor.CachingProvider{Provider: provider, Ttl: time.Minute, ClockNow: func() time.Time { return now }}
+
+	for i := 0; i < 3; i++ {
+		secret, err := cache.Secret(context.Background(), "JiraCreds")
+		assert.Nil(t, err)
+		assert.Equal(t, `{"token": "a"}`, string(secret))
+	}
+	assert.Equal(t, 1, provider.calls)
+
+	now = now.Add(2 * time.Minute)
+	provider.values["JiraCreds"] = `{"token": "b"}`
+	secret, err := cache.Secret(context.Background(), "JiraCreds")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"token": "b"}`, string(secret))
+	assert.Equal(t, 2, provider.calls)
+
+	cache.Invalidate()
+	_, err = cache.Secret(context.Background(), "JiraCreds")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, provider.calls)
+}
+
+func TestCachingProviderDoesNotCacheErrors(t *testing.T) {
+	provider := &countingProvider{values: map[string]string{}}
+	cache := &jiraProcessor.CachingProvider{Provider: provider, Ttl: time.Minute}
+
+	_, err := cache.Secret(context.Background(), "Missing")
+	assert.NotNil(t, err)
+	_, err = cache.Secret(context.Background(), "Missing")
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, provider.calls)
+}
+
+func TestEnvProvider(t *testing.T) {
+	withEnv(t, map[string]string{"JIRA_CREDS": `{"token": "env"}`}, func() {
+		secret, err := jiraProcessor.EnvProvider{}.Secret(context.Background(), "JIRA_CREDS")
+		assert.Nil(t, err)
+		assert.Equal(t, `{"token": "env"}`, string(secret))
+
+		_, err = jiraProcessor.EnvProvider{}.Secret(context.Background(), "JIRA_CREDS_MISSING")
+		assert.NotNil(t, err)
+	})
+}
+
+func TestFileProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "jira"), []byte(`{"token": "file"}`), 0600))
+
+	secret, err := jiraProcessor.FileProvider{Dir: dir}.Secret(context.Background(), "jira")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"token": "file"}`, string(secret))
+
+	_, err = jiraProcessor.FileProvider{Dir: dir}.Secret(context.Background(), "missing")
+	assert.NotNil(t, err)
+}
+
+func TestUnknownSecretProvider(t *testing.T) {
+	_, err := jiraProcessor.NewSecretProvider("vault", "")
+	assert.NotNil(t, err)
+}
+
+// Credentials are read from secret of configured provider when no credentials env vars are set
+func TestAuthWithFileSecret(t *testing.T) {
+	server := fakeJira(t, func(header string) bool { return header == "Bearer mounted-token" })
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "secrets")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "jira-creds"), []byte(`{"token": "mounted-token"}`), 0600))
+
+	withEnv(t, map[string]string{
+		"JIRA_URL":              server.URL,
+		"JIRA_AUTH":             "pat",
+		"JIRA_SECRETS_PROVIDER": "file",
+		"JIRA_SECRETS_DIR":      dir,
+		"JIRA_SECRET_NAME":      "jira-creds",
+	}, func() {
+		result, err := jiraProcessor.CheckAuth(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, "Authenticated as Test User (test.user) with [pat] auth", result)
+	})
+}
+
+// Cached credentials are read again once Jira rejects them
+func TestRejectedCredentialsInvalidateCache(t *testing.T) {
+	server := fakeJira(t, func(header string) bool { return header == "Bearer rotated-token" })
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "secrets")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	secretFile := filepath.Join(dir, "rotated-creds")
+	assert.Nil(t, ioutil.WriteFile(secretFile, []byte(`{"token": "old-token"}`), 0600))
+
+	withEnv(t, map[string]string{
+		"JIRA_URL":              server.URL,
+		"JIRA_AUTH":             "pat",
+		"JIRA_SECRETS_PROVIDER": "file",
+		"JIRA_SECRETS_DIR":      dir,
+		"JIRA_SECRET_NAME":      "rotated-creds",
+	}, func() {
+		_, err := jiraProcessor.CheckAuth(context.Background())
+		assert.NotNil(t, err, "Old token should be rejected")
+
+		assert.Nil(t, ioutil.WriteFile(secretFile, []byte(`{"token": "rotated-token"}`), 0600))
+		_, err = jiraProcessor.CheckAuth(context.Background())
+		assert.Nil(t, err, "Rotated token should be read again instead of cached one")
+	})
+}
@@ -0,0 +1,112 @@
+package unit
+
+import (
+	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Tests sprint membership replayed from sprint changes
+func TestSprintMembership(t *testing.T) {
+	ticket := createTicket("Done", dirtyDate("2020-01-01T09:00:00"))
+	ticket.Sprints = []int{2}
+	ticket.SprintChanges = []domain.SprintChange{
+		{SprintId: 1, Added: true, Timestamp: dirtyDate("2020-01-05T09:00:00")},
+		{SprintId: 1, Added: false, Timestamp: dirtyDate("2020-01-20T09:00:00")},
+		{SprintId: 2, Added: true, Timestamp: dirtyDate("2020-01-20T09:00:00")},
+	}
+
+	assert.False(t, ticket.InSprintAt(1, dirtyDate("2020-01-04T09:00:00")), "Ticket was not yet added to sprint")
+	assert.True(t, ticket.InSprintAt(1, dirtyDate("2020-01-06T09:00:00")), "Ticket was added to sprint")
+	assert.False(t, ticket.InSprintAt(1, dirtyDate("2020-01-21T09:00:00")), "Ticket was removed from sprint")
+	assert.True(t, ticket.InSprintAt(2, dirtyDate("2020-01-21T09:00:00")), "Ticket was moved to next sprint")
+
+	ticket.SprintChanges = nil
+	assert.True(t, ticket.InSprintAt(2, dirtyDate("2020-01-02T09:00:00")), "Ticket with no changes is in sprint since creation")
+	assert.False(t, ticket.InSprintAt(1, dirtyDate("2020-01-02T09:00:00")), "Ticket with no changes is in its sprints only")
+}
+
+// Tests sprint summary with committed, added, removed and carried over tickets
+func TestSprintSummary(t *testing.T) {
+	sprint := domain.Sprint{
+		Id:           7,
+		StartDate:    dirtyDate("2020-02-03T09:00:00"),
+		EndDate:      dirtyDate("2020-02-14T17:00:00"),
+		CompleteDate: dirtyDate("2020-02-14T18:00:00"),
+	}
+
+	committed := createTicket("Done", dirtyDate("2020-01-01T09:00:00"))
+	committed.Key = "ABC-1"
+	committed.Sprints = []int{7}
+	committed.SprintChanges = []domain.SprintChange{{SprintId: 7, Added: true, Timestamp: dirtyDate("2020-02-01T09:00:00")}}
+	committed.Transitions = domain.MakeIntervals(committed,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-03T10:00:00")),
+		createTransition("In Development", "Done", dirtyDate("2020-02-04T19:00:00")),
+	)
+
+	added := createTicket("In Development", dirtyDate("2020-01-01T09:00:00"))
+	added.Key = "ABC-2"
+	added.Sprints = []int{7, 8}
+	added.SprintChanges = []domain.SprintChange{{SprintId: 7, Added: true, Timestamp: dirtyDate("2020-02-05T09:00:00")}}
+	added.Transitions = domain.MakeIntervals(added,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-13T09:00:00")),
+	)
+
+	removed := createTicket("To Do", dirtyDate("2020-01-01T09:00:00"))
+	removed.Key = "ABC-3"
+	removed.SprintChanges = []domain.SprintChange{
+		{SprintId: 7, Added: true, Timestamp: dirtyDate("2020-02-01T09:00:00")},
+		{SprintId: 7, Added: false, Timestamp: dirtyDate("2020-02-06T09:00:00")},
+	}
+
+	other := createTicket("To Do", dirtyDate("2020-01-01T09:00:00"))
+	other.Key = "ABC-4"
+
+	calculator := domain.DaysCalculator{
+		ClockNow: func() time.Time {
+			return dirtyDate("2020-12-31T00:00:00")
+		},
+	}
+	summary := domain.SummarizeSprint(calculator, sprint, []domain.Ticket{committed, added, removed, other}, domain.DefaultWorkflow)
+
+	assert.Equal(t, []string{"ABC-1", "ABC-3"}, summary.Committed, "Incorrect committed tickets")
+	assert.Equal(t, []string{"ABC-1"}, summary.Completed, "Incorrect completed tickets")
+	assert.Equal(t, []string{"ABC-2"}, summary.Added, "Incorrect added tickets")
+	assert.Equal(t, []string{"ABC-3"}, summary.Removed, "Incorrect removed tickets")
+	assert.Equal(t, []string{"ABC-2"}, summary.CarriedOver, "Incorrect carried over tickets")
+	assert.Equal(t, 2.0+2.0, summary.DevDays, "Incorrect dev time within sprint")
+}
+
+// Tests that dev time of ticket counts towards sprint only while ticket was part of it
+func TestSprintDevDaysOnlyWhileInSprint(t *testing.T) {
+	sprint := domain.Sprint{
+		Id:           7,
+		StartDate:    dirtyDate("2020-02-03T09:00:00"),
+		EndDate:      dirtyDate("2020-02-14T17:00:00"),
+		CompleteDate: dirtyDate("2020-02-14T18:00:00"),
+	}
+
+	removed := createTicket("Done", dirtyDate("2020-01-01T09:00:00"))
+	removed.Key = "ABC-1"
+	removed.SprintChanges = []domain.SprintChange{
+		{SprintId: 7, Added: true, Timestamp: dirtyDate("2020-02-01T09:00:00")},
+		{SprintId: 7, Added: false, Timestamp: dirtyDate("2020-02-05T09:00:00")},
+	}
+	removed.Transitions = domain.MakeIntervals(removed,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-03T10:00:00")),
+		createTransition("In Development", "Done", dirtyDate("2020-02-12T17:00:00")),
+	)
+
+	assert.Equal(t, []domain.TransitionInterval{{Start: sprint.StartDate, End: dirtyDate("2020-02-05T09:00:00")}},
+		removed.SprintPeriods(7, sprint.StartDate, sprint.FinishDate()))
+
+	calculator := domain.DaysCalculator{
+		ClockNow: func() time.Time {
+			return dirtyDate("2020-12-31T00:00:00")
+		},
+	}
+	summary := domain.SummarizeSprint(calculator, sprint, []domain.Ticket{removed}, domain.DefaultWorkflow)
+
+	assert.Equal(t, 2.5, summary.DevDays, "Dev time after ticket was removed from sprint should not count")
+}
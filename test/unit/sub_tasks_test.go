@@ -0,0 +1,91 @@
+package unit
+
+import (
+	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Tests all the modes of treating sub-tasks
+func TestSubTaskModes(t *testing.T) {
+	startDate := dirtyDate("2020-01-01T00:00:00")
+	endDate := dirtyDate("2020-03-31T23:59:59")
+
+	parent := createTicket("Done", dirtyDate("2020-02-01T09:00:00"))
+	parent.Key = "ABC-1"
+	parent.SubTaskKeys = []string{"ABC-2"}
+	parent.Transitions = domain.MakeIntervals(parent,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-03T09:00:00")),
+		createTransition("In Development", "Done", dirtyDate("2020-02-04T19:00:00")),
+	)
+
+	subTask := createTicket("Done", dirtyDate("2020-02-01T09:00:00"))
+	subTask.Key = "ABC-2"
+	subTask.ParentKey = "ABC-1"
+	subTask.Transitions = domain.MakeIntervals(subTask,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-04T09:00:00")),
+		createTransition("In Development", "Done", dirtyDate("2020-02-05T19:00:00")),
+	)
+
+	calculator := domain.DaysCalculator{
+		ClockNow: func() time.Time {
+			return dirtyDate("2020-12-31T00:00:00")
+		},
+	}
+	tickets := []domain.Ticket{subTask, parent}
+
+	devTimes, _ := domain.CalculateDevTimes(calculator, tickets, domain.ModeAll, startDate, endDate)
+	assert.Equal(t, 2, len(devTimes), "Every ticket should be counted")
+
+	devTimes, _ = domain.CalculateDevTimes(calculator, tickets, domain.ModeRollUp, startDate, endDate)
+	assert.Equal(t, 1, len(devTimes), "Sub-task should be rolled up to parent")
+	assert.Equal(t, "ABC-1", devTimes[0].Ticket.Key, "Sub-task should be rolled up to parent")
+	assert.Equal(t, 2.0+2.0, devTimes[0].DevDays, "Incorrect number of dev days calculated")
+
+	devTimes, _ = domain.CalculateDevTimes(calculator, tickets, domain.ModeLeaf, startDate, endDate)
+	assert.Equal(t, 1, len(devTimes), "Only sub-task should be counted")
+	assert.Equal(t, "ABC-2", devTimes[0].Ticket.Key, "Only sub-task should be counted")
+	assert.Equal(t, 2.0, devTimes[0].DevDays, "Incorrect number of dev days calculated")
+
+	devTimes, _ = domain.CalculateDevTimes(calculator, tickets, domain.ModeUnion, startDate, endDate)
+	assert.Equal(t, 1, len(devTimes), "Sub-task should be merged with parent")
+	assert.Equal(t, 3.0, devTimes[0].DevDays, "Overlapping dev time should be counted once")
+
+	_, err := domain.CalculateDevTimes(calculator, tickets, "unknown", startDate, endDate)
+	assert.Error(t, err, "Unknown mode should not be accepted")
+}
+
+// Tests sub-task which parent is not known
+func TestSubTaskWithMissingParent(t *testing.T) {
+	subTask := createTicket("Done", dirtyDate("2020-02-01T09:00:00"))
+	subTask.ParentKey = "ABC-1"
+
+	devTimes, _ := domain.CalculateDevTimes(domain.DaysCalculator{}, []domain.Ticket{subTask}, domain.ModeRollUp,
+		dirtyDate("2020-01-01T00:00:00"), dirtyDate("2020-03-31T23:59:59"))
+
+	assert.Equal(t, 1, len(devTimes), "Missing parent should be represented")
+	assert.Equal(t, "ABC-1", devTimes[0].Ticket.Key, "Missing parent should be represented by key")
+}
+
+func TestMergingDevIntervals(t *testing.T) {
+	first := createTicket("Done", dirtyDate("2020-02-01T09:00:00"))
+	first.Transitions = domain.MakeIntervals(first,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-03T09:00:00")),
+		createTransition("In Development", "Done", dirtyDate("2020-02-04T19:00:00")),
+	)
+
+	second := createTicket("Done", dirtyDate("2020-02-01T09:00:00"))
+	second.Transitions = domain.MakeIntervals(second,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-03T10:00:00")),
+		createTransition("In Development", "In Review", dirtyDate("2020-02-03T12:00:00")),
+		createTransition("In Review", "In Development", dirtyDate("2020-02-06T09:00:00")),
+		createTransition("In Development", "Done", dirtyDate("2020-02-06T19:00:00")),
+	)
+
+	intervals := domain.MergeDevIntervals(first, second)
+	assert.Equal(t, 2, len(intervals), "Overlapping intervals should be merged")
+	assert.Equal(t, dirtyDate("2020-02-03T09:00:00"), intervals[0].Start, "Incorrect start of merged interval")
+	assert.Equal(t, dirtyDate("2020-02-04T19:00:00"), intervals[0].End, "Incorrect end of merged interval")
+	assert.Equal(t, dirtyDate("2020-02-06T09:00:00"), intervals[1].Start, "Incorrect start of separate interval")
+}
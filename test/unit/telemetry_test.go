@@ -0,0 +1,115 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/VirtusLab/jira-stats/analyzer/telemetry"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var telemetryNow = time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func TestJsonLogging(t *testing.T) {
+	var out bytes.Buffer
+	logger := &telemetry.Logger{Out: &out, Level: telemetry.LevelInfo, ClockNow: func() time.Time { return telemetryNow }}
+
+	ctx := telemetry.WithFields(context.Background(), "requestId", "abc", "attempt", 2)
+	logger.Log(ctx, telemetry.LevelDebug, "dropped")
+	logger.Log(ctx, telemetry.LevelWarn, "Fetched %d tickets\n", 5)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, 1, len(lines))
+	assert.Equal(t, `{"time":"2020-03-01T12:00:00Z","level":"warn","msg":"Fetched 5 tickets","requestId":"abc","attempt":2}`, lines[0])
+	assert.Equal(t, "abc", telemetry.FieldValue(ctx, "requestId"))
+}
+
+func TestParseLevel(t *testing.T) {
+	level, ok := telemetry.ParseLevel("DEBUG")
+	assert.True(t, ok)
+	assert.Equal(t, telemetry.LevelDebug, level)
+
+	level, ok = telemetry.ParseLevel("verbose")
+	assert.False(t, ok)
+	assert.Equal(t, telemetry.LevelInfo, level)
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+	registry := telemetry.NewRegistry()
+	registry.Count("tickets_fetched", 10)
+	registry.Count("tickets_fetched", 5)
+	registry.Count("jira_errors", 1, "status", "503")
+	registry.Gauge("sync_lag_seconds", 120)
+	registry.Gauge("sync_lag_seconds", 30)
+	registry.Duration("phase_duration_seconds", 1500*time.Millisecond, "phase", "fetch_issues")
+	registry.Duration("phase_duration_seconds", 500*time.Millisecond, "phase", "fetch_issues")
+
+	recorder := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, telemetry.PrometheusContentType, recorder.Header().Get("Content-Type"))
+	assert.Equal(t, `# TYPE jira_stats_jira_errors_total counter
+jira_stats_jira_errors_total{status="503"} 1
+# TYPE jira_stats_phase_duration_seconds summary
+jira_stats_phase_duration_seconds_sum{phase="fetch_issues"} 2
+jira_stats_phase_duration_seconds_count{phase="fetch_issues"} 2
+# TYPE jira_stats_sync_lag_seconds gauge
+jira_stats_sync_lag_seconds 30
+# TYPE jira_stats_tickets_fetched_total counter
+jira_stats_tickets_fetched_total 15
+`, recorder.Body.String())
+}
+
+func TestEmbeddedMetricFormat(t *testing.T) {
+	registry := telemetry.NewRegistry()
+	registry.ClockNow = func() time.Time { return telemetryNow }
+	registry.Count("tickets_fetched", 10) // recorded before EMF is enabled, not emitted
+	registry.EnableEmf()
+	registry.Count("tickets_stored", 4)
+	registry.Gauge("sync_lag_seconds", 60)
+	registry.Duration("phase_duration_seconds", time.Second, "phase", "store_tickets")
+	registry.Duration("phase_duration_seconds", 3*time.Second, "phase", "store_tickets")
+
+	var out bytes.Buffer
+	assert.Nil(t, registry.FlushEmf(&out, map[string]string{"requestId": "abc"}))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, 2, len(lines))
+
+	documents := make([]map[string]interface{}, 0)
+	for _, line := range lines {
+		document := make(map[string]interface{})
+		assert.Nil(t, json.Unmarshal([]byte(line), &document))
+		documents = append(documents, document)
+	}
+
+	assert.Equal(t, 4.0, documents[0]["tickets_stored"])
+	assert.Equal(t, 60.0, documents[0]["sync_lag_seconds"])
+	assert.Nil(t, documents[0]["tickets_fetched"])
+	assert.Equal(t, "abc", documents[0]["requestId"])
+	assert.Equal(t, map[string]interface{}{
+		"Timestamp": float64(telemetryNow.UnixNano() / int64(time.Millisecond)),
+		"CloudWatchMetrics": []interface{}{map[string]interface{}{
+			"Namespace":  "JiraStats",
+			"Dimensions": []interface{}{[]interface{}{}},
+			"Metrics": []interface{}{
+				map[string]interface{}{"Name": "sync_lag_seconds", "Unit": "None"},
+				map[string]interface{}{"Name": "tickets_stored", "Unit": "Count"},
+			},
+		}},
+	}, documents[0]["_aws"])
+
+	assert.Equal(t, "store_tickets", documents[1]["phase"])
+	assert.Equal(t, []interface{}{1.0, 3.0}, documents[1]["phase_duration_seconds"])
+	cloudWatch := documents[1]["_aws"].(map[string]interface{})["CloudWatchMetrics"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, []interface{}{[]interface{}{"phase"}}, cloudWatch["Dimensions"])
+
+	// values are emitted only once
+	out.Reset()
+	assert.Nil(t, registry.FlushEmf(&out, nil))
+	assert.Equal(t, "", out.String())
+}
@@ -0,0 +1,182 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	jiraProcessor "github.com/VirtusLab/jira-stats/analyzer"
+	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Tests single tenant configured with env vars when there is no tenants config
+func TestTenantFromEnv(t *testing.T) {
+	withEnv(t, map[string]string{"JIRA_URL": "https://jira.example.com", "JIRA_DEPLOYMENT": "cloud", "JIRA_DONE_STATES": "Shipped"}, func() {
+		tenants, err := jiraProcessor.Tenants()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(tenants))
+
+		tenant := tenants[0]
+		assert.Equal(t, jiraProcessor.DefaultTenantId, tenant.Id)
+		assert.Equal(t, "https://jira.example.com", tenant.JiraUrl)
+		assert.True(t, tenant.JiraConfig().IsCloud())
+		assert.Equal(t, jiraProcessor.DefaultJiraQuery, tenant.Query)
+		assert.Equal(t, []string{"Shipped"}, tenant.Workflow.DoneStates)
+		assert.Equal(t, jiraProcessor.DefaultSecretName, tenant.SecretName)
+	})
+}
+
+// Tests tenants config, settings not given fall back to defaults
+func TestTenantsConfig(t *testing.T) {
+	config := `[
+		{"id": "traffic", "jiraUrl": "https://jira.example.com", "query": "project = TO"},
+		{"id": "cloud-team", "jiraUrl": "https://example.atlassian.net", "deployment": "Cloud", "auth": "PAT",
+		 "secretName": "JiraCreds-cloud", "query": "project = CT",
+		 "fields": {"storyPoints": "customfield_10016"}, "workflow": {"doneStates": ["Done", "Won't Do"]}}
+	]`
+
+	withEnv(t, map[string]string{"JIRA_TENANTS": config, "JIRA_URL": "https://ignored.example.com"}, func() {
+		tenants, err := jiraProcessor.Tenants()
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(tenants))
+
+		traffic := tenants[0]
+		assert.Equal(t, "traffic", traffic.Id)
+		assert.Equal(t, "https://jira.example.com", traffic.JiraUrl)
+		assert.Equal(t, domain.DeploymentServer, traffic.Deployment)
+		assert.Equal(t, jiraProcessor.AuthBasic, traffic.Auth)
+		assert.Equal(t, jiraProcessor.DefaultSecretName, traffic.SecretName)
+		assert.Equal(t, domain.DefaultCustomFields, traffic.Fields)
+		assert.Equal(t, domain.DefaultWorkflow, traffic.Workflow)
+
+		cloud := tenants[1]
+		assert.True(t, cloud.JiraConfig().IsCloud())
+		assert.Equal(t, jiraProcessor.AuthPat, cloud.Auth)
+		assert.Equal(t, "JiraCreds-cloud", cloud.SecretName)
+		assert.Equal(t, "customfield_10016", cloud.Fields.StoryPoints)
+		assert.Equal(t, domain.DefaultCustomFields.EpicLink, cloud.Fields.EpicLink)
+		assert.Equal(t, []string{"Done", "Won't Do"}, cloud.Workflow.DoneStates)
+		assert.Equal(t, domain.DefaultWorkflow.ActiveStates, cloud.Workflow.ActiveStates)
+
+		tenant, err := jiraProcessor.LookupTenant("")
+		assert.Nil(t, err)
+		assert.Equal(t, "traffic", tenant.Id, "First tenant should be used by default")
+
+		tenant, err = jiraProcessor.LookupTenant("cloud-team")
+		assert.Nil(t, err)
+		assert.Equal(t, "cloud-team", tenant.Id)
+
+		_, err = jiraProcessor.LookupTenant("unknown")
+		assert.EqualError(t, err, "unknown tenant [unknown]")
+	})
+}
+
+func TestTenantsConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tenants")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "tenants.json")
+	assert.Nil(t, ioutil.WriteFile(file, []byte(`[{"id": "traffic", "jiraUrl": "https://jira.example.com", "query": "project = TO"}]`), 0600))
+
+	withEnv(t, map[string]string{"JIRA_TENANTS_FILE": file}, func() {
+		tenants, err := jiraProcessor.Tenants()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(tenants))
+		assert.Equal(t, "traffic", tenants[0].Id)
+	})
+}
+
+func TestInvalidTenantsConfig(t *testing.T) {
+	configs := map[string]string{
+		`{"id": "traffic"}`: "invalid tenants config: ",
+		`[]`:                "tenants config has no tenants",
+		`[{"jiraUrl": "https://jira.example.com", "query": "project = TO"}]`: "tenant id is missing",
+		`[{"id": "traffic", "query": "project = TO"}]`:                       "Jira URL of tenant [traffic] is missing",
+		`[{"id": "traffic", "jiraUrl": "https://jira.example.com"}]`:         "query of tenant [traffic] is missing",
+		`[{"id": "traffic", "jiraUrl": "https://jira.example.com", "query": "project = TO"},
+		  {"id": "traffic", "jiraUrl": "https://jira.example.com", "query": "project = AD"}]`: "tenant [traffic] is configured twice",
+	}
+
+	for config, expected := range configs {
+		withEnv(t, map[string]string{"JIRA_TENANTS": config}, func() {
+			_, err := jiraProcessor.Tenants()
+			if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), expected)
+			}
+		})
+	}
+}
+
+// Tests that every tenant authenticates against its own Jira with its own credentials
+func TestAuthPerTenant(t *testing.T) {
+	trafficJira := fakeJira(t, func(header string) bool { return header == "Bearer traffic-token" })
+	defer trafficJira.Close()
+	cloudJira := fakeJira(t, func(header string) bool { return header == "Bearer cloud-token" })
+	defer cloudJira.Close()
+
+	dir, err := ioutil.TempDir("", "secrets")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "JiraCreds-traffic"), []byte(`{"token": "traffic-token"}`), 0600))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "JiraCreds-cloud"), []byte(`{"token": "cloud-token"}`), 0600))
+
+	config := fmt.Sprintf(`[
+		{"id": "traffic", "jiraUrl": "%s", "auth": "pat", "secretName": "JiraCreds-traffic", "query": "project = TO"},
+		{"id": "cloud-team", "jiraUrl": "%s", "auth": "pat", "secretName": "JiraCreds-cloud", "query": "project = CT"}
+	]`, trafficJira.URL, cloudJira.URL)
+
+	env := map[string]string{
+		"JIRA_TENANTS":          config,
+		"JIRA_SECRETS_PROVIDER": "file",
+		"JIRA_SECRETS_DIR":      dir,
+		"JIRA_TOKEN":            "env-token", // not used by configured tenants
+	}
+	withEnv(t, env, func() {
+		for _, id := range []string{"traffic", "cloud-team"} {
+			tenant, err := jiraProcessor.LookupTenant(id)
+			assert.Nil(t, err)
+
+			result, err := jiraProcessor.CheckAuth(jiraProcessor.WithTenant(context.Background(), tenant))
+			assert.Nil(t, err, id)
+			assert.Equal(t, "Authenticated as Test User (test.user) with [pat] auth", result)
+		}
+	})
+}
+
+// Tests that webhook of tenant is accepted only with secret of that tenant
+func TestWebhookSecretPerTenant(t *testing.T) {
+	config := `[
+		{"id": "traffic", "jiraUrl": "https://jira.example.com", "query": "project = TO", "webhookSecret": "traffic-secret"},
+		{"id": "cloud-team", "jiraUrl": "https://example.atlassian.net", "query": "project = CT"}
+	]`
+
+	withEnv(t, map[string]string{"JIRA_TENANTS": config, "JIRA_WEBHOOK_SECRET": "shared-secret"}, func() {
+		body := []byte(`{"webhookEvent": "jira:issue_deleted", "issue": {"id": "1", "key": "TO-1"}}`)
+
+		traffic, err := jiraProcessor.LookupTenant("traffic")
+		assert.Nil(t, err)
+		processor := jiraProcessor.NewWebhookProcessor(traffic)
+		processor.Delete = func(ctx context.Context, ticketId string) error { return nil }
+
+		_, err = processor.Handle(context.Background(), body, "", "shared-secret")
+		assert.Equal(t, jiraProcessor.ErrWebhookUnauthorized, err, "Shared secret should not be accepted for configured tenant")
+		result, err := processor.Handle(context.Background(), body, "", "traffic-secret")
+		assert.Nil(t, err)
+		assert.Equal(t, "Deleted ticket TO-1", result)
+
+		cloud, err := jiraProcessor.LookupTenant("cloud-team")
+		assert.Nil(t, err)
+		_, err = jiraProcessor.NewWebhookProcessor(cloud).Handle(context.Background(), body, "", "traffic-secret")
+		assert.Equal(t, jiraProcessor.ErrWebhookUnauthorized, err, "Secret of another tenant should be rejected")
+	})
+
+	withEnv(t, map[string]string{"JIRA_WEBHOOK_SECRET": "shared-secret"}, func() {
+		tenant, err := jiraProcessor.LookupTenant("")
+		assert.Nil(t, err)
+		assert.Equal(t, "shared-secret", tenant.WebhookSecret, "Tenant configured with env vars should use JIRA_WEBHOOK_SECRET")
+	})
+}
@@ -0,0 +1,43 @@
+package unit
+
+import (
+	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Tests weekly counts of created, started and done tickets
+func TestWeeklyThroughput(t *testing.T) {
+	first := createTicket("Done", dirtyDate("2020-02-04T09:00:00"))
+	first.Type = "Story"
+	first.Transitions = domain.MakeIntervals(first,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-05T09:00:00")),
+		createTransition("In Development", "Done", dirtyDate("2020-02-11T09:00:00")),
+	)
+
+	second := createTicket("To Do", dirtyDate("2020-02-06T09:00:00"))
+	second.Type = "Story"
+	second.Transitions = domain.MakeIntervals(second)
+
+	third := createTicket("Done", dirtyDate("2020-01-06T09:00:00"))
+	third.Type = "Bug"
+	third.Transitions = domain.MakeIntervals(third,
+		createTransition("To Do", "Done", dirtyDate("2020-02-12T09:00:00")),
+	)
+
+	throughputs, err := domain.CalculateThroughput([]domain.Ticket{first, second, third}, domain.DefaultWorkflow,
+		dirtyDate("2020-02-01T00:00:00"), dirtyDate("2020-02-29T23:59:59"), domain.PeriodWeek)
+	assert.Nil(t, err, "Throughput should be calculated")
+
+	assert.Equal(t, []domain.Throughput{
+		{Period: dirtyDate("2020-02-03T00:00:00"), Project: "Ticket", Type: "Story", Created: 2, Started: 1, Done: 0},
+		{Period: dirtyDate("2020-02-10T00:00:00"), Project: "Ticket", Type: "Bug", Created: 0, Started: 0, Done: 1},
+		{Period: dirtyDate("2020-02-10T00:00:00"), Project: "Ticket", Type: "Story", Created: 0, Started: 0, Done: 1},
+	}, throughputs, "Incorrect throughput")
+	assert.Equal(t, 2, throughputs[0].NetChange(), "Backlog should grow")
+	assert.Equal(t, -1, throughputs[1].NetChange(), "Backlog should shrink")
+
+	_, err = domain.CalculateThroughput([]domain.Ticket{first}, domain.DefaultWorkflow,
+		dirtyDate("2020-02-01T00:00:00"), dirtyDate("2020-02-29T23:59:59"), domain.PeriodHour)
+	assert.Error(t, err, "Only weekly and monthly periods should be supported")
+}
@@ -0,0 +1,210 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	jiraProcessor "github.com/VirtusLab/jira-stats/analyzer"
+	"github.com/VirtusLab/jira-stats/analyzer/telemetry"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type exportedSpan struct {
+	TraceId      string `json:"traceId"`
+	SpanId       string `json:"spanId"`
+	ParentSpanId string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Kind         int    `json:"kind"`
+	Attributes   []struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	} `json:"attributes"`
+	Status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+}
+
+func (span exportedSpan) attribute(key string) interface{} {
+	for _, attribute := range span.Attributes {
+		if attribute.Key == key {
+			for _, value := range attribute.Value {
+				return value
+			}
+		}
+	}
+	return nil
+}
+
+// Fake OTLP collector keeping received spans
+type fakeCollector struct {
+	server  *httptest.Server
+	lock    sync.Mutex
+	spans   []exportedSpan
+	release chan struct{} // requests are answered once it is closed, when set
+}
+
+func newFakeCollector(t *testing.T) *fakeCollector {
+	collector := &fakeCollector{}
+	collector.server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if collector.release != nil {
+			<-collector.release
+		}
+		assert.Equal(t, "/v1/traces", request.URL.Path)
+		assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+
+		var body struct {
+			ResourceSpans []struct {
+				Resource struct {
+					Attributes []struct {
+						Key   string            `json:"key"`
+						Value map[string]string `json:"value"`
+					} `json:"attributes"`
+				} `json:"resource"`
+				ScopeSpans []struct {
+					Spans []exportedSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		content, _ := ioutil.ReadAll(request.Body)
+		assert.Nil(t, json.Unmarshal(content, &body))
+		assert.Equal(t, "test-service", body.ResourceSpans[0].Resource.Attributes[0].Value["stringValue"])
+
+		collector.lock.Lock()
+		defer collector.lock.Unlock()
+		collector.spans = append(collector.spans, body.ResourceSpans[0].ScopeSpans[0].Spans...)
+	}))
+	return collector
+}
+
+func (collector *fakeCollector) span(name string) (exportedSpan, bool) {
+	collector.lock.Lock()
+	defer collector.lock.Unlock()
+
+	for _, span := range collector.spans {
+		if span.Name == name {
+			return span, true
+		}
+	}
+	return exportedSpan{}, false
+}
+
+func TestSpansAreExported(t *testing.T) {
+	collector := newFakeCollector(t)
+	defer collector.server.Close()
+
+	exporter := telemetry.NewOtlpExporter(collector.server.URL+"/v1/traces", "test-service")
+	tracer := &telemetry.Tracer{Exporter: exporter}
+
+	ctx, parent := tracer.Start(context.Background(), "fetch_data", telemetry.KindInternal, "tickets", 3)
+	_, child := tracer.Start(ctx, "Jira GET /rest/api/2/search", telemetry.KindClient)
+	child.RecordError(errors.New("Jira responded with status [503]"))
+	child.End()
+	parent.End()
+	parent.End() // ending again has no effect
+
+	assert.Nil(t, exporter.Flush(context.Background()))
+	assert.Equal(t, 2, len(collector.spans))
+
+	exportedParent, _ := collector.span("fetch_data")
+	exportedChild, _ := collector.span("Jira GET /rest/api/2/search")
+	assert.Equal(t, parent.TraceId(), exportedParent.TraceId)
+	assert.Equal(t, "", exportedParent.ParentSpanId)
+	assert.Equal(t, "3", exportedParent.attribute("tickets"))
+	assert.Equal(t, 1, exportedParent.Status.Code)
+
+	assert.Equal(t, exportedParent.TraceId, exportedChild.TraceId)
+	assert.Equal(t, exportedParent.SpanId, exportedChild.ParentSpanId)
+	assert.Equal(t, 3, exportedChild.Kind)
+	assert.Equal(t, 2, exportedChild.Status.Code)
+	assert.Equal(t, "Jira responded with status [503]", exportedChild.Status.Message)
+	assert.Equal(t, parent.TraceId(), telemetry.FieldValue(ctx, "traceId"))
+}
+
+// Full batch is sent in background, so that ending span does not wait for collector
+func TestSpansAreExportedInBackground(t *testing.T) {
+	collector := newFakeCollector(t)
+	collector.release = make(chan struct{})
+	defer collector.server.Close()
+
+	exporter := telemetry.NewOtlpExporter(collector.server.URL+"/v1/traces", "test-service")
+	exporter.BatchSize = 2
+	tracer := &telemetry.Tracer{Exporter: exporter}
+
+	ended := make(chan struct{})
+	go func() {
+		for _, name := range []string{"first", "second", "third"} {
+			_, span := tracer.Start(context.Background(), name, telemetry.KindInternal)
+			span.End()
+		}
+		close(ended)
+	}()
+
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Fatal("Ending spans should not wait for collector")
+	}
+
+	close(collector.release)
+	assert.Nil(t, exporter.Flush(context.Background()))
+	assert.Equal(t, 3, len(collector.spans), "Queued batch and remaining span should be sent by flush")
+}
+
+func TestTraceContextPropagation(t *testing.T) {
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx, span := telemetry.StartSpan(telemetry.Extract(context.Background(), traceparent), "GET /generate_csv")
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.TraceId())
+	header := http.Header{}
+	telemetry.Inject(ctx, header)
+	assert.Equal(t, fmt.Sprintf("00-4bf92f3577b34da6a3ce929d0e0e4736-%s-01", span.SpanId()), header.Get("traceparent"))
+
+	for _, invalid := range []string{"", "garbage", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"} {
+		_, span := telemetry.StartSpan(telemetry.Extract(context.Background(), invalid), "root")
+		assert.NotEqual(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.TraceId())
+	}
+
+	assert.Equal(t, traceparent, telemetry.HeaderValue(map[string]string{"Traceparent": traceparent}, telemetry.TraceparentHeader))
+}
+
+// Jira calls are traced as client spans of caller, trace context is sent to Jira
+func TestJiraRequestsAreTraced(t *testing.T) {
+	collector := newFakeCollector(t)
+	defer collector.server.Close()
+
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		received = request.Header.Get("traceparent")
+		fmt.Fprint(writer, `{"name": "test.user", "displayName": "Test User"}`)
+	}))
+	defer server.Close()
+
+	exporter := telemetry.NewOtlpExporter(collector.server.URL+"/v1/traces", "test-service")
+	previous := telemetry.DefaultTracer.Exporter
+	telemetry.DefaultTracer.Exporter = exporter
+	defer func() { telemetry.DefaultTracer.Exporter = previous }()
+
+	withEnv(t, map[string]string{"JIRA_URL": server.URL, "JIRA_AUTH": "pat", "JIRA_TOKEN": "secret-token"}, func() {
+		ctx, parent := telemetry.StartSpan(context.Background(), "auth_check")
+		_, err := jiraProcessor.CheckAuth(ctx)
+		parent.End()
+		assert.Nil(t, err)
+	})
+	assert.Nil(t, exporter.Flush(context.Background()))
+
+	span, ok := collector.span("Jira GET /rest/api/2/myself")
+	assert.True(t, ok)
+	parent, _ := collector.span("auth_check")
+	assert.Equal(t, parent.SpanId, span.ParentSpanId)
+	assert.Equal(t, "200", span.attribute("http.status_code"))
+	assert.True(t, strings.HasPrefix(received, "00-"+parent.TraceId+"-"+span.SpanId))
+}
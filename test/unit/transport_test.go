@@ -0,0 +1,268 @@
+package unit
+
+import (
+	"context"
+	jiraProcessor "github.com/VirtusLab/jira-stats/analyzer"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Fake Jira failing with given statuses before answering with 200
+func failingJira(statuses []int, header http.Header) (*httptest.Server, *int32) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		attempt := int(atomic.AddInt32(&attempts, 1)) - 1
+		if attempt < len(statuses) {
+			for name, values := range header {
+				writer.Header()[name] = values
+			}
+			writer.WriteHeader(statuses[attempt])
+			return
+		}
+		writer.Write([]byte("ok"))
+	}))
+	return server, &attempts
+}
+
+// Config without rate limit recording delays instead of sleeping
+func recordingConfig(delays *[]time.Duration) jiraProcessor.TransportConfig {
+	config := jiraProcessor.DefaultTransportConfig
+	config.RateLimit = 0
+	config.Sleep = func(ctx context.Context, delay time.Duration) error {
+		if delay > 0 {
+			*delays = append(*delays, delay)
+		}
+		return nil
+	}
+	return config
+}
+
+func get(transport http.RoundTripper, url string) (*http.Response, error) {
+	return (&http.Client{Transport: transport}).Get(url)
+}
+
+func TestRetriesTransientFailures(t *testing.T) {
+	server, attempts := failingJira([]int{http.StatusServiceUnavailable, http.StatusBadGateway}, nil)
+	defer server.Close()
+
+	var delays []time.Duration
+	transport := jiraProcessor.NewResilientTransport(http.DefaultTransport, recordingConfig(&delays))
+
+	resp, err := get(transport, server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(3), *attempts)
+
+	// exponential backoff with jitter: [base/2, base], then [base, 2*base]
+	assert.Equal(t, 2, len(delays))
+	assert.True(t, delays[0] >= 500*time.Millisecond && delays[0] <= time.Second)
+	assert.True(t, delays[1] >= time.Second && delays[1] <= 2*time.Second)
+}
+
+func TestRetryAfterIsHonored(t *testing.T) {
+	server, attempts := failingJira([]int{http.StatusTooManyRequests}, http.Header{"Retry-After": {"7"}})
+	defer server.Close()
+
+	var delays []time.Duration
+	transport := jiraProcessor.NewResilientTransport(http.DefaultTransport, recordingConfig(&delays))
+
+	resp, err := get(transport, server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(2), *attempts)
+	assert.Equal(t, []time.Duration{7 * time.Second}, delays)
+}
+
+func TestRetryAfterIsCapped(t *testing.T) {
+	server, _ := failingJira([]int{http.StatusServiceUnavailable}, http.Header{"Retry-After": {"3600"}})
+	defer server.Close()
+
+	var delays []time.Duration
+	transport := jiraProcessor.NewResilientTransport(http.DefaultTransport, recordingConfig(&delays))
+
+	_, err := get(transport, server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, []time.Duration{jiraProcessor.DefaultTransportConfig.MaxDelay}, delays)
+}
+
+func TestRetriesAreLimited(t *testing.T) {
+	server, attempts := failingJira([]int{503, 503, 503, 503, 503}, nil)
+	defer server.Close()
+
+	var delays []time.Duration
+	config := recordingConfig(&delays)
+	config.MaxRetries = 2
+	transport := jiraProcessor.NewResilientTransport(http.DefaultTransport, config)
+
+	resp, err := get(transport, server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(3), *attempts)
+}
+
+func TestBackoffIsCappedForManyRetries(t *testing.T) {
+	statuses := make([]int, 70)
+	for i := range statuses {
+		statuses[i] = http.StatusServiceUnavailable
+	}
+	server, attempts := failingJira(statuses, nil)
+	defer server.Close()
+
+	var delays []time.Duration
+	config := recordingConfig(&delays)
+	config.MaxRetries = len(statuses)
+	config.BreakerThreshold = 0
+	transport := jiraProcessor.NewResilientTransport(http.DefaultTransport, config)
+
+	resp, err := get(transport, server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(len(statuses)+1), *attempts)
+	for _, delay := range delays[10:] {
+		assert.True(t, delay >= config.MaxDelay/2 && delay <= config.MaxDelay, "Delay should stay capped, got %s", delay)
+	}
+}
+
+func TestPermanentFailuresAreNotRetried(t *testing.T) {
+	for _, status := range []int{http.StatusInternalServerError, http.StatusUnauthorized, http.StatusNotFound} {
+		server, attempts := failingJira([]int{status}, nil)
+
+		var delays []time.Duration
+		transport := jiraProcessor.NewResilientTransport(http.DefaultTransport, recordingConfig(&delays))
+
+		resp, err := get(transport, server.URL)
+		assert.Nil(t, err)
+		assert.Equal(t, status, resp.StatusCode)
+		assert.Equal(t, int32(1), *attempts)
+		server.Close()
+	}
+}
+
+func TestRequestBodyIsResent(t *testing.T) {
+	var bodies []string
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body := make([]byte, 64)
+		n, _ := request.Body.Read(body)
+		bodies = append(bodies, string(body[:n]))
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	var delays []time.Duration
+	client := &http.Client{Transport: jiraProcessor.NewResilientTransport(http.DefaultTransport, recordingConfig(&delays))}
+
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"jql": "project = ABC"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{`{"jql": "project = ABC"}`, `{"jql": "project = ABC"}`}, bodies)
+}
+
+func TestCircuitBreakerOpens(t *testing.T) {
+	server, attempts := failingJira([]int{500, 500, 500, 500, 500}, nil)
+	defer server.Close()
+
+	var delays []time.Duration
+	config := recordingConfig(&delays)
+	config.BreakerThreshold = 3
+	transport := jiraProcessor.NewResilientTransport(http.DefaultTransport, config)
+
+	for i := 0; i < 3; i++ {
+		resp, err := get(transport, server.URL)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	}
+
+	_, err := get(transport, server.URL)
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), jiraProcessor.ErrCircuitOpen.Error()))
+	assert.Equal(t, int32(3), *attempts)
+}
+
+func TestCircuitBreakerCloses(t *testing.T) {
+	server, attempts := failingJira([]int{500, 500}, nil)
+	defer server.Close()
+
+	var delays []time.Duration
+	config := recordingConfig(&delays)
+	config.BreakerThreshold = 2
+	config.BreakerCooldown = 50 * time.Millisecond
+	transport := jiraProcessor.NewResilientTransport(http.DefaultTransport, config)
+
+	get(transport, server.URL)
+	get(transport, server.URL)
+	_, err := get(transport, server.URL)
+	assert.NotNil(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+	resp, err := get(transport, server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(3), *attempts)
+}
+
+func TestRateLimit(t *testing.T) {
+	server, _ := failingJira(nil, nil)
+	defer server.Close()
+
+	var delays []time.Duration
+	config := recordingConfig(&delays)
+	config.RateLimit = 10
+	transport := jiraProcessor.NewResilientTransport(http.DefaultTransport, config)
+
+	for i := 0; i < 3; i++ {
+		_, err := get(transport, server.URL)
+		assert.Nil(t, err)
+	}
+
+	// first request goes immediately, next ones wait for their slots
+	assert.Equal(t, 2, len(delays))
+	assert.True(t, delays[0] > 50*time.Millisecond && delays[0] <= 100*time.Millisecond)
+	assert.True(t, delays[1] > 150*time.Millisecond && delays[1] <= 200*time.Millisecond)
+}
+
+func TestCancelledRequestIsNotRetried(t *testing.T) {
+	server, attempts := failingJira([]int{503, 503}, nil)
+	defer server.Close()
+
+	config := jiraProcessor.DefaultTransportConfig
+	config.RateLimit = 0
+	transport := jiraProcessor.NewResilientTransport(http.DefaultTransport, config)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+
+	_, err := (&http.Client{Transport: transport}).Do(request)
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(1), *attempts)
+}
+
+// Tests that requests cancelled by caller do not open circuit breaker
+func TestCancelledRequestsDoNotOpenBreaker(t *testing.T) {
+	stalled := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		<-request.Context().Done()
+	}))
+	defer stalled.Close()
+
+	var delays []time.Duration
+	config := recordingConfig(&delays)
+	config.BreakerThreshold = 2
+	transport := jiraProcessor.NewResilientTransport(http.DefaultTransport, config)
+
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		request, _ := http.NewRequestWithContext(ctx, http.MethodGet, stalled.URL, nil)
+		_, err := (&http.Client{Transport: transport}).Do(request)
+		cancel()
+		assert.NotNil(t, err)
+		assert.False(t, strings.Contains(err.Error(), jiraProcessor.ErrCircuitOpen.Error()), "Breaker should stay closed")
+	}
+}
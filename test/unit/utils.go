@@ -30,6 +30,12 @@ func createTransition(from string, to string, timestamp time.Time) domain.Transi
 	}
 }
 
+func createAuthoredTransition(from string, to string, timestamp time.Time, author string) domain.Transition {
+	transition := createTransition(from, to, timestamp)
+	transition.Author = author
+	return transition
+}
+
 func dirtyDate(dateString string) time.Time {
 	date, err := time.Parse(SimpleDateFormat, dateString)
 	if err != nil {
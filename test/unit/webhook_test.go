@@ -0,0 +1,102 @@
+package unit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	jiraProcessor "github.com/VirtusLab/jira-stats/analyzer"
+	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/andygrunwald/go-jira"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+type webhookStore struct {
+	stored  []domain.Ticket
+	deleted []string
+}
+
+// Creates processor working on recorded payload instead of Jira and on in-memory store instead of DB
+func webhookProcessor(t *testing.T, store *webhookStore) jiraProcessor.WebhookProcessor {
+	return jiraProcessor.WebhookProcessor{
+		Secret: "secret",
+		FetchIssue: func(ctx context.Context, key string) (jira.Issue, error) {
+			event := jiraProcessor.WebhookEvent{}
+			assert.Nil(t, json.Unmarshal(readPayload(t, "webhook_issue_updated.json"), &event))
+
+			issue := event.Issue
+			history := changeLog([]jira.ChangelogHistory{
+				changeLogHistoryItem("2020-02-03T09:30:00.000+0000", []jira.ChangelogItems{changeLogItem("status", "To Do", "In Development")}),
+			})
+			issue.Changelog = &history
+			return issue, nil
+		},
+		Store: func(ctx context.Context, tickets []domain.Ticket) error {
+			store.stored = append(store.stored, tickets...)
+			return nil
+		},
+		Delete: func(ctx context.Context, ticketId string) error {
+			store.deleted = append(store.deleted, ticketId)
+			return nil
+		},
+		Config: domain.DefaultJiraConfig,
+	}
+}
+
+func readPayload(t *testing.T, name string) []byte {
+	payload, err := ioutil.ReadFile("testdata/" + name)
+	assert.Nil(t, err)
+	return payload
+}
+
+// Updated issue is re-fetched and stored
+func TestWebhookIssueUpdated(t *testing.T) {
+	store := &webhookStore{}
+	payload := readPayload(t, "webhook_issue_updated.json")
+
+	_, err := webhookProcessor(t, store).Handle(context.Background(), payload, "", "secret")
+	assert.Nil(t, err)
+
+	assert.Equal(t, 1, len(store.stored), "Updated ticket should be stored")
+	assert.Equal(t, "ABC-1", store.stored[0].Key)
+	assert.Equal(t, "In Development", store.stored[0].State)
+	assert.Equal(t, 2, len(store.stored[0].Transitions), "Transitions should be taken from fetched changelog")
+}
+
+// Deleted issue is removed by its id
+func TestWebhookIssueDeleted(t *testing.T) {
+	store := &webhookStore{}
+	payload := readPayload(t, "webhook_issue_deleted.json")
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(payload)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	_, err := webhookProcessor(t, store).Handle(context.Background(), payload, signature, "")
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"10123"}, store.deleted, "Deleted ticket should be removed")
+	assert.Equal(t, 0, len(store.stored))
+}
+
+// Webhooks without matching secret are rejected
+func TestWebhookUnauthorized(t *testing.T) {
+	store := &webhookStore{}
+	payload := readPayload(t, "webhook_issue_updated.json")
+	processor := webhookProcessor(t, store)
+
+	_, err := processor.Handle(context.Background(), payload, "", "wrong")
+	assert.Equal(t, jiraProcessor.ErrWebhookUnauthorized, err, "Wrong secret should be rejected")
+
+	_, err = processor.Handle(context.Background(), payload, "sha256=0000", "secret")
+	assert.Equal(t, jiraProcessor.ErrWebhookUnauthorized, err, "Wrong signature should be rejected")
+
+	processor.Secret = ""
+	_, err = processor.Handle(context.Background(), payload, "", "")
+	assert.Equal(t, jiraProcessor.ErrWebhookUnauthorized, err, "Webhooks should be rejected when secret is not set")
+
+	assert.Equal(t, 0, len(store.stored)+len(store.deleted), "Nothing should be changed")
+}
@@ -0,0 +1,54 @@
+package unit
+
+import (
+	"github.com/VirtusLab/jira-stats/analyzer/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Tests comparing logged work with dev time inferred from transitions
+func TestWorkComparison(t *testing.T) {
+	startDate := dirtyDate("2020-02-01T00:00:00")
+	endDate := dirtyDate("2020-02-29T23:59:59")
+
+	ticket := createTicket("Done", dirtyDate("2020-02-01T09:00:00"))
+	ticket.Transitions = domain.MakeIntervals(ticket,
+		createTransition("To Do", "In Development", dirtyDate("2020-02-03T09:00:00")),
+		createAuthoredTransition("In Development", "Done", dirtyDate("2020-02-05T19:00:00"), "john"),
+	)
+	ticket.Worklogs = []domain.Worklog{
+		{Id: "1", Author: "john", Started: dirtyDate("2020-02-03T09:00:00"), TimeSpentSeconds: 4 * 3600},
+		{Id: "2", Author: "jane", Started: dirtyDate("2020-02-04T09:00:00"), TimeSpentSeconds: 2 * 3600},
+		{Id: "3", Author: "john", Started: dirtyDate("2020-03-04T09:00:00"), TimeSpentSeconds: 8 * 3600},
+	}
+
+	calculator := domain.DaysCalculator{
+		ClockNow: func() time.Time {
+			return dirtyDate("2020-12-31T00:00:00")
+		},
+	}
+
+	tickets := domain.CompareTicketWork(calculator, []domain.Ticket{ticket}, startDate, endDate)
+	assert.Equal(t, 1, len(tickets), "There should be one ticket compared")
+	assert.Equal(t, 6.0, tickets[0].LoggedHours, "Worklogs outside boundaries should not be counted")
+	assert.Equal(t, 24.0, tickets[0].InferredHours, "Incorrect inferred dev time")
+	assert.Equal(t, -18.0, tickets[0].Difference(), "Incorrect difference")
+	assert.True(t, tickets[0].Discrepancy(), "Difference should be flagged")
+
+	people := domain.ComparePersonWork(calculator, []domain.Ticket{ticket}, startDate, endDate)
+	assert.Equal(t, 2, len(people), "There should be two people compared")
+	assert.Equal(t, "jane", people[0].Person, "People should be sorted")
+	assert.Equal(t, 2.0, people[0].LoggedHours, "Incorrect logged time")
+	assert.Equal(t, 0.0, people[0].InferredHours, "Incorrect inferred time")
+	assert.Equal(t, 4.0, people[1].LoggedHours, "Incorrect logged time")
+	assert.Equal(t, 24.0, people[1].InferredHours, "Dev time should be attributed to author of transition")
+}
+
+func TestSmallDifferenceIsNoDiscrepancy(t *testing.T) {
+	work := domain.WorkComparison{LoggedHours: 10, InferredHours: 16}
+	assert.False(t, work.Discrepancy(), "Difference lower than a day should not be flagged")
+
+	work = domain.WorkComparison{LoggedHours: 40, InferredHours: 50}
+	assert.False(t, work.Discrepancy(), "Relatively small difference should not be flagged")
+}